@@ -19,18 +19,60 @@ import (
 
 var (
 	livenessObject     = flag.String("l", "https://speed.cloudflare.com/__down?bytes=%d", "liveness object, support http(s) url, support payload too")
-	configPathConfig   = flag.String("c", "", "configuration file path, also support http(s) url")
+	configPathConfig   = flag.String("c", "", "configuration file path, also support http(s) url and - for stdin")
 	filterRegexConfig  = flag.String("f", ".*", "filter proxies by name, use regexp")
+	filterExpr         = flag.String("filter", "", "filter proxies by config fields, e.g. 'type in (hysteria2, tuic) && port not in (80, 443)'")
 	downloadSizeConfig = flag.Int("size", 1024*1024*100, "download size for testing proxies")
 	timeoutConfig      = flag.Duration("timeout", time.Second*30, "timeout for testing proxies")
 	sortField          = flag.String("sort", "b", "sort field for testing proxies, b for bandwidth, t for TTFB")
-	output             = flag.String("output", "", "output result to csv/yaml file")
+	output             = flag.String("output", "", "output result to file, format (csv/yaml/json/jsonl/md/html/uri/base64/sing-box) or file name with such extension")
 	bandwidthConcur    = flag.Int("concurrent-bandwidth", 4, "concurrency for bandwidth testing")
 	enableLatencyStats = flag.Bool("enable-latency-metrics", false, "collect latency p50/p90/p95/jitter/loss-rate metrics")
 	latencySamples     = flag.Int("latency-samples", 3, "measured latency samples after warmup when latency metrics are enabled")
 	delayUrl           = flag.String("delay-url", "", "URL to use for latency testing")
+	renameTemplate     = flag.String("rename", "", "rename exported proxies by template, e.g. \"{flag} {country} {idx} | {bandwidth} | {delay}ms\"")
+	csvColumns         = flag.String("csv-columns", "", "comma separated csv columns, e.g. \"name,type,server,delay,p90,checks\"")
+	csvLanguage        = flag.String("csv-lang", "zh", "csv header language, zh or en")
+	csvNoBOM           = flag.Bool("csv-no-bom", false, "do not write UTF-8 BOM to csv")
+	csvIncludeDead     = flag.Bool("csv-include-dead", false, "include dead proxies in csv")
+	cacheDir           = flag.String("cache-dir", "", "persist test results in this directory and reuse them across runs")
+	cacheTTL           = flag.Duration("cache-ttl", 30*time.Minute, "ttl of persisted alive test results")
+	cacheDeadTTL       = flag.Duration("cache-dead-ttl", 5*time.Minute, "ttl of persisted dead test results, 0 disables negative caching")
+	cacheSize          = flag.Int("cache-size", 10000, "max number of persisted test results")
+	expiryWarning      = flag.Duration("expiry-warning", 7*24*time.Hour, "warn when a subscription expires within this duration")
+	dedupPolicy        = flag.String("dedup", "", "drop duplicate proxies before testing, policy first/shortest_name/merge_sources")
+	sourceUA           = flag.String("ua", "", "user agent used to download configuration sources, default clash-meta")
+	sourceProxy        = flag.String("source-proxy", "", "proxy used to download configuration sources, e.g. http://127.0.0.1:7890 or DIRECT")
+	sourceTimeout      = flag.Duration("source-timeout", 30*time.Minute, "max time to download one configuration source while its proxies are tested, negative for no limit; a source that sends no data for 60s fails regardless")
+	snapshotDir        = flag.String("snapshot-dir", "", "keep the last good copy of each subscription in this directory and fall back to it when a fetch fails")
+	evaluateGroups     = flag.Bool("groups", false, "report which proxy each url-test/fallback/load-balance group of the config would select")
+	sourceHeaders      headerFlag
 )
 
+func init() {
+	flag.Var(&sourceHeaders, "header", "extra header used to download configuration sources, e.g. \"Authorization: Bearer xxx\", can be repeated")
+}
+
+// headerFlag 可重复的 "Key: Value" 请求头参数
+type headerFlag map[string][]string
+
+func (h *headerFlag) String() string {
+	return ""
+}
+
+func (h *headerFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(key) == "" {
+		return errors.New("header must be in the form \"Key: Value\"")
+	}
+	if *h == nil {
+		*h = make(headerFlag)
+	}
+	key = strings.TrimSpace(key)
+	(*h)[key] = append((*h)[key], strings.TrimSpace(val))
+	return nil
+}
+
 func main() {
 	flag.Parse()
 
@@ -45,6 +87,7 @@ func main() {
 		Timeout:              *timeoutConfig,
 		ConfigPath:           *configPathConfig,
 		NameRegexContain:     *filterRegexConfig,
+		Filter:               *filterExpr,
 		SortField:            models.SortField(*sortField),
 		BandwidthConcurrency: *bandwidthConcur,
 		EnableLatencyMetrics: *enableLatencyStats,
@@ -52,6 +95,48 @@ func main() {
 		DelayTestUrl:         *delayUrl,
 		Progress:             models.ProgressConfig{PrintProgress: true},
 		SourceConcurrency:    3,
+		RenameTemplate:       *renameTemplate,
+		Dedup:                models.DedupPolicy(*dedupPolicy),
+		// 订阅即将到期时在加载阶段输出警告，测速结束后的配置源报告中也会标出
+		SubscriptionExpiryWarning: *expiryWarning,
+		SourceTimeout:             *sourceTimeout,
+		SnapshotDir:               *snapshotDir,
+		EvaluateGroups:            *evaluateGroups,
+		Stdin:                     os.Stdin,
+		Export: models.ExportOptions{
+			CSV: models.CSVOptions{
+				Language:    *csvLanguage,
+				NoBOM:       *csvNoBOM,
+				IncludeDead: *csvIncludeDead,
+			},
+		},
+	}
+	if *sourceUA != "" || *sourceProxy != "" || len(sourceHeaders) > 0 {
+		header := map[string][]string(sourceHeaders)
+		if *sourceUA != "" {
+			if header == nil {
+				header = make(map[string][]string)
+			}
+			header["User-Agent"] = []string{*sourceUA}
+		}
+		// 对 ConfigPath 中的所有来源生效
+		options.SourceOptions = map[string]models.ProxyProvider{
+			"*": {Header: header, Proxy: *sourceProxy},
+		}
+	}
+	if *csvColumns != "" {
+		options.Export.CSV.Columns = strings.Split(*csvColumns, ",")
+	}
+	if *cacheDir != "" {
+		cache, err := speedtest.NewFileCacheWithPolicy(*cacheDir, models.CacheTTLPolicy{
+			AliveTTL: *cacheTTL,
+			DeadTTL:  *cacheDeadTTL,
+		}, *cacheSize)
+		if err != nil {
+			log.Fatal().Msgf("%v", err)
+		}
+		defer cache.Close()
+		options.Cache = cache
 	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -74,14 +159,35 @@ func main() {
 	d, err := t.AliveProxiesToJson()
 	log.Info().Msgf("json: %s", d)
 	t.LogAlive()
+	t.LogSourceReports()
+	if *evaluateGroups {
+		t.LogGroupReports()
+	}
 
-	if strings.EqualFold(*output, "yaml") {
-		if err = t.WriteToYaml(); err != nil {
-			log.Fatal().Msgf("Failed to write yaml: %s", err)
+	if *output != "" {
+		if err := writeOutput(t, *output); err != nil {
+			log.Fatal().Msgf("Failed to write %s: %s", *output, err)
 		}
-	} else if strings.EqualFold(*output, "csv") {
-		if err := t.WriteToCsv(); err != nil {
-			log.Fatal().Msgf("Failed to write csv: %s", err)
+	}
+}
+
+// outputExtensions 格式名与默认文件扩展名不一致的导出格式
+var outputExtensions = map[string]string{
+	"uri":      "txt",
+	"base64":   "txt",
+	"sing-box": "json",
+	"singbox":  "json",
+}
+
+// writeOutput output 为格式名时写入 result.<ext>，否则按文件扩展名判断格式
+func writeOutput(t *speedtest.Test, output string) error {
+	if _, ok := speedtest.GetExporter(output); ok {
+		format := strings.ToLower(output)
+		ext, ok := outputExtensions[format]
+		if !ok {
+			ext = format
 		}
+		return t.WriteToFile("result."+ext, format)
 	}
+	return t.WriteToFile(output, "")
 }
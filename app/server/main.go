@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,6 +14,18 @@ import (
 	"github.com/xiecang/speedtest-clash/speedtest/models"
 )
 
+var (
+	cacheDir     = flag.String("cache-dir", "", "persist test results in this directory and reuse them across restarts")
+	cacheTTL     = flag.Duration("cache-ttl", 30*time.Minute, "ttl of persisted alive test results")
+	cacheDeadTTL = flag.Duration("cache-dead-ttl", 5*time.Minute, "ttl of persisted dead test results, 0 disables negative caching")
+	cacheSize    = flag.Int("cache-size", 10000, "max number of persisted test results")
+	snapshotDir  = flag.String("snapshot-dir", "", "save subscription snapshots in this directory, send conditional requests and fall back to the last good copy when a download fails")
+	annotation   = flag.String("json-annotation", string(models.AnnotationExtension),
+		`how filter_alive attaches test results to returned proxies when the request leaves export.json_annotation empty: "extension" writes delay, bandwidth and check results under the x-speedtest field (replaces the former "_check" field), "" returns plain proxy configs`)
+
+	cache models.Cache
+)
+
 func resError(w http.ResponseWriter, err error) {
 	w.WriteHeader(http.StatusInternalServerError)
 	_, e := w.Write([]byte(fmt.Sprintf("{\"msg\": \"%s\"}", err.Error())))
@@ -21,6 +34,12 @@ func resError(w http.ResponseWriter, err error) {
 	}
 }
 
+// filterAliveReport 携带配置源报告时的响应
+type filterAliveReport struct {
+	Proxies json.RawMessage       `json:"proxies"`
+	Sources []models.SourceReport `json:"sources"`
+}
+
 func filterAlive(w http.ResponseWriter, req *http.Request) {
 	if req.Method != "POST" {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -40,6 +59,12 @@ func filterAlive(w http.ResponseWriter, req *http.Request) {
 	if body.Timeout <= time.Second {
 		body.Timeout = 1 * time.Minute
 	}
+	body.Cache = cache
+	body.SnapshotDir = *snapshotDir
+	// 默认在返回的节点中保留检测结果（x-speedtest.check_results），与旧版本的 _check 字段对应
+	if body.Export.JSONAnnotation == models.AnnotationNone {
+		body.Export.JSONAnnotation = models.AnnotationMode(*annotation)
+	}
 	t, err := speedtest.NewTest(body)
 	if err != nil {
 		log.Errorln("new test error: %v", err)
@@ -58,13 +83,33 @@ func filterAlive(w http.ResponseWriter, req *http.Request) {
 		resError(w, err)
 		return
 	}
+	// ?report=1 时同时返回各配置源的健康报告
+	if req.URL.Query().Get("report") != "" {
+		res, err = json.Marshal(filterAliveReport{Proxies: res, Sources: t.SourceReports()})
+		if err != nil {
+			log.Errorln("source report to json error: %v", err)
+			resError(w, err)
+			return
+		}
+	}
 	t.LogNum()
 	//t.LogAlive()
 	w.Write(res)
 }
 
 func main() {
-
+	flag.Parse()
+	if *cacheDir != "" {
+		fileCache, err := speedtest.NewFileCacheWithPolicy(*cacheDir, models.CacheTTLPolicy{
+			AliveTTL: *cacheTTL,
+			DeadTTL:  *cacheDeadTTL,
+		}, *cacheSize)
+		if err != nil {
+			log.Fatalln("%v", err)
+		}
+		defer fileCache.Close()
+		cache = fileCache
+	}
 	http.HandleFunc("/api/clash_speedtest/v1/filter_alive", filterAlive)
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
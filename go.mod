@@ -4,6 +4,7 @@ go 1.25.0
 
 require (
 	filippo.io/intermediates v0.0.0-20260424031642-2a58309389a4
+	github.com/dlclark/regexp2 v1.12.0
 	github.com/metacubex/mihomo v1.19.24
 	github.com/phuslu/log v1.0.124
 	github.com/stretchr/testify v1.11.1
@@ -18,7 +19,6 @@ require (
 	github.com/bahlo/generic-list-go v0.2.0 // indirect
 	github.com/coreos/go-iptables v0.8.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/dlclark/regexp2 v1.12.0 // indirect
 	github.com/dunglas/httpsfv v1.0.2 // indirect
 	github.com/enfein/mieru/v3 v3.31.0 // indirect
 	github.com/ericlagergren/aegis v0.0.0-20250325060835-cd0defd64358 // indirect
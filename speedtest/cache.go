@@ -2,10 +2,8 @@ package speedtest
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/hex"
-	"encoding/json"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/xiecang/speedtest-clash/speedtest/models"
@@ -17,12 +15,40 @@ var (
 	defaultCacheOnce     sync.Once
 )
 
+const defaultCacheTTL = 30 * time.Minute
+
 // DefaultCache 默认内存缓存实现
 type DefaultCache struct {
-	storage       sync.Map
-	defaultTTL    time.Duration
-	cleanupTicker *time.Ticker
-	stopChan      chan struct{}
+	storage         sync.Map
+	cleanupInterval time.Duration // 清理过期缓存项的间隔
+	policy          models.CacheTTLPolicy
+	cleanupTicker   *time.Ticker
+	stopChan        chan struct{}
+	counters        cacheCounters
+}
+
+// cacheCounters 缓存命中统计计数器
+type cacheCounters struct {
+	hits, misses, sets, skipped atomic.Int64
+}
+
+func (c *cacheCounters) stats() models.CacheStats {
+	return models.CacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Sets:    c.sets.Load(),
+		Skipped: c.skipped.Load(),
+	}
+}
+
+// hit 记录一次查询结果，返回 ok 便于直接 return
+func (c *cacheCounters) hit(ok bool) bool {
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return ok
 }
 
 type cacheItem struct {
@@ -33,34 +59,27 @@ type cacheItem struct {
 // GetDefaultCache 获取单例默认缓存
 func GetDefaultCache() models.Cache {
 	defaultCacheOnce.Do(func() {
-		defaultCacheInstance = &DefaultCache{
-			defaultTTL: 30 * time.Minute,
-			stopChan:   make(chan struct{}),
-		}
-		// 启动清理协程
-		defaultCacheInstance.(*DefaultCache).startCleanup()
+		defaultCacheInstance = NewDefaultCache()
 	})
 	return defaultCacheInstance
 }
 
 // NewDefaultCache 创建新的默认缓存实例（不推荐，建议使用 GetDefaultCache）
 func NewDefaultCache() models.Cache {
-	cache := &DefaultCache{
-		defaultTTL: 30 * time.Minute,
-		stopChan:   make(chan struct{}),
-	}
-
-	// 启动清理协程
-	cache.startCleanup()
-
-	return cache
+	return NewDefaultCacheWithTTL(defaultCacheTTL)
 }
 
-// NewDefaultCacheWithTTL 创建指定TTL的默认缓存
+// NewDefaultCacheWithTTL 创建指定TTL的默认缓存，有效与无效节点使用相同的TTL
 func NewDefaultCacheWithTTL(ttl time.Duration) models.Cache {
+	return NewDefaultCacheWithPolicy(models.CacheTTLPolicy{AliveTTL: ttl, DeadTTL: ttl})
+}
+
+// NewDefaultCacheWithPolicy 创建按测速结果决定TTL的默认缓存
+func NewDefaultCacheWithPolicy(policy models.CacheTTLPolicy) models.Cache {
 	cache := &DefaultCache{
-		defaultTTL: ttl,
-		stopChan:   make(chan struct{}),
+		cleanupInterval: cleanupInterval(policy),
+		policy:          policy,
+		stopChan:        make(chan struct{}),
 	}
 
 	// 启动清理协程
@@ -69,6 +88,18 @@ func NewDefaultCacheWithTTL(ttl time.Duration) models.Cache {
 	return cache
 }
 
+// cleanupInterval 清理间隔取策略中较短的有效 TTL
+func cleanupInterval(policy models.CacheTTLPolicy) time.Duration {
+	interval := policy.AliveTTL
+	if policy.DeadTTL > 0 && (interval <= 0 || policy.DeadTTL < interval) {
+		interval = policy.DeadTTL
+	}
+	if interval <= 0 {
+		interval = defaultCacheTTL
+	}
+	return interval
+}
+
 func (c *DefaultCache) Get(ctx context.Context, key string) (*models.CProxyWithResult, bool) {
 	if item, ok := c.storage.Load(key); ok {
 		cacheItem, ok := item.(*cacheItem)
@@ -79,47 +110,63 @@ func (c *DefaultCache) Get(ctx context.Context, key string) (*models.CProxyWithR
 
 		// 检查是否过期
 		if cacheItem.expiresAt.IsZero() || time.Now().Before(cacheItem.expiresAt) {
-			return cacheItem.result, true
+			return cacheItem.result, c.counters.hit(true)
 		}
 
 		// 过期了，删除
 		c.storage.Delete(key)
 	}
 
-	return nil, false
+	return nil, c.counters.hit(false)
 }
 
+// Set 按 TTL 策略缓存结果，策略不允许缓存的结果（如不完整的结果）会被跳过
 func (c *DefaultCache) Set(ctx context.Context, key string, result *models.CProxyWithResult) error {
+	ttl, ok := c.policy.TTL(&result.Result)
+	if !ok {
+		c.counters.skipped.Add(1)
+		return nil
+	}
 	item := &cacheItem{
 		result:    result,
-		expiresAt: time.Now().Add(c.defaultTTL),
+		expiresAt: time.Now().Add(ttl),
 	}
 
 	c.storage.Store(key, item)
+	c.counters.sets.Add(1)
 	return nil
 }
 
+// Stats 返回缓存命中统计
+func (c *DefaultCache) Stats() models.CacheStats {
+	return c.counters.stats()
+}
+
 func (c *DefaultCache) GenerateKey(proxy *models.CProxy) string {
-	bytes, err := json.Marshal(proxy.SecretConfig)
-	if err != nil {
-		return proxy.Name()
+	return generateCacheKey(proxy)
+}
+
+// generateCacheKey 以节点的规范身份作为缓存键，节点改名不会导致缓存失效
+func generateCacheKey(proxy *models.CProxy) string {
+	if key := ProxyIdentity(proxy.SecretConfig); key != "" {
+		return key
 	}
-	hash := md5.Sum(bytes)
-	md5Str := hex.EncodeToString(hash[:])
-	return md5Str
+	return proxy.Name()
 }
 
 // startCleanup 启动清理协程
 func (c *DefaultCache) startCleanup() {
-	c.cleanupTicker = time.NewTicker(c.defaultTTL)
+	// Close 会将字段置空，协程中使用局部变量避免读到 nil
+	ticker, stop := time.NewTicker(c.cleanupInterval), c.stopChan
+	c.cleanupTicker = ticker
 
 	go func() {
 		for {
 			select {
-			case <-c.cleanupTicker.C:
+			case <-ticker.C:
 				c.cleanup()
-			case <-c.stopChan:
-				c.cleanupTicker.Stop()
+			case <-stop:
+				ticker.Stop()
 				return
 			}
 		}
@@ -0,0 +1,93 @@
+package speedtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+func TestCacheTTLPolicy(t *testing.T) {
+	policy := models.CacheTTLPolicy{AliveTTL: time.Hour, DeadTTL: time.Minute}
+
+	ttl, ok := policy.TTL(&models.Result{Delay: 100})
+	assert.True(t, ok)
+	assert.Equal(t, time.Hour, ttl)
+
+	ttl, ok = policy.TTL(&models.Result{})
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, ttl)
+
+	_, ok = policy.TTL(&models.Result{Delay: 100, Partial: true})
+	assert.False(t, ok)
+
+	policy.CachePartial = true
+	_, ok = policy.TTL(&models.Result{Delay: 100, Partial: true})
+	assert.True(t, ok)
+
+	_, ok = models.CacheTTLPolicy{AliveTTL: time.Hour}.TTL(&models.Result{})
+	assert.False(t, ok, "dead results are not cached without DeadTTL")
+}
+
+func TestDefaultCachePolicyAndStats(t *testing.T) {
+	cache := NewDefaultCacheWithPolicy(models.CacheTTLPolicy{AliveTTL: time.Hour, DeadTTL: 20 * time.Millisecond})
+	defer cache.Close()
+	ctx := context.Background()
+
+	alive := &models.CProxyWithResult{Result: models.Result{Name: "alive", Delay: 100}}
+	dead := &models.CProxyWithResult{Result: models.Result{Name: "dead"}}
+	partial := &models.CProxyWithResult{Result: models.Result{Name: "partial", Delay: 100, Partial: true}}
+	require.NoError(t, cache.Set(ctx, "alive", alive))
+	require.NoError(t, cache.Set(ctx, "dead", dead))
+	require.NoError(t, cache.Set(ctx, "partial", partial))
+
+	_, ok := cache.Get(ctx, "partial")
+	assert.False(t, ok)
+	_, ok = cache.Get(ctx, "dead")
+	assert.True(t, ok)
+
+	time.Sleep(40 * time.Millisecond)
+	_, ok = cache.Get(ctx, "dead")
+	assert.False(t, ok)
+	_, ok = cache.Get(ctx, "alive")
+	assert.True(t, ok)
+
+	stats := cache.(models.CacheStatsReporter).Stats()
+	assert.Equal(t, models.CacheStats{Hits: 2, Misses: 2, Sets: 2, Skipped: 1}, stats)
+	assert.InDelta(t, 0.5, stats.HitRate(), 1e-9)
+}
+
+func TestFileCacheSkipsPartialResults(t *testing.T) {
+	cache, err := NewFileCacheWithPolicy(t.TempDir(), models.CacheTTLPolicy{AliveTTL: time.Hour}, 10)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	partial := cacheTestResult(t, "hk")
+	partial.Partial = true
+	require.NoError(t, cache.Set(ctx, "k", partial))
+	_, ok := cache.Get(ctx, "k")
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.Len())
+	assert.Equal(t, models.CacheStats{Misses: 1, Skipped: 1}, cache.Stats())
+}
+
+func TestTestCacheStatsPerRun(t *testing.T) {
+	cache := NewDefaultCache()
+	defer cache.Close()
+	ctx := context.Background()
+	cache.Get(ctx, "before")
+
+	tester, err := NewTest(models.Options{ConfigPath: "dummy.yaml", Cache: cache})
+	require.NoError(t, err)
+	_, err = tester.RunStream(ctx)
+	require.NoError(t, err)
+	tester.Stop()
+	cache.Get(ctx, "during")
+
+	stats, ok := tester.CacheStats()
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), stats.Misses)
+}
@@ -0,0 +1,220 @@
+package speedtest
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/metacubex/mihomo/adapter"
+	"github.com/metacubex/mihomo/component/proxydialer"
+	C "github.com/metacubex/mihomo/constant"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+// parseProxy 解析节点配置。配置中带有 models.ChainKey 时依次解析前置节点，
+// 每个节点经由上一个节点连接，返回的落地节点即整条链路
+func parseProxy(config map[string]any) (C.Proxy, error) {
+	chain := chainConfigs(config)
+	if len(chain) == 0 {
+		return adapter.ParseProxy(config)
+	}
+	var upstream C.Proxy
+	for _, hop := range append(slices.Clone(chain), config) {
+		var options []adapter.ProxyOption
+		if upstream != nil {
+			options = append(options, adapter.WithDialerForAPI(proxydialer.New(upstream, false)))
+		}
+		proxy, err := adapter.ParseProxy(hop, options...)
+		if err != nil {
+			return nil, fmt.Errorf("chain %s: proxy %v: %w", chainName(config), hop["name"], err)
+		}
+		upstream = proxy
+	}
+	return upstream, nil
+}
+
+// chainConfigs 读取节点配置中的前置节点，经 JSON/YAML 往返（如持久化缓存）后为 []any
+func chainConfigs(config map[string]any) []map[string]any {
+	switch chain := config[models.ChainKey].(type) {
+	case []map[string]any:
+		return chain
+	case []any:
+		out := make([]map[string]any, 0, len(chain))
+		for _, hop := range chain {
+			if m, ok := hop.(map[string]any); ok {
+				out = append(out, m)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// chainNames 链式节点经过的节点名称，按连接顺序排列，普通节点返回 nil
+func chainNames(config map[string]any) []string {
+	chain := chainConfigs(config)
+	if len(chain) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(chain)+1)
+	for _, hop := range append(slices.Clone(chain), config) {
+		names = append(names, fmt.Sprint(hop["name"]))
+	}
+	return names
+}
+
+// chainName 以 "a -> b -> c" 的形式展示链路
+func chainName(config map[string]any) string {
+	return strings.Join(chainNames(config), " -> ")
+}
+
+// proxyLookup 按名称查找配置中的节点，同名节点以先定义的为准
+type proxyLookup func(name string) (map[string]any, bool)
+
+// sliceLookup 在已解码的节点中按名称查找
+func sliceLookup(proxies []map[string]any) proxyLookup {
+	byName := make(map[string]map[string]any, len(proxies))
+	for _, proxy := range proxies {
+		name, _ := proxy["name"].(string)
+		if _, ok := byName[name]; name != "" && !ok {
+			byName[name] = proxy
+		}
+	}
+	return func(name string) (map[string]any, bool) {
+		proxy, ok := byName[name]
+		return proxy, ok
+	}
+}
+
+// chainResolver 按名称解析 dialer-proxy 与 relay 组引用的节点
+type chainResolver struct {
+	lookups []proxyLookup
+	groups  map[string]bool // 所在配置中的代理组名称，用于给出更明确的错误
+}
+
+// newChainResolver lookups 中靠前的优先，通常为所在配置的节点，其次为上层配置的节点
+func newChainResolver(lookups ...proxyLookup) *chainResolver {
+	return &chainResolver{lookups: lookups}
+}
+
+func (r *chainResolver) lookup(name string) (map[string]any, bool) {
+	for _, lookup := range r.lookups {
+		if proxy, ok := lookup(name); ok {
+			return proxy, true
+		}
+	}
+	return nil, false
+}
+
+// addGroups 登记代理组名称，dialer-proxy 引用代理组时 resolve 返回的错误会指明原因
+func (r *chainResolver) addGroups(groups []map[string]any) {
+	for _, group := range groups {
+		if name, _ := group["name"].(string); name != "" {
+			if r.groups == nil {
+				r.groups = make(map[string]bool)
+			}
+			r.groups[name] = true
+		}
+	}
+}
+
+// resolve 沿 dialer-proxy 找到所有前置节点，返回带有 models.ChainKey 的配置副本；
+// 没有 dialer-proxy 的节点原样返回，引用的节点不存在（如代理组）或循环引用时返回错误
+func (r *chainResolver) resolve(config map[string]any) (map[string]any, error) {
+	dialer := dialerProxy(config)
+	if dialer == "" {
+		return config, nil
+	}
+	name, _ := config["name"].(string)
+	visited := []string{name}
+	var chain []map[string]any
+	for dialer != "" {
+		if slices.Contains(visited, dialer) {
+			return config, fmt.Errorf("proxy %s: dialer-proxy cycle: %s -> %s", name, strings.Join(visited, " -> "), dialer)
+		}
+		upstream, ok := r.lookup(dialer)
+		if !ok && r.groups[dialer] {
+			return config, fmt.Errorf("proxy %s: dialer-proxy %s is a proxy group, only proxies can be chained", name, dialer)
+		}
+		if !ok {
+			return config, fmt.Errorf("proxy %s: dialer-proxy %s not found", name, dialer)
+		}
+		visited = append(visited, dialer)
+		hop := cloneConfig(upstream)
+		delete(hop, models.ChainKey)
+		chain = append([]map[string]any{hop}, chain...)
+		dialer = dialerProxy(upstream)
+	}
+	out := cloneConfig(config)
+	out[models.ChainKey] = chain
+	return out, nil
+}
+
+// relay 将 relay 组转换为以组名命名的链式节点：组内节点依次经由前一个节点连接，
+// 最后一个节点为落地节点，DIRECT 被忽略。mihomo 已移除 relay 组，链路改用 dialer-proxy 表示
+func (r *chainResolver) relay(group map[string]any) (map[string]any, error) {
+	name, _ := group["name"].(string)
+	var members []map[string]any
+	list, _ := group["proxies"].([]any)
+	for _, item := range list {
+		member := fmt.Sprint(item)
+		if isDirectProxy(member) {
+			continue
+		}
+		proxy, ok := r.lookup(member)
+		if !ok {
+			return nil, fmt.Errorf("relay %s: proxy %s not found", name, member)
+		}
+		members = append(members, proxy)
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("relay %s: no proxies", name)
+	}
+
+	// 第一个节点自身的 dialer-proxy 仍然生效
+	head, err := r.resolve(members[0])
+	if err != nil {
+		return nil, fmt.Errorf("relay %s: %w", name, err)
+	}
+	chain := slices.Clone(chainConfigs(head))
+	hop := cloneConfig(head)
+	delete(hop, models.ChainKey)
+	chain = append(chain, hop)
+	for _, member := range members[1:] {
+		hop := cloneConfig(member)
+		delete(hop, models.ChainKey)
+		hop["dialer-proxy"] = chain[len(chain)-1]["name"]
+		chain = append(chain, hop)
+	}
+
+	n := len(chain) - 1
+	landing := chain[n]
+	landing["name"] = name
+	if n > 0 {
+		landing[models.ChainKey] = chain[:n:n]
+	}
+	return landing, nil
+}
+
+// relays 转换配置中的所有 relay 组，其它类型的代理组被忽略
+func (r *chainResolver) relays(groups []map[string]any) ([]map[string]any, []error) {
+	var out []map[string]any
+	var errs []error
+	for _, group := range groups {
+		if typ, _ := group["type"].(string); typ != "relay" {
+			continue
+		}
+		proxy, err := r.relay(group)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		out = append(out, proxy)
+	}
+	return out, errs
+}
+
+func dialerProxy(config map[string]any) string {
+	dialer, _ := config["dialer-proxy"].(string)
+	return dialer
+}
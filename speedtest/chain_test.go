@@ -0,0 +1,211 @@
+package speedtest
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	C "github.com/metacubex/mihomo/constant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+func ssConfig(name, dialer string) map[string]any {
+	config := map[string]any{
+		"name": name, "type": "ss", "server": "127.0.0.1", "port": 8388, "cipher": "aes-128-gcm", "password": "pass",
+	}
+	if dialer != "" {
+		config["dialer-proxy"] = dialer
+	}
+	return config
+}
+
+func TestChainResolver(t *testing.T) {
+	proxies := []map[string]any{
+		ssConfig("a", ""),
+		ssConfig("b", "a"),
+		ssConfig("c", "b"),
+		ssConfig("d", "group"),
+		ssConfig("e", "f"),
+		ssConfig("f", "e"),
+	}
+	r := newChainResolver(sliceLookup(proxies))
+
+	out, err := r.resolve(proxies[0])
+	require.NoError(t, err)
+	assert.Nil(t, chainNames(out))
+
+	out, err = r.resolve(proxies[2])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, chainNames(out))
+	assert.NotContains(t, proxies[2], models.ChainKey, "original config must not be modified")
+
+	_, err = r.resolve(proxies[3])
+	assert.ErrorContains(t, err, "dialer-proxy group not found")
+	_, err = r.resolve(proxies[4])
+	assert.ErrorContains(t, err, "dialer-proxy cycle: e -> f -> e")
+
+	relays, errs := r.relays([]map[string]any{
+		{"name": "r", "type": "relay", "proxies": []any{"DIRECT", "b", "a"}},
+		{"name": "s", "type": "select", "proxies": []any{"a"}},
+		{"name": "broken", "type": "relay", "proxies": []any{"a", "missing"}},
+	})
+	require.Len(t, relays, 1)
+	require.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "relay broken: proxy missing not found")
+	// b 自身的 dialer-proxy 仍然生效，落地节点以组名命名并经由 b 连接
+	assert.Equal(t, []string{"a", "b", "r"}, chainNames(relays[0]))
+	assert.Equal(t, "b", relays[0]["dialer-proxy"])
+}
+
+func TestProxyIdentityIncludesChain(t *testing.T) {
+	landing := ssConfig("landing", "transit")
+	viaA, err := newChainResolver(sliceLookup([]map[string]any{ssConfig("transit", "")})).resolve(landing)
+	require.NoError(t, err)
+	other := ssConfig("transit", "")
+	other["server"] = "127.0.0.9"
+	viaB, err := newChainResolver(sliceLookup([]map[string]any{other})).resolve(landing)
+	require.NoError(t, err)
+
+	// 落地节点与 dialer-proxy 名称相同，前置节点不同
+	assert.NotEqual(t, ProxyIdentity(viaA), ProxyIdentity(viaB))
+	assert.NotEqual(t, ProxyIdentity(landing), ProxyIdentity(viaA))
+
+	// 改名与经 JSON 往返后标识不变
+	renamed := cloneConfig(viaA)
+	renamed["name"] = "renamed"
+	renamed[models.ChainKey] = []any{cloneConfig(chainConfigs(viaA)[0])}
+	assert.Equal(t, ProxyIdentity(viaA), ProxyIdentity(renamed))
+
+	deduper := newProxyDeduper(models.DedupFirst, nil)
+	_, ok := deduper.admit(viaA, nil)
+	assert.True(t, ok)
+	_, ok = deduper.admit(viaB, nil)
+	assert.True(t, ok, "chains through different hops are not duplicates")
+	_, ok = deduper.admit(renamed, nil)
+	assert.False(t, ok)
+}
+
+// connectRecorder 记录 CONNECT 请求的目标地址后返回 502 的 HTTP 代理
+func connectRecorder(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	targets := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		targets <- req.Host
+		_, _ = conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n"))
+	}()
+	return ln.Addr().String(), targets
+}
+
+func TestParseProxyChain(t *testing.T) {
+	addr, targets := connectRecorder(t)
+	host, port, _ := net.SplitHostPort(addr)
+	proxies := []map[string]any{
+		{"name": "transit", "type": "http", "server": host, "port": port},
+		{"name": "landing", "type": "socks5", "server": "192.0.2.1", "port": 1080, "dialer-proxy": "transit"},
+	}
+	config, err := newChainResolver(sliceLookup(proxies)).resolve(proxies[1])
+	require.NoError(t, err)
+	proxy, err := parseProxy(config)
+	require.NoError(t, err)
+	assert.Equal(t, C.Socks5, proxy.Type())
+	assert.Equal(t, "landing", proxy.Name())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = proxy.DialContext(ctx, &C.Metadata{NetWork: C.TCP, Host: "example.com", DstPort: 80})
+	assert.Error(t, err)
+	select {
+	case target := <-targets:
+		// 落地节点经由前置节点连接
+		assert.Equal(t, "192.0.2.1:1080", target)
+	case <-ctx.Done():
+		t.Fatal("landing proxy did not dial through the transit proxy")
+	}
+
+	// 经持久化缓存的 JSON 往返后前置节点为 []any
+	roundTrip := cloneConfig(config)
+	roundTrip[models.ChainKey] = []any{map[string]any(config[models.ChainKey].([]map[string]any)[0])}
+	assert.Equal(t, []string{"transit", "landing"}, chainNames(roundTrip))
+
+	result := exportConfig(&models.CProxyWithResult{Proxy: models.CProxy{SecretConfig: config}}, models.AnnotationNone)
+	assert.NotContains(t, result, models.ChainKey)
+	assert.Equal(t, "transit", result["dialer-proxy"])
+}
+
+func TestProxySourceLoaderResolvesChains(t *testing.T) {
+	provider := writeProxyConfig(t, "p1")
+	body := `proxies:
+  - {name: landing, type: ss, server: 127.0.0.2, port: 8388, cipher: aes-128-gcm, password: pass, dialer-proxy: transit}
+  - {name: transit, type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}
+  - {name: orphan, type: ss, server: 127.0.0.3, port: 8388, cipher: aes-128-gcm, password: pass, dialer-proxy: auto}
+proxy-groups:
+  - {name: auto, type: url-test, proxies: [transit, landing]}
+  - {name: chain, type: relay, proxies: [transit, orphan]}
+proxy-providers:
+  paid:
+    type: file
+    path: ` + provider + `
+    dialer-proxy: transit
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(body), 0644))
+
+	loader := &ProxySourceLoader{reports: newSourceReports()}
+	chains := map[string]string{}
+	err := loader.LoadStream(context.Background(), path, 10, func(batch []map[string]any) error {
+		for _, proxy := range batch {
+			chains[proxy["name"].(string)] = chainName(proxy)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"landing": "transit -> landing",
+		"transit": "",
+		"chain":   "transit -> chain",
+		"p1":      "transit -> p1", // provider 节点可以引用所在配置中的节点
+	}, chains)
+
+	reports := loader.reports.list()
+	require.Len(t, reports, 2)
+	assert.Equal(t, 3, reports[0].Parsed)
+	assert.Equal(t, 0, reports[0].Excluded)
+	assert.Equal(t, 2, reports[0].Chains)
+	assert.Equal(t, 1, reports[1].Chains)
+	// 引用代理组的 dialer-proxy 无法解析，节点被丢弃并记录原因
+	assert.Equal(t, []string{"proxy orphan: dialer-proxy auto is a proxy group, only proxies can be chained"}, reports[0].ChainErrors)
+	assert.Empty(t, reports[1].ChainErrors)
+
+	// 非流式解析（JSON 配置）结果相同
+	jsonBody := `{"proxies": [
+  {"name": "landing", "type": "ss", "server": "127.0.0.2", "port": 8388, "cipher": "aes-128-gcm", "password": "pass", "dialer-proxy": "transit"},
+  {"name": "transit", "type": "ss", "server": "127.0.0.1", "port": 8388, "cipher": "aes-128-gcm", "password": "pass"}
+], "proxy-groups": [{"name": "chain", "type": "relay", "proxies": ["transit", "landing"]}]}`
+	proxies, err := (&ProxySourceLoader{}).parse(context.Background(), []byte(jsonBody))
+	require.NoError(t, err)
+	var got []string
+	for _, proxy := range proxies {
+		got = append(got, proxy["name"].(string)+"="+chainName(proxy))
+	}
+	assert.Equal(t, "landing=transit -> landing,transit=,chain=transit -> chain", strings.Join(got, ","))
+}
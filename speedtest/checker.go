@@ -4,6 +4,7 @@ import (
 	"context"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 
 	C "github.com/metacubex/mihomo/constant"
 	"github.com/xiecang/speedtest-clash/speedtest/check"
@@ -22,11 +23,12 @@ var (
 	}
 )
 
-func checkProxy(ctx context.Context, proxy C.Proxy, types []models.CheckType, logger *slog.Logger) []models.CheckResult {
+// checkProxy 并发执行解锁检测，failed 为请求失败（无法得出结论）的检测数
+func checkProxy(ctx context.Context, proxy C.Proxy, types []models.CheckType, logger *slog.Logger) (res []models.CheckResult, failed int) {
 	var (
-		res []models.CheckResult
-		ch  = make(chan models.CheckResult, len(types))
-		wg  sync.WaitGroup
+		ch      = make(chan models.CheckResult, len(types))
+		wg      sync.WaitGroup
+		failedN atomic.Int32
 	)
 	logger = resolveLogger(logger)
 	for _, checkType := range types {
@@ -36,6 +38,7 @@ func checkProxy(ctx context.Context, proxy C.Proxy, types []models.CheckType, lo
 				defer wg.Done()
 				r, err := f.Check(ctx, proxy)
 				if err != nil {
+					failedN.Add(1)
 					logger.Debug("proxy check failed",
 						slog.String("proxy_name", proxy.Name()),
 						slog.String("proxy_addr", proxy.Addr()),
@@ -54,5 +57,5 @@ func checkProxy(ctx context.Context, proxy C.Proxy, types []models.CheckType, lo
 	for r := range ch {
 		res = append(res, r)
 	}
-	return res
+	return res, int(failedN.Load())
 }
@@ -0,0 +1,442 @@
+package speedtest
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	C "github.com/metacubex/mihomo/constant"
+)
+
+// configFilter 按节点配置字段过滤节点的表达式，在解析为 mihomo 节点之前求值。语法：
+//
+//	type in (hysteria2, tuic)          字段等于列表中的任一值
+//	port not in (80, 443)              字段不等于列表中的任何值
+//	server != "*.cn"                   == 与 != 忽略大小写，值中的 * 为通配符
+//	server !~ "\.cn$"                  =~ 与 !~ 为正则匹配
+//	port >= 1024                       < <= > >= 按数值比较，非数值时不匹配
+//	udp                                只写字段名时判断字段是否为真（true、非零数值或非空字符串）
+//	ws-opts.path =~ "^/ray"            以 . 访问嵌套字段
+//
+// 条件之间可用 &&/and、||/or、!/not 与括号组合。字段取自节点配置（SecretConfig），type 为配置中的节点类型，
+// 如 ss、ssr、vmess、hysteria2，不区分大小写；直接通过 AddProxy 添加且没有配置的节点按 configType 换算为同样的名称。
+// 不存在的字段除 != 与 not in 外均不匹配。
+// 值可以是不含空白与运算符的裸词，或以双引号、单引号引用的字符串，引用中 \ 只转义引号与 \ 本身
+type configFilter struct {
+	expr filterNode
+}
+
+// adapterConfigTypes 配置中的类型名称与 mihomo 节点类型名称的小写不同的节点类型
+var adapterConfigTypes = map[C.AdapterType]string{
+	C.Shadowsocks:  "ss",
+	C.ShadowsocksR: "ssr",
+}
+
+// configType 返回 mihomo 节点类型在配置中的类型名称，如 Shadowsocks 为 ss、Hysteria2 为 hysteria2
+func configType(tp C.AdapterType) string {
+	if name, ok := adapterConfigTypes[tp]; ok {
+		return name
+	}
+	return strings.ToLower(tp.String())
+}
+
+// newConfigFilter 解析过滤表达式，表达式为空时返回 nil，nil 过滤器接受所有节点
+func newConfigFilter(source string) (*configFilter, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, nil
+	}
+	tokens, err := lexFilter(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at offset %d", tok, tok.pos)
+	}
+	return &configFilter{expr: expr}, nil
+}
+
+// match 节点配置是否满足表达式
+func (f *configFilter) match(config map[string]any) bool {
+	if f == nil {
+		return true
+	}
+	return f.expr.match(config)
+}
+
+type filterNode interface {
+	match(config map[string]any) bool
+}
+
+type filterAnd struct{ left, right filterNode }
+
+func (n filterAnd) match(config map[string]any) bool {
+	return n.left.match(config) && n.right.match(config)
+}
+
+type filterOr struct{ left, right filterNode }
+
+func (n filterOr) match(config map[string]any) bool {
+	return n.left.match(config) || n.right.match(config)
+}
+
+type filterNot struct{ node filterNode }
+
+func (n filterNot) match(config map[string]any) bool { return !n.node.match(config) }
+
+// filterTruthy 只写字段名的条件
+type filterTruthy struct{ field string }
+
+func (n filterTruthy) match(config map[string]any) bool {
+	switch v := lookupField(config, n.field).(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		b, err := strconv.ParseBool(v)
+		return v != "" && (err != nil || b)
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	default:
+		return true
+	}
+}
+
+// filterCompare 字段与值的比较，in/not in 的 values 为列表，其它运算符只有一个值
+type filterCompare struct {
+	field  string
+	op     string
+	values []string
+	re     *regexp.Regexp
+}
+
+func (n filterCompare) match(config map[string]any) bool {
+	raw := lookupField(config, n.field)
+	if raw == nil {
+		return n.op == "!=" || n.op == "not in"
+	}
+	actual := fmt.Sprint(raw)
+	switch n.op {
+	case "==":
+		return filterEqual(actual, n.values[0])
+	case "!=":
+		return !filterEqual(actual, n.values[0])
+	case "in":
+		return filterContains(actual, n.values)
+	case "not in":
+		return !filterContains(actual, n.values)
+	case "=~":
+		return n.re.MatchString(actual)
+	case "!~":
+		return !n.re.MatchString(actual)
+	}
+	a, errA := strconv.ParseFloat(actual, 64)
+	b, errB := strconv.ParseFloat(n.values[0], 64)
+	if errA != nil || errB != nil {
+		return false
+	}
+	switch n.op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+// filterEqual 忽略大小写比较，数值按数值比较（"443" 与 443.0 相等），值中含 * 时按通配符匹配
+func filterEqual(actual, want string) bool {
+	if strings.Contains(want, "*") {
+		ok, _ := path.Match(strings.ToLower(want), strings.ToLower(actual))
+		return ok
+	}
+	if a, err := strconv.ParseFloat(actual, 64); err == nil {
+		if b, err := strconv.ParseFloat(want, 64); err == nil {
+			return a == b
+		}
+	}
+	return strings.EqualFold(actual, want)
+}
+
+func filterContains(actual string, values []string) bool {
+	for _, want := range values {
+		if filterEqual(actual, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupField 读取字段，字段名本身不存在时按 . 访问嵌套字段
+func lookupField(config map[string]any, field string) any {
+	if v, ok := config[field]; ok {
+		return v
+	}
+	keys := strings.Split(field, ".")
+	m := config
+	for _, key := range keys[:len(keys)-1] {
+		if m = cfgMap(m, key); m == nil {
+			return nil
+		}
+	}
+	return m[keys[len(keys)-1]]
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOp
+)
+
+type filterToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t filterToken) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return strconv.Quote(t.text)
+	default:
+		return "'" + t.text + "'"
+	}
+}
+
+// filterOps 运算符，较长的在前
+var filterOps = []string{"&&", "||", "==", "!=", "=~", "!~", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func lexFilter(source string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(source) && rune(source[j]) != c; j++ {
+				if source[j] == '\\' && j+1 < len(source) && (rune(source[j+1]) == c || source[j+1] == '\\') {
+					j++
+				}
+				b.WriteByte(source[j])
+			}
+			if j >= len(source) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, filterToken{kind: tokenString, text: b.String(), pos: i})
+			i = j + 1
+		default:
+			if op := matchFilterOp(source[i:]); op != "" {
+				tokens = append(tokens, filterToken{kind: tokenOp, text: op, pos: i})
+				i += len(op)
+				continue
+			}
+			j := i
+			for j < len(source) && !unicode.IsSpace(rune(source[j])) && !strings.ContainsRune(`"'&|=!<>()[],`, rune(source[j])) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q at offset %d", source[i], i)
+			}
+			tokens = append(tokens, filterToken{kind: tokenWord, text: source[i:j], pos: i})
+			i = j
+		}
+	}
+	return append(tokens, filterToken{kind: tokenEOF, pos: len(source)}), nil
+}
+
+func matchFilterOp(s string) string {
+	for _, op := range filterOps {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept 下一个记号为给定的运算符或关键字（不区分大小写）时消费并返回 true
+func (p *filterParser) accept(texts ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokenOp && tok.kind != tokenWord {
+		return false
+	}
+	for _, text := range texts {
+		if strings.EqualFold(tok.text, text) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||", "or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&", "and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.accept("!", "not") {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{node}, nil
+	}
+	if p.accept("(") {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.unexpected("')'")
+		}
+		return node, nil
+	}
+	return p.parseCondition()
+}
+
+func (p *filterParser) parseCondition() (filterNode, error) {
+	field := p.next()
+	if field.kind != tokenWord {
+		return nil, fmt.Errorf("expected field name at offset %d, got %s", field.pos, field)
+	}
+	switch {
+	case p.accept("in"):
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return filterCompare{field: field.text, op: "in", values: values}, nil
+	case p.peek().kind == tokenWord && strings.EqualFold(p.peek().text, "not") &&
+		p.tokens[p.pos+1].kind == tokenWord && strings.EqualFold(p.tokens[p.pos+1].text, "in"):
+		p.pos += 2
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return filterCompare{field: field.text, op: "not in", values: values}, nil
+	}
+	op := p.peek()
+	if op.kind != tokenOp || !strings.Contains(" == != =~ !~ < <= > >= ", " "+op.text+" ") {
+		return filterTruthy{field: field.text}, nil
+	}
+	p.pos++
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	node := filterCompare{field: field.text, op: op.text, values: []string{value}}
+	if op.text == "=~" || op.text == "!~" {
+		if node.re, err = regexp.Compile(value); err != nil {
+			return nil, fmt.Errorf("invalid regexp for %s: %w", field.text, err)
+		}
+	}
+	return node, nil
+}
+
+// parseList 解析 (a, b) 或 [a, b]，也接受单个值
+func (p *filterParser) parseList() ([]string, error) {
+	closing := ""
+	switch {
+	case p.accept("("):
+		closing = ")"
+	case p.accept("["):
+		closing = "]"
+	default:
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return []string{value}, nil
+	}
+	var values []string
+	for !p.accept(closing) {
+		if len(values) > 0 && !p.accept(",") {
+			return nil, p.unexpected("',' or '" + closing + "'")
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("empty list at offset %d", p.tokens[p.pos-1].pos)
+	}
+	return values, nil
+}
+
+func (p *filterParser) parseValue() (string, error) {
+	tok := p.next()
+	if tok.kind != tokenWord && tok.kind != tokenString {
+		return "", fmt.Errorf("expected value at offset %d, got %s", tok.pos, tok)
+	}
+	return tok.text, nil
+}
+
+func (p *filterParser) unexpected(want string) error {
+	tok := p.peek()
+	return fmt.Errorf("expected %s at offset %d, got %s", want, tok.pos, tok)
+}
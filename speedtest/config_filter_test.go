@@ -0,0 +1,120 @@
+package speedtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	C "github.com/metacubex/mihomo/constant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+func TestConfigFilter(t *testing.T) {
+	hy2 := map[string]any{"name": "hy2", "type": "hysteria2", "server": "hk.example.com", "port": 443, "udp": true}
+	tuic := map[string]any{"name": "tuic", "type": "tuic", "server": "sh.example.cn", "port": "8443"}
+	vmess := map[string]any{"name": "vmess", "type": "vmess", "server": "1.2.3.4", "port": 80.0, "udp": "false",
+		"ws-opts": map[string]any{"path": "/ray", "headers": map[string]any{"Host": "cdn.example.com"}}}
+	all := []map[string]any{hy2, tuic, vmess}
+
+	for _, tc := range []struct {
+		expr string
+		want []string
+	}{
+		{expr: "", want: []string{"hy2", "tuic", "vmess"}},
+		{expr: "type in (hysteria2, TUIC)", want: []string{"hy2", "tuic"}},
+		{expr: "type == Hysteria2", want: []string{"hy2"}},
+		{expr: "port not in [80, 443]", want: []string{"tuic"}},
+		{expr: `server != "*.cn"`, want: []string{"hy2", "vmess"}},
+		{expr: `server !~ "\.cn$"`, want: []string{"hy2", "vmess"}},
+		{expr: "udp", want: []string{"hy2"}},
+		{expr: "!udp", want: []string{"tuic", "vmess"}},
+		{expr: "port >= 443 and port < 8443", want: []string{"hy2"}},
+		{expr: "ws-opts.path =~ '^/ray' || ws-opts.headers.Host == cdn.example.com", want: []string{"vmess"}},
+		{expr: "sni != x", want: []string{"hy2", "tuic", "vmess"}},
+		{expr: "sni == x", want: nil},
+		{expr: "not (type == vmess or udp) && port > 100", want: []string{"tuic"}},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			filter, err := newConfigFilter(tc.expr)
+			require.NoError(t, err)
+			var got []string
+			for _, config := range all {
+				if filter.match(config) {
+					got = append(got, config["name"].(string))
+				}
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	for _, expr := range []string{
+		"type ==",
+		"type in ()",
+		"(udp",
+		"udp udp",
+		`server == "cn`,
+		"server =~ '('",
+		"== 1",
+	} {
+		_, err := newConfigFilter(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestFilterCounted(t *testing.T) {
+	_, err := NewTest(models.Options{Filter: "type in ("})
+	assert.ErrorContains(t, err, "Filter")
+
+	cache := &namedCache{results: map[string]models.Result{"a": {Delay: 100}, "c": {Delay: 100}}}
+	tester, err := NewTest(models.Options{Cache: cache, Timeout: time.Second, Filter: "port != 443"})
+	require.NoError(t, err)
+	defer tester.Close()
+
+	resultsCh, err := tester.RunStream(context.Background())
+	require.NoError(t, err)
+	err = tester.AddProxies(context.Background(), []map[string]any{
+		{"name": "a", "type": "ss", "server": "1.1.1.1", "port": 8388, "cipher": "aes-128-gcm", "password": "pass"},
+		{"name": "b", "type": "ss", "server": "2.2.2.2", "port": 443, "cipher": "aes-128-gcm", "password": "pass"},
+		{"name": "c", "type": "ss", "server": "3.3.3.3", "port": "8443", "cipher": "aes-128-gcm", "password": "pass"},
+	})
+	require.NoError(t, err)
+	tester.CloseInput()
+
+	var names []string
+	for result := range resultsCh {
+		names = append(names, result.Name)
+	}
+	assert.ElementsMatch(t, []string{"a", "c"}, names)
+	assert.Equal(t, int32(1), tester.FilteredCount())
+	assert.Equal(t, int32(3), tester.ProcessCount())
+}
+
+func TestConfigFilterTypeVocabulary(t *testing.T) {
+	ss := map[string]any{"name": "ss", "type": "ss", "server": "127.0.0.1", "port": 8388, "cipher": "aes-128-gcm", "password": "pass"}
+	ssr := map[string]any{"name": "ssr", "type": "ssr", "server": "127.0.0.1", "port": 8388, "cipher": "aes-128-cfb",
+		"password": "pass", "obfs": "plain", "protocol": "origin"}
+
+	tester, err := NewTest(models.Options{Filter: "type in (ss, ssr)"})
+	require.NoError(t, err)
+	defer tester.Close()
+	for _, config := range []map[string]any{ss, ssr} {
+		// 预解析时按配置中的类型过滤
+		_, ok := tester.admitConfig(config, nil)
+		assert.True(t, ok, config["name"])
+
+		// AddProxy 添加的节点没有配置时换算为相同的类型名称
+		proxy, err := parseProxy(config)
+		require.NoError(t, err)
+		assert.True(t, tester.proxyShouldKeep(models.CProxy{Proxy: proxy}), config["name"])
+	}
+
+	tester, err = NewTest(models.Options{Filter: "type == shadowsocks"})
+	require.NoError(t, err)
+	defer tester.Close()
+	proxy, err := parseProxy(ss)
+	require.NoError(t, err)
+	assert.False(t, tester.proxyShouldKeep(models.CProxy{Proxy: proxy}))
+	assert.Equal(t, "hysteria2", configType(C.Hysteria2))
+}
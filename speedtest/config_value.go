@@ -0,0 +1,99 @@
+package speedtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 以下辅助函数用于读取 mihomo 节点配置中的弱类型字段，
+// 配置可能来自 YAML（int/bool）、JSON（float64）或分享链接（string）。
+
+func cfgString(config map[string]any, key string) string {
+	switch v := config[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func cfgInt(config map[string]any, key string) int {
+	switch v := config[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case uint16:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(strings.TrimSpace(v))
+		return n
+	default:
+		return 0
+	}
+}
+
+func cfgBool(config map[string]any, key string) bool {
+	switch v := config[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	default:
+		return false
+	}
+}
+
+func cfgMap(config map[string]any, key string) map[string]any {
+	switch v := config[key].(type) {
+	case map[string]any:
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = item
+		}
+		return m
+	default:
+		return nil
+	}
+}
+
+// cfgStrings 读取字符串列表，兼容单个字符串与逗号分隔写法
+func cfgStrings(config map[string]any, key string) []string {
+	switch v := config[key].(type) {
+	case []string:
+		return v
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			out = append(out, fmt.Sprint(item))
+		}
+		return out
+	case string:
+		if v == "" {
+			return nil
+		}
+		return strings.Split(v, ",")
+	default:
+		return nil
+	}
+}
+
+// cfgFirstString 读取列表字段的第一个值，如 h2-opts.host、http-opts.path
+func cfgFirstString(config map[string]any, key string) string {
+	if values := cfgStrings(config, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,197 @@
+package speedtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+// csvColumn CSV 列定义，表头分中英文两种
+type csvColumn struct {
+	zh, en string
+	value  func(r *models.CProxyWithResult) string
+}
+
+func (c csvColumn) header(lang string) string {
+	if lang == "en" {
+		return c.en
+	}
+	return c.zh
+}
+
+func formatMillis(v uint16) string {
+	return strconv.Itoa(int(v))
+}
+
+var csvColumns = map[string]csvColumn{
+	"name":    {"节点", "Name", func(r *models.CProxyWithResult) string { return r.Name }},
+	"type":    {"类型", "Type", resultType},
+	"server":  {"服务器", "Server", resultServer},
+	"alive":   {"有效", "Alive", func(r *models.CProxyWithResult) string { return strconv.FormatBool(r.Alive()) }},
+	"country": {"国家", "Country", func(r *models.CProxyWithResult) string { return r.Country }},
+	"bandwidth": {"带宽 (MB/s)", "Bandwidth (MB/s)", func(r *models.CProxyWithResult) string {
+		return fmt.Sprintf("%.2f", r.Bandwidth/(1024*1024))
+	}},
+	"ttfb": {"首字节时间 (ms)", "TTFB (ms)", func(r *models.CProxyWithResult) string {
+		return strconv.FormatInt(r.TTFB.Milliseconds(), 10)
+	}},
+	"delay":  {"延迟 (ms)", "Delay (ms)", func(r *models.CProxyWithResult) string { return formatMillis(r.Delay) }},
+	"p50":    {"P50 (ms)", "P50 (ms)", func(r *models.CProxyWithResult) string { return formatMillis(r.DelayP50) }},
+	"p90":    {"P90 (ms)", "P90 (ms)", func(r *models.CProxyWithResult) string { return formatMillis(r.DelayP90) }},
+	"p95":    {"P95 (ms)", "P95 (ms)", func(r *models.CProxyWithResult) string { return formatMillis(r.DelayP95) }},
+	"jitter": {"抖动 (ms)", "Jitter (ms)", func(r *models.CProxyWithResult) string { return formatMillis(r.Jitter) }},
+	"loss": {"丢包率 (%)", "Loss (%)", func(r *models.CProxyWithResult) string {
+		return fmt.Sprintf("%.1f", r.LossRate*100)
+	}},
+	"test_duration": {"测试耗时 (ms)", "Test Duration (ms)", func(r *models.CProxyWithResult) string {
+		return strconv.FormatInt(r.TestDuration.Milliseconds(), 10)
+	}},
+	"download_bytes": {"下载量 (B)", "Downloaded (B)", func(r *models.CProxyWithResult) string {
+		return strconv.FormatInt(r.DownloadBytes, 10)
+	}},
+	"source": {"来源", "Source", func(r *models.CProxyWithResult) string { return r.Source.String() }},
+}
+
+var defaultCSVColumns = []string{"name", "bandwidth", "ttfb", "delay"}
+
+func checkColumn(tp models.CheckType) csvColumn {
+	return csvColumn{"解锁 " + string(tp), "Check " + string(tp), func(r *models.CProxyWithResult) string {
+		for _, c := range r.CheckResults {
+			if c.Type != tp {
+				continue
+			}
+			if c.OK {
+				return "✅" + c.Value
+			}
+			return "❌" + c.Value
+		}
+		return ""
+	}}
+}
+
+func urlColumn(url string) csvColumn {
+	return csvColumn{"链接 " + url, "URL " + url, func(r *models.CProxyWithResult) string {
+		ok, tested := r.URLForTest[url]
+		switch {
+		case !tested:
+			return ""
+		case ok:
+			return "✅"
+		default:
+			return "❌"
+		}
+	}}
+}
+
+// CSVExporter 导出 CSV 表格，列与表头语言可通过 CSVOptions 配置
+type CSVExporter struct {
+	models.CSVOptions
+}
+
+// ExportsDead 是否需要导出无效节点
+func (e *CSVExporter) ExportsDead() bool {
+	return e.IncludeDead
+}
+
+func (e *CSVExporter) Export(w io.Writer, results []models.CProxyWithResult) error {
+	lang := strings.ToLower(e.Language)
+	if lang != "" && lang != "zh" && lang != "en" {
+		return fmt.Errorf("unsupported csv language: %s", e.Language)
+	}
+	columns, err := e.columns(results)
+	if err != nil {
+		return err
+	}
+	if !e.NoBOM {
+		if _, err := io.WriteString(w, "\xEF\xBB\xBF"); err != nil {
+			return err
+		}
+	}
+
+	csvWriter := csv.NewWriter(w)
+	line := make([]string, len(columns))
+	for i, column := range columns {
+		line[i] = column.header(lang)
+	}
+	if err = csvWriter.Write(line); err != nil {
+		return err
+	}
+	for _, result := range results {
+		for i, column := range columns {
+			line[i] = column.value(&result)
+		}
+		if err = csvWriter.Write(line); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// columns 解析列配置，checks 与 url_tests 按结果中出现的检测类型与 URL 展开
+func (e *CSVExporter) columns(results []models.CProxyWithResult) ([]csvColumn, error) {
+	names := e.Columns
+	if len(names) == 0 {
+		names = defaultCSVColumns
+	}
+	columns := make([]csvColumn, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "checks":
+			for _, tp := range resultCheckTypes(results) {
+				columns = append(columns, checkColumn(tp))
+			}
+		case name == "url_tests":
+			for _, url := range resultTestURLs(results) {
+				columns = append(columns, urlColumn(url))
+			}
+		case strings.HasPrefix(name, "check:"):
+			columns = append(columns, checkColumn(models.CheckType(strings.TrimPrefix(name, "check:"))))
+		case strings.HasPrefix(name, "url:"):
+			columns = append(columns, urlColumn(strings.TrimPrefix(name, "url:")))
+		default:
+			column, ok := csvColumns[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown csv column: %s", name)
+			}
+			columns = append(columns, column)
+		}
+	}
+	return columns, nil
+}
+
+func resultCheckTypes(results []models.CProxyWithResult) []models.CheckType {
+	seen := make(map[models.CheckType]struct{})
+	var types []models.CheckType
+	for _, r := range results {
+		for _, c := range r.CheckResults {
+			if _, ok := seen[c.Type]; !ok {
+				seen[c.Type] = struct{}{}
+				types = append(types, c.Type)
+			}
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+func resultTestURLs(results []models.CProxyWithResult) []string {
+	seen := make(map[string]struct{})
+	var urls []string
+	for _, r := range results {
+		for url := range r.URLForTest {
+			if _, ok := seen[url]; !ok {
+				seen[url] = struct{}{}
+				urls = append(urls, url)
+			}
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
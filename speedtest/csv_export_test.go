@@ -0,0 +1,89 @@
+package speedtest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+func readCSV(t *testing.T, data []byte) [][]string {
+	t.Helper()
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func TestCSVExporterDefaultColumnsSeparateDelayAndTTFB(t *testing.T) {
+	results := []models.CProxyWithResult{{
+		Result: models.Result{Name: "hk", Bandwidth: 2 * 1024 * 1024, TTFB: 350 * time.Millisecond, Delay: 120},
+	}}
+	var buf bytes.Buffer
+	require.NoError(t, (&CSVExporter{}).Export(&buf, results))
+
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("\xEF\xBB\xBF")))
+	records := readCSV(t, bytes.TrimPrefix(buf.Bytes(), []byte("\xEF\xBB\xBF")))
+	assert.Equal(t, []string{"节点", "带宽 (MB/s)", "首字节时间 (ms)", "延迟 (ms)"}, records[0])
+	assert.Equal(t, []string{"hk", "2.00", "350", "120"}, records[1])
+}
+
+func TestCSVExporterColumnsExpandChecksAndURLs(t *testing.T) {
+	results := exporterResults()
+	results[0].CheckResults = []models.CheckResult{
+		models.NewCheckResult(models.CheckTypeNetflix, true, "HK"),
+		models.NewCheckResult(models.CheckTypeGPTWeb, false, ""),
+	}
+	results[0].URLForTest = map[string]bool{"https://a.example": true}
+	results[1].URLForTest = map[string]bool{"https://b.example": false}
+
+	exporter := &CSVExporter{CSVOptions: models.CSVOptions{
+		Columns:  []string{"name", "type", "server", "delay", "p90", "checks", "url_tests"},
+		Language: "en",
+		NoBOM:    true,
+	}}
+	var buf bytes.Buffer
+	require.NoError(t, exporter.Export(&buf, results))
+
+	records := readCSV(t, buf.Bytes())
+	assert.Equal(t, []string{
+		"Name", "Type", "Server", "Delay (ms)", "P90 (ms)",
+		"Check gpt_web", "Check netflix", "URL https://a.example", "URL https://b.example",
+	}, records[0])
+	assert.Equal(t, []string{"hk|01", "ss", "1.1.1.1:8388", "100", "130", "❌", "✅HK", "✅", ""}, records[1])
+	assert.Equal(t, []string{"us-01", "trojan", "2.2.2.2:443", "200", "0", "", "", "", "❌"}, records[2])
+}
+
+func TestCSVExporterRejectsUnknownColumn(t *testing.T) {
+	exporter := &CSVExporter{CSVOptions: models.CSVOptions{Columns: []string{"name", "nope"}}}
+	var buf bytes.Buffer
+	assert.Error(t, exporter.Export(&buf, exporterResults()))
+	assert.Zero(t, buf.Len())
+}
+
+func TestWriteToCsvIncludeDead(t *testing.T) {
+	tester, _ := newExportTester(t, models.ExportOptions{
+		CSV: models.CSVOptions{Columns: []string{"name", "alive"}, NoBOM: true, IncludeDead: true},
+	})
+	tester.results = append(tester.results, models.CProxyWithResult{Result: models.Result{Name: "dead"}})
+
+	var buf bytes.Buffer
+	require.NoError(t, tester.ExportFormat(&buf, "csv"))
+	assert.Equal(t, [][]string{{"节点", "有效"}, {"node", "true"}, {"dead", "false"}}, readCSV(t, buf.Bytes()))
+}
+
+func TestCSVExporterSourceColumn(t *testing.T) {
+	results := exporterResults()
+	results[0].Source = &models.ProxySource{URL: "https://a.example/sub", Provider: "paid", Index: 3}
+	exporter := &CSVExporter{CSVOptions: models.CSVOptions{Columns: []string{"name", "source"}, Language: "en", NoBOM: true}}
+	var buf bytes.Buffer
+	require.NoError(t, exporter.Export(&buf, results))
+
+	records := readCSV(t, buf.Bytes())
+	assert.Equal(t, []string{"Name", "Source"}, records[0])
+	assert.Equal(t, "paid (https://a.example/sub)", records[1][1])
+	assert.Equal(t, "", records[2][1])
+}
@@ -0,0 +1,105 @@
+package speedtest
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+// dedupEntry 同一身份节点中被保留的节点信息
+type dedupEntry struct {
+	name    string              // 最终保留的名称
+	config  map[string]any      // shortest_name 时名称最短的重复节点的配置，为空时即测速的节点
+	source  *models.ProxySource // config 对应节点的来源
+	sources []string            // 节点及其重复节点的来源（仅 merge_sources）
+}
+
+// proxyDeduper 按节点身份去重。节点入队测速后才可能出现名称更短的重复节点，
+// 因此 shortest_name 与 merge_sources 仅记录最终保留的节点与来源，测速完成后由 apply 应用到结果上
+type proxyDeduper struct {
+	policy  models.DedupPolicy
+	prepare func(config map[string]any) // 与测速节点相同的配置处理（如 ForceCertVerify），apply 替换配置时使用
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+}
+
+func newProxyDeduper(policy models.DedupPolicy, prepare func(config map[string]any)) *proxyDeduper {
+	if policy == models.DedupNone {
+		return nil
+	}
+	return &proxyDeduper{policy: policy, prepare: prepare, entries: make(map[string]*dedupEntry)}
+}
+
+// admit 登记节点并返回其身份，ok 为 false 表示该节点与已登记的节点重复，应丢弃。
+// 身份按登记时的配置计算，测速前的配置处理（如 ForceCertVerify）可能改变配置，apply 时应使用此处返回的身份
+func (d *proxyDeduper) admit(config map[string]any, source *models.ProxySource) (identity string, ok bool) {
+	if d == nil {
+		return "", true
+	}
+	identity = ProxyIdentity(config)
+	if identity == "" {
+		return "", true
+	}
+	name, _ := config["name"].(string)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, exists := d.entries[identity]
+	if !exists {
+		entry = &dedupEntry{name: name}
+		d.entries[identity] = entry
+	}
+	if d.policy == models.DedupMergeSources && source.String() != "" && !slices.Contains(entry.sources, source.String()) {
+		entry.sources = append(entry.sources, source.String())
+	}
+	// 名称更短的重复节点取代测速节点的名称、配置与来源，身份相同的节点测速结果相同
+	if exists && d.policy == models.DedupShortestName && len([]rune(name)) < len([]rune(entry.name)) {
+		entry.name, entry.config, entry.source = name, config, source
+	}
+	return identity, !exists
+}
+
+// apply 将最终保留的节点与合并后的来源应用到测速结果上
+func (d *proxyDeduper) apply(results []models.CProxyWithResult) {
+	if d == nil || d.policy == models.DedupFirst {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range results {
+		r := &results[i]
+		identity := r.Proxy.Identity
+		if identity == "" {
+			identity = ProxyIdentity(r.Proxy.SecretConfig)
+		}
+		entry, ok := d.entries[identity]
+		if !ok {
+			continue
+		}
+		if len(entry.sources) > 0 {
+			r.Sources = append([]string(nil), entry.sources...)
+		}
+		if entry.config != nil && entry.name != r.Name {
+			d.replaceResult(r, entry)
+		}
+	}
+}
+
+// replaceResult 以 shortest_name 保留的重复节点重建结果，测速数据保持不变
+func (d *proxyDeduper) replaceResult(r *models.CProxyWithResult, entry *dedupEntry) {
+	config := cloneConfig(entry.config)
+	if d.prepare != nil {
+		d.prepare(config)
+	}
+	proxy, err := parseProxy(config)
+	if err != nil {
+		return
+	}
+	r.Name = entry.name
+	r.Proxy.Proxy = proxy
+	r.Proxy.SecretConfig = config
+	r.Proxy.Source = entry.source
+	r.Source = entry.source
+	r.Chain = chainNames(config)
+}
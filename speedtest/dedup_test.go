@@ -0,0 +1,93 @@
+package speedtest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+func TestDedupPolicies(t *testing.T) {
+	first := writeProxyConfig(t, "hk-long-name", "hk-01")
+	second := writeProxyConfig(t, "hk")
+	configPath := first + "|" + second
+
+	for _, tc := range []struct {
+		policy  models.DedupPolicy
+		name    string
+		sources []string
+	}{
+		{policy: models.DedupFirst, name: "hk-long-name"},
+		{policy: models.DedupShortestName, name: "hk"},
+		{policy: models.DedupMergeSources, name: "hk-long-name", sources: []string{first, second}},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			cache := &countingCache{gets: map[string]int{}, result: models.Result{Delay: 100}}
+			tester, err := NewTest(models.Options{ConfigPath: configPath, Cache: cache, Dedup: tc.policy})
+			require.NoError(t, err)
+			defer tester.Close()
+
+			results, err := tester.TestSpeed(context.Background())
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Equal(t, tc.name, results[0].Name)
+			assert.Equal(t, tc.name, results[0].Proxy.Name())
+			assert.ElementsMatch(t, tc.sources, results[0].Sources)
+			require.NotNil(t, results[0].Proxy.Source, "source kept after rename")
+			// shortest_name 保留名称最短的节点的配置与来源
+			wantSource := first
+			if tc.policy == models.DedupShortestName {
+				wantSource = second
+			}
+			assert.Equal(t, wantSource, results[0].Proxy.Source.URL)
+			assert.Equal(t, wantSource, results[0].Source.URL)
+			assert.Equal(t, tc.name, results[0].Proxy.SecretConfig["name"])
+			assert.Equal(t, int32(2), tester.DroppedCount())
+			assert.Equal(t, int32(3), tester.TotalCount())
+			assert.Equal(t, int32(3), tester.ProcessCount())
+		})
+	}
+}
+
+func TestDedupShortestNameWithForceCertVerify(t *testing.T) {
+	body := `proxies:
+  - {name: trojan-long-name, type: trojan, server: 127.0.0.1, port: 443, password: p, skip-cert-verify: true}
+  - {name: t, type: trojan, server: 127.0.0.1, port: 443, password: p, skip-cert-verify: true}
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(body), 0644))
+
+	cache := &countingCache{gets: map[string]int{}, result: models.Result{Delay: 100}}
+	tester, err := NewTest(models.Options{ConfigPath: path, Cache: cache, Dedup: models.DedupShortestName, ForceCertVerify: true})
+	require.NoError(t, err)
+	defer tester.Close()
+
+	results, err := tester.TestSpeed(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	// ForceCertVerify 改变了测速节点的配置，去重仍按登记时的身份找到保留的节点
+	assert.Equal(t, "t", results[0].Name)
+	assert.Equal(t, false, results[0].Proxy.SecretConfig["skip-cert-verify"])
+	assert.Equal(t, 1, results[0].Proxy.Source.Index)
+}
+
+func TestDedupDisabledByDefault(t *testing.T) {
+	cache := &countingCache{gets: map[string]int{}, result: models.Result{Delay: 100}}
+	tester, err := NewTest(models.Options{ConfigPath: writeProxyConfig(t, "a", "b"), Cache: cache})
+	require.NoError(t, err)
+	defer tester.Close()
+
+	results, err := tester.TestSpeed(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Zero(t, tester.DroppedCount())
+}
+
+func TestNewTestRejectsUnknownDedupPolicy(t *testing.T) {
+	_, err := NewTest(models.Options{Dedup: "longest"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,45 @@
+package speedtest
+
+import (
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+// exportResults 返回导出用的结果，配置了 RenameTemplate 时按模板重命名
+func (t *Test) exportResults(results []models.CProxyWithResult) []models.CProxyWithResult {
+	return renameResults(results, t.options.RenameTemplate)
+}
+
+// exportConfigs 返回导出用的节点配置。配置均为深拷贝，不会修改 SecretConfig；
+// mode 为 AnnotationExtension 时测速结果写入命名空间字段 models.AnnotationKey。
+func (t *Test) exportConfigs(results []models.CProxyWithResult, mode models.AnnotationMode) []map[string]any {
+	var configs []map[string]any
+	for _, result := range t.exportResults(results) {
+		configs = append(configs, exportConfig(&result, mode))
+	}
+	return configs
+}
+
+// annotatedProxies 返回纯净配置及旁路注解
+func (t *Test) annotatedProxies(results []models.CProxyWithResult) []models.AnnotatedProxy {
+	var out []models.AnnotatedProxy
+	for _, result := range t.exportResults(results) {
+		out = append(out, models.AnnotatedProxy{
+			Config:     exportConfig(&result, models.AnnotationNone),
+			Annotation: models.NewProxyAnnotation(&result.Result),
+		})
+	}
+	return out
+}
+
+func exportConfig(result *models.CProxyWithResult, mode models.AnnotationMode) map[string]any {
+	config := cloneConfig(result.Proxy.SecretConfig)
+	if config == nil {
+		config = map[string]any{}
+	}
+	// 链路由 dialer-proxy 表示，前置节点不随落地节点导出
+	delete(config, models.ChainKey)
+	if mode == models.AnnotationExtension {
+		config[models.AnnotationKey] = models.NewProxyAnnotation(&result.Result)
+	}
+	return config
+}
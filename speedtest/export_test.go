@@ -0,0 +1,78 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+func newExportTester(t *testing.T, export models.ExportOptions) (*Test, map[string]any) {
+	t.Helper()
+	config := map[string]any{
+		"name": "node", "type": "ss", "server": "1.1.1.1", "port": 8388,
+		"ws-opts": map[string]any{"path": "/ws"},
+	}
+	tester, err := NewTest(models.Options{ConfigPath: "dummy.yaml", Export: export})
+	assert.NoError(t, err)
+	result := models.CProxyWithResult{
+		Result: models.Result{
+			Name:         "node",
+			Delay:        100,
+			CheckResults: []models.CheckResult{models.NewCheckResult(models.CheckTypeNetflix, true, "US")},
+		},
+		Proxy: models.CProxy{SecretConfig: config},
+	}
+	tester._testedSpeed = true
+	tester.results = []models.CProxyWithResult{result}
+	tester.aliveProxies = []models.CProxyWithResult{result}
+	return tester, config
+}
+
+func TestAliveProxiesDoesNotMutateSecretConfig(t *testing.T) {
+	tester, config := newExportTester(t, models.ExportOptions{})
+	cache := &DefaultCache{}
+	keyBefore := cache.GenerateKey(&tester.aliveProxies[0].Proxy)
+
+	proxies, err := tester.AliveProxies()
+	assert.NoError(t, err)
+	assert.Len(t, proxies, 1)
+	proxies[0]["name"] = "changed"
+	proxies[0]["ws-opts"].(map[string]any)["path"] = "/changed"
+
+	_, err = tester.AliveProxiesToJson()
+	assert.NoError(t, err)
+
+	assert.NotContains(t, config, "_check")
+	assert.NotContains(t, config, models.AnnotationKey)
+	assert.Equal(t, "node", config["name"])
+	assert.Equal(t, "/ws", config["ws-opts"].(map[string]any)["path"])
+	assert.Equal(t, keyBefore, cache.GenerateKey(&tester.aliveProxies[0].Proxy))
+}
+
+func TestAliveProxiesToJsonExtensionAnnotation(t *testing.T) {
+	tester, config := newExportTester(t, models.ExportOptions{JSONAnnotation: models.AnnotationExtension})
+
+	data, err := tester.AliveProxiesToJson()
+	assert.NoError(t, err)
+
+	var proxies []map[string]any
+	assert.NoError(t, json.Unmarshal(data, &proxies))
+	assert.Len(t, proxies, 1)
+	annotation, ok := proxies[0][models.AnnotationKey].(map[string]any)
+	assert.True(t, ok)
+	assert.Equal(t, float64(100), annotation["delay"])
+	assert.NotContains(t, config, models.AnnotationKey)
+}
+
+func TestAliveProxiesWithAnnotationKeepsConfigClean(t *testing.T) {
+	tester, _ := newExportTester(t, models.ExportOptions{})
+
+	proxies, err := tester.AliveProxiesWithAnnotation()
+	assert.NoError(t, err)
+	assert.Len(t, proxies, 1)
+	assert.NotContains(t, proxies[0].Config, models.AnnotationKey)
+	assert.Equal(t, uint16(100), proxies[0].Annotation.Delay)
+	assert.Len(t, proxies[0].Annotation.CheckResults, 1)
+}
@@ -0,0 +1,465 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Exporter 将测速结果导出到 io.Writer
+type Exporter interface {
+	Export(w io.Writer, results []models.CProxyWithResult) error
+}
+
+// ExporterFunc 允许普通函数作为 Exporter 使用
+type ExporterFunc func(w io.Writer, results []models.CProxyWithResult) error
+
+func (f ExporterFunc) Export(w io.Writer, results []models.CProxyWithResult) error {
+	return f(w, results)
+}
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = map[string]Exporter{
+		"yaml":     &YAMLExporter{},
+		"csv":      &CSVExporter{},
+		"json":     &JSONExporter{Indent: true},
+		"jsonl":    &JSONLExporter{},
+		"md":       &MarkdownExporter{},
+		"markdown": &MarkdownExporter{},
+		"html":     &HTMLExporter{},
+		"uri":      &URIExporter{},
+		"base64":   &SubscriptionExporter{},
+		"sing-box": &SingBoxExporter{},
+		"singbox":  &SingBoxExporter{},
+	}
+)
+
+// RegisterExporter 注册（或覆盖）指定格式的导出器，format 不区分大小写
+func RegisterExporter(format string, exporter Exporter) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	exporters[strings.ToLower(format)] = exporter
+}
+
+// GetExporter 获取指定格式的导出器
+func GetExporter(format string) (Exporter, bool) {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	e, ok := exporters[strings.ToLower(format)]
+	return e, ok
+}
+
+// ExportFormats 返回已注册的导出格式
+func ExportFormats() []string {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	formats := make([]string, 0, len(exporters))
+	for format := range exporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// deadExporter 可选接口，ExportsDead 返回 true 的导出器会同时导出无效节点
+type deadExporter interface {
+	ExportsDead() bool
+}
+
+// Export 使用指定导出器导出有效节点，导出前会按 RenameTemplate 重命名
+func (t *Test) Export(w io.Writer, exporter Exporter) error {
+	results, err := t.resultsFor(exporter)
+	if err != nil {
+		return err
+	}
+	return exporter.Export(w, t.exportResults(results))
+}
+
+// resultsFor 返回导出器需要导出的结果
+func (t *Test) resultsFor(exporter Exporter) ([]models.CProxyWithResult, error) {
+	if !t._testedSpeed {
+		return nil, ErrSpeedNotTest
+	}
+	if e, ok := exporter.(deadExporter); ok && e.ExportsDead() {
+		return t.results, nil
+	}
+	if len(t.aliveProxies) == 0 {
+		return nil, ErrSpeedNoAlive
+	}
+	return t.aliveProxies, nil
+}
+
+// ExportFormat 按格式名导出有效节点，格式参考 ExportFormats
+func (t *Test) ExportFormat(w io.Writer, format string) error {
+	exporter, ok := t.exporter(format)
+	if !ok {
+		return fmt.Errorf("unsupported export format: %s", format)
+	}
+	return t.Export(w, exporter)
+}
+
+// WriteToFile 按格式将有效节点导出到文件，format 为空时根据文件扩展名判断
+func (t *Test) WriteToFile(name string, format string) error {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(name), ".")
+	}
+	exporter, ok := t.exporter(format)
+	if !ok {
+		return fmt.Errorf("unsupported export format: %s", format)
+	}
+	if _, err := t.resultsFor(exporter); err != nil {
+		return err
+	}
+	// 先写入临时文件，导出失败时不会留下不完整的文件
+	return writeFileAtomicFunc(name, 0o644, func(w io.Writer) error {
+		return t.Export(w, exporter)
+	})
+}
+
+// exporter 返回格式对应的导出器，未单独配置的内置 yaml/csv 导出器会带上 Options.Export 中的配置，
+// 未设置 Logger 的 uri/base64/sing-box 导出器使用 Options.Logger
+func (t *Test) exporter(format string) (Exporter, bool) {
+	exporter, ok := GetExporter(format)
+	if !ok {
+		return nil, false
+	}
+	switch e := exporter.(type) {
+	case *YAMLExporter:
+		if e.Annotation == models.AnnotationNone {
+			return &YAMLExporter{Annotation: t.options.Export.YAMLAnnotation}, true
+		}
+	case *CSVExporter:
+		if len(e.Columns) == 0 && e.Language == "" && !e.NoBOM && !e.IncludeDead {
+			return &CSVExporter{CSVOptions: t.options.Export.CSV}, true
+		}
+	case *URIExporter:
+		if e.Logger == nil {
+			return &URIExporter{Logger: t.options.Logger}, true
+		}
+	case *SubscriptionExporter:
+		if e.Logger == nil {
+			return &SubscriptionExporter{Logger: t.options.Logger}, true
+		}
+	case *SingBoxExporter:
+		if e.Logger == nil {
+			return &SingBoxExporter{Logger: t.options.Logger}, true
+		}
+	}
+	return exporter, true
+}
+
+// YAMLExporter 导出 mihomo 可直接使用的节点列表
+type YAMLExporter struct {
+	Annotation models.AnnotationMode
+}
+
+func (e *YAMLExporter) Export(w io.Writer, results []models.CProxyWithResult) error {
+	proxies := make([]map[string]any, 0, len(results))
+	for _, result := range results {
+		proxies = append(proxies, exportConfig(&result, e.Annotation))
+	}
+	encoder := yaml.NewEncoder(w)
+	if err := encoder.Encode(proxies); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// JSONExporter 以 JSON 数组导出全部结果字段
+type JSONExporter struct {
+	Indent     bool
+	OmitConfig bool // 不导出节点配置，便于对外分享
+}
+
+func (e *JSONExporter) Export(w io.Writer, results []models.CProxyWithResult) error {
+	records := make([]models.ExportRecord, 0, len(results))
+	for _, result := range results {
+		records = append(records, newExportRecord(&result, !e.OmitConfig))
+	}
+	encoder := json.NewEncoder(w)
+	if e.Indent {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(records)
+}
+
+// JSONLExporter 逐行写出 JSON，每行一条结果，适合流式处理
+type JSONLExporter struct {
+	OmitConfig bool
+}
+
+func (e *JSONLExporter) Export(w io.Writer, results []models.CProxyWithResult) error {
+	encoder := json.NewEncoder(w)
+	for _, result := range results {
+		if err := encoder.Encode(newExportRecord(&result, !e.OmitConfig)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newExportRecord(result *models.CProxyWithResult, withConfig bool) models.ExportRecord {
+	record := models.ExportRecord{
+		Result: result.Result,
+		Type:   resultType(result),
+		Server: resultServer(result),
+	}
+	if withConfig {
+		record.Config = exportConfig(result, models.AnnotationNone)
+	}
+	return record
+}
+
+func resultType(result *models.CProxyWithResult) string {
+	if tp, ok := result.Proxy.SecretConfig["type"].(string); ok {
+		return tp
+	}
+	if result.Proxy.Proxy != nil {
+		return strings.ToLower(result.Proxy.Type().String())
+	}
+	return ""
+}
+
+func resultServer(result *models.CProxyWithResult) string {
+	if server, ok := result.Proxy.SecretConfig["server"]; ok {
+		if port, ok := result.Proxy.SecretConfig["port"]; ok {
+			return net.JoinHostPort(fmt.Sprint(server), fmt.Sprint(port))
+		}
+		return fmt.Sprint(server)
+	}
+	if result.Proxy.Proxy != nil {
+		return result.Proxy.Addr()
+	}
+	return ""
+}
+
+// formatBytes 格式化字节数，如 12.50MB
+func formatBytes(n int64) string {
+	if n <= 0 {
+		return "0B"
+	}
+	v := float64(n)
+	for _, unit := range []string{"B", "KB", "MB", "GB"} {
+		if v < 1024 {
+			return fmt.Sprintf("%.02f%s", v, unit)
+		}
+		v /= 1024
+	}
+	return fmt.Sprintf("%.02fTB", v)
+}
+
+// reportRow 报告类导出（Markdown/HTML）的单行数据
+type reportRow struct {
+	Name, Type, Server, Country            string
+	Bandwidth, TTFB, Delay                 string
+	P50, P90, P95, Jitter, Loss            string
+	Checks, URLTests, Duration, Downloaded string
+	Source                                 string
+	Alive                                  bool
+}
+
+var reportHeaders = []string{
+	"节点", "类型", "服务器", "国家", "带宽", "首字节时间", "延迟 (ms)",
+	"P50", "P90", "P95", "抖动", "丢包率", "解锁检测", "链接测试", "测试耗时", "下载量", "来源",
+}
+
+func newReportRow(result *models.CProxyWithResult) reportRow {
+	return reportRow{
+		Name:       result.Name,
+		Type:       resultType(result),
+		Server:     resultServer(result),
+		Country:    result.Country,
+		Bandwidth:  result.FormattedBandwidth(),
+		TTFB:       result.FormattedTTFB(),
+		Delay:      strconv.Itoa(int(result.Delay)),
+		P50:        strconv.Itoa(int(result.DelayP50)),
+		P90:        strconv.Itoa(int(result.DelayP90)),
+		P95:        strconv.Itoa(int(result.DelayP95)),
+		Jitter:     strconv.Itoa(int(result.Jitter)),
+		Loss:       fmt.Sprintf("%.1f%%", result.LossRate*100),
+		Checks:     formatCheckResults(result.CheckResults),
+		URLTests:   formatURLTests(result.URLForTest),
+		Duration:   result.TestDuration.Round(time.Millisecond).String(),
+		Downloaded: formatBytes(result.DownloadBytes),
+		Source:     result.Source.String(),
+		Alive:      result.Alive(),
+	}
+}
+
+func (r reportRow) cells() []string {
+	return []string{
+		r.Name, r.Type, r.Server, r.Country, r.Bandwidth, r.TTFB, r.Delay,
+		r.P50, r.P90, r.P95, r.Jitter, r.Loss, r.Checks, r.URLTests, r.Duration, r.Downloaded, r.Source,
+	}
+}
+
+// formatCheckResults 以 "netflix:✅US gpt_web:❌" 的形式展示解锁结果
+func formatCheckResults(results []models.CheckResult) string {
+	if len(results) == 0 {
+		return ""
+	}
+	items := make([]string, 0, len(results))
+	for _, r := range results {
+		mark := "❌"
+		if r.OK {
+			mark = "✅"
+		}
+		items = append(items, fmt.Sprintf("%s:%s%s", r.Type, mark, r.Value))
+	}
+	sort.Strings(items)
+	return strings.Join(items, " ")
+}
+
+func formatURLTests(results map[string]bool) string {
+	if len(results) == 0 {
+		return ""
+	}
+	items := make([]string, 0, len(results))
+	for url, ok := range results {
+		mark := "❌"
+		if ok {
+			mark = "✅"
+		}
+		items = append(items, url+":"+mark)
+	}
+	sort.Strings(items)
+	return strings.Join(items, " ")
+}
+
+// MarkdownExporter 导出 Markdown 表格，便于在聊天工具或文档中分享
+type MarkdownExporter struct{}
+
+func (e *MarkdownExporter) Export(w io.Writer, results []models.CProxyWithResult) error {
+	escape := strings.NewReplacer("|", "\\|", "\n", " ", "\r", "")
+	var b strings.Builder
+	b.WriteString("| " + strings.Join(reportHeaders, " | ") + " |\n")
+	b.WriteString("|" + strings.Repeat(" --- |", len(reportHeaders)) + "\n")
+	for _, result := range results {
+		cells := newReportRow(&result).cells()
+		for i, cell := range cells {
+			cells[i] = escape.Replace(cell)
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// HTMLExporter 导出自包含的 HTML 报告，包括统计概览、地区分布与结果明细
+type HTMLExporter struct {
+	Title string
+}
+
+type htmlReport struct {
+	Title     string
+	Summary   resultSummary
+	Dead      int
+	Headers   []string
+	Rows      [][]string
+	RowsAlive []bool
+}
+
+// ExportsDead HTML 报告统计无效节点并在明细中标出，总是导出全部结果
+func (e *HTMLExporter) ExportsDead() bool {
+	return true
+}
+
+func (e *HTMLExporter) Export(w io.Writer, results []models.CProxyWithResult) error {
+	title := e.Title
+	if title == "" {
+		title = "SpeedTest-Clash 测速报告"
+	}
+	report := htmlReport{
+		Title:   title,
+		Summary: summarizeResults(results),
+		Headers: reportHeaders,
+	}
+	report.Dead = report.Summary.Total - report.Summary.Alive
+	for _, result := range results {
+		row := newReportRow(&result)
+		report.Rows = append(report.Rows, row.cells())
+		report.RowsAlive = append(report.RowsAlive, row.Alive)
+	}
+	return htmlReportTemplate.Execute(w, report)
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(n, total int) string {
+		if total == 0 {
+			return "0"
+		}
+		return strconv.FormatFloat(float64(n)/float64(total)*100, 'f', 1, 64)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body{font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Roboto,"PingFang SC","Microsoft YaHei",sans-serif;margin:24px;color:#222}
+h1{font-size:22px}h2{font-size:18px;margin-top:28px}
+table{border-collapse:collapse;font-size:13px}
+th,td{border:1px solid #ddd;padding:4px 8px;text-align:left;white-space:nowrap}
+th{background:#f5f5f5}
+tr.dead td{color:#999}
+.cards{display:flex;gap:12px;flex-wrap:wrap}
+.card{border:1px solid #ddd;border-radius:6px;padding:10px 16px;min-width:120px}
+.card b{display:block;font-size:20px}
+.bar{background:#4c8bf5;height:10px;display:inline-block;vertical-align:middle}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<h2>统计概览</h2>
+<div class="cards">
+<div class="card">总节点<b>{{.Summary.Total}}</b></div>
+<div class="card">✅ 有效<b>{{.Summary.Alive}}</b></div>
+<div class="card">❌ 无效<b>{{.Dead}}</b></div>
+</div>
+{{- if .Summary.Alive}}
+<h2>性能详情</h2>
+<table>
+<tr><th></th><th>最小值</th><th>最大值</th><th>平均值</th></tr>
+<tr><td>带宽</td><td>{{.Summary.Min.FormattedBandwidth}}</td><td>{{.Summary.Max.FormattedBandwidth}}</td><td>{{.Summary.Avg.FormattedBandwidth}}</td></tr>
+<tr><td>延迟</td><td>{{.Summary.Min.FormattedTTFB}}</td><td>{{.Summary.Max.FormattedTTFB}}</td><td>{{.Summary.Avg.FormattedTTFB}}</td></tr>
+</table>
+<h2>地区分布</h2>
+<table>
+<tr><th>地区</th><th>节点数</th><th>占比</th></tr>
+{{- range .Summary.Countries}}
+<tr><td>{{.Country}}</td><td>{{.Count}}</td><td><span class="bar" style="width:{{percent .Count $.Summary.Alive}}px"></span> {{percent .Count $.Summary.Alive}}%</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Summary.Sources}}
+<h2>来源统计</h2>
+<table>
+<tr><th>来源</th><th>✅ 有效</th><th>❌ 无效</th></tr>
+{{- range .Summary.Sources}}
+<tr><td>{{.Source}}</td><td>{{.Alive}}</td><td>{{.Dead}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+<h2>结果明细</h2>
+<table>
+<tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr>
+{{- range $i, $row := .Rows}}
+<tr{{if not (index $.RowsAlive $i)}} class="dead"{{end}}>{{range $row}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,135 @@
+package speedtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+func exporterResults() []models.CProxyWithResult {
+	return []models.CProxyWithResult{
+		{
+			Result: models.Result{Name: "hk|01", Country: "HK", Bandwidth: 10 * 1024 * 1024, Delay: 100, DelayP90: 130},
+			Proxy:  models.CProxy{SecretConfig: map[string]any{"name": "hk|01", "type": "ss", "server": "1.1.1.1", "port": 8388}},
+		},
+		{
+			Result: models.Result{Name: "us-01", Country: "US", Bandwidth: 20 * 1024 * 1024, Delay: 200},
+			Proxy:  models.CProxy{SecretConfig: map[string]any{"name": "us-01", "type": "trojan", "server": "2.2.2.2", "port": 443}},
+		},
+	}
+}
+
+func TestJSONLExporterWritesOneRecordPerLine(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, (&JSONLExporter{}).Export(&buf, exporterResults()))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	var record map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "hk|01", record["name"])
+	assert.Equal(t, "ss", record["type"])
+	assert.Equal(t, "1.1.1.1:8388", record["server"])
+	assert.Equal(t, float64(130), record["delay_p90"])
+	assert.Contains(t, record, "config")
+}
+
+func TestJSONExporterOmitConfig(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, (&JSONExporter{OmitConfig: true}).Export(&buf, exporterResults()))
+
+	var records []map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &records))
+	assert.Len(t, records, 2)
+	assert.NotContains(t, records[0], "config")
+}
+
+func TestMarkdownExporterEscapesCells(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, (&MarkdownExporter{}).Export(&buf, exporterResults()))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "| 节点 |"))
+	assert.Contains(t, lines[2], `hk\|01`)
+	assert.Contains(t, lines[3], "20.00MB/s")
+}
+
+func TestHTMLExporterIncludesSummary(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, (&HTMLExporter{Title: "report"}).Export(&buf, exporterResults()))
+
+	html := buf.String()
+	assert.Contains(t, html, "<title>report</title>")
+	assert.Contains(t, html, "<td>HK</td><td>1</td>")
+	assert.Contains(t, html, "<td>US</td><td>1</td>")
+	assert.Contains(t, html, "15.00MB/s") // 平均带宽
+	assert.Contains(t, html, "hk|01")
+}
+
+func TestHTMLExportIncludesDead(t *testing.T) {
+	tester, _ := newExportTester(t, models.ExportOptions{})
+	tester.results = append(tester.results, models.CProxyWithResult{Result: models.Result{Name: "dead-node"}})
+
+	var buf bytes.Buffer
+	require.NoError(t, tester.ExportFormat(&buf, "html"))
+	html := buf.String()
+	assert.Contains(t, html, "❌ 无效<b>1</b>")
+	assert.Contains(t, html, "dead-node")
+}
+
+func TestWriteToFileKeepsExistingFileOnError(t *testing.T) {
+	RegisterExporter("failing-test", ExporterFunc(func(w io.Writer, results []models.CProxyWithResult) error {
+		_, _ = io.WriteString(w, "partial")
+		return errors.New("export failed")
+	}))
+	tester, _ := newExportTester(t, models.ExportOptions{})
+	dir := t.TempDir()
+	name := filepath.Join(dir, "out.txt")
+	require.NoError(t, os.WriteFile(name, []byte("previous"), 0644))
+
+	assert.Error(t, tester.WriteToFile(name, "failing-test"))
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "previous", string(data))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary file removed")
+
+	require.NoError(t, tester.WriteToFile(name, "json"))
+	data, err = os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"node"`)
+}
+
+func TestRegisterExporter(t *testing.T) {
+	called := false
+	RegisterExporter("Custom-Test", ExporterFunc(func(w io.Writer, results []models.CProxyWithResult) error {
+		called = true
+		return nil
+	}))
+
+	e, ok := GetExporter("custom-test")
+	assert.True(t, ok)
+	assert.NoError(t, e.Export(io.Discard, nil))
+	assert.True(t, called)
+	assert.Contains(t, ExportFormats(), "custom-test")
+}
+
+func TestExportFormatRequiresTestedSpeed(t *testing.T) {
+	tester, err := NewTest(models.Options{ConfigPath: "dummy.yaml"})
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, tester.ExportFormat(io.Discard, "json"), ErrSpeedNotTest)
+	assert.Error(t, tester.ExportFormat(io.Discard, "unknown"))
+}
@@ -0,0 +1,357 @@
+package speedtest
+
+import (
+	"container/list"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+const (
+	defaultFileCacheTTL        = 30 * time.Minute
+	defaultFileCacheMaxEntries = 10000
+	fileCacheExt               = ".json"
+	fileCacheLockStripes       = 64
+	// staleTempFileAge 超过该时间的临时文件视为写入过程中进程退出的残留，较新的可能正被其它进程写入
+	staleTempFileAge  = time.Minute
+	atomicTempPattern = ".tmp-*"
+)
+
+// FileCache 基于本地目录的持久化缓存，每个缓存项为一个 JSON 文件，进程重启后仍然有效。
+// 缓存项数量超过 maxEntries 时按最近最少使用（LRU）淘汰，文件修改时间记录最近访问时间。
+// 可被 RunStream 的多个 worker 并发使用。
+type FileCache struct {
+	dir        string
+	policy     models.CacheTTLPolicy
+	maxEntries int
+	counters   cacheCounters
+
+	mu    sync.Mutex
+	lru   *list.List // 最近使用的在前，元素为 *fileCacheEntry
+	index map[string]*list.Element
+
+	// files 按 key 分片的文件锁，串行化同一 key 的文件写入与删除。需要同时持有 mu 时先获取文件锁，
+	// 持有文件锁时不获取其它分片
+	files [fileCacheLockStripes]sync.Mutex
+}
+
+type fileCacheEntry struct {
+	key       string
+	expiresAt time.Time
+	result    *models.CProxyWithResult // 已重新解析的结果，首次 Get 时从文件加载
+}
+
+// NewFileCache 创建持久化缓存，dir 不存在时自动创建，有效与无效节点使用相同的TTL。
+// ttl <= 0 时默认 30 分钟，maxEntries <= 0 时默认 10000
+func NewFileCache(dir string, ttl time.Duration, maxEntries int) (*FileCache, error) {
+	if ttl <= 0 {
+		ttl = defaultFileCacheTTL
+	}
+	return NewFileCacheWithPolicy(dir, models.CacheTTLPolicy{AliveTTL: ttl, DeadTTL: ttl}, maxEntries)
+}
+
+// NewFileCacheWithPolicy 创建按测速结果决定TTL的持久化缓存
+func NewFileCacheWithPolicy(dir string, policy models.CacheTTLPolicy, maxEntries int) (*FileCache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("file cache dir is empty")
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultFileCacheMaxEntries
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+	c := &FileCache{
+		dir:        dir,
+		policy:     policy,
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		index:      make(map[string]*list.Element),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load 扫描缓存目录重建索引，清理过期或损坏的缓存项以及写入中断残留的临时文件
+func (c *FileCache) load() error {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("read cache dir: %w", err)
+	}
+	type loaded struct {
+		entry  *fileCacheEntry
+		usedAt time.Time
+	}
+	var (
+		now     = time.Now()
+		entries = make([]loaded, 0, len(files))
+	)
+	for _, file := range files {
+		name := file.Name()
+		if !file.IsDir() && strings.HasPrefix(name, strings.TrimSuffix(atomicTempPattern, "*")) {
+			if info, err := file.Info(); err == nil && now.Sub(info.ModTime()) > staleTempFileAge {
+				_ = os.Remove(filepath.Join(c.dir, name))
+			}
+			continue
+		}
+		if file.IsDir() || !strings.HasSuffix(name, fileCacheExt) {
+			continue
+		}
+		path := filepath.Join(c.dir, name)
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		cached, err := readCachedResult(path)
+		if err != nil || cached.Expired(now) || c.path(cached.Key) != path {
+			_ = os.Remove(path)
+			continue
+		}
+		entries = append(entries, loaded{
+			entry:  &fileCacheEntry{key: cached.Key, expiresAt: cached.ExpiresAt},
+			usedAt: info.ModTime(),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].usedAt.After(entries[j].usedAt) })
+
+	c.mu.Lock()
+	for _, e := range entries {
+		c.index[e.entry.key] = c.lru.PushBack(e.entry)
+	}
+	evicted := c.evictLocked()
+	c.mu.Unlock()
+	c.removeFiles(evicted...)
+	return nil
+}
+
+// Get 读取缓存项，锁只保护索引与 LRU，读取文件、重新解析节点等磁盘操作在锁外进行
+func (c *FileCache) Get(ctx context.Context, key string) (*models.CProxyWithResult, bool) {
+	now := time.Now()
+	c.mu.Lock()
+	el, ok := c.index[key]
+	if !ok {
+		c.mu.Unlock()
+		return nil, c.counters.hit(false)
+	}
+	entry := el.Value.(*fileCacheEntry)
+	if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+		c.removeLocked(el)
+		c.mu.Unlock()
+		c.removeFiles(entry.key)
+		return nil, c.counters.hit(false)
+	}
+	c.lru.MoveToFront(el)
+	result := entry.result
+	c.mu.Unlock()
+
+	if result == nil {
+		cached, err := readCachedResult(c.path(key))
+		if err == nil {
+			result, err = rehydrateResult(cached)
+		}
+		if err != nil {
+			c.drop(entry)
+			return nil, c.counters.hit(false)
+		}
+		// 并发的 Get 可能重复解析，以先写入的为准
+		c.mu.Lock()
+		if entry.result == nil {
+			entry.result = result
+		} else {
+			result = entry.result
+		}
+		c.mu.Unlock()
+	}
+	_ = os.Chtimes(c.path(key), now, now)
+	return result, c.counters.hit(true)
+}
+
+// Set 按 TTL 策略缓存结果，策略不允许缓存的结果（如不完整的结果）会被跳过
+func (c *FileCache) Set(ctx context.Context, key string, result *models.CProxyWithResult) error {
+	ttl, ok := c.policy.TTL(&result.Result)
+	if !ok {
+		c.counters.skipped.Add(1)
+		return nil
+	}
+	expiresAt := time.Now().Add(ttl)
+	data, err := json.Marshal(models.NewCachedResult(key, result, expiresAt))
+	if err != nil {
+		return fmt.Errorf("marshal cache entry: %w", err)
+	}
+	// 写入文件与更新索引在同一个文件锁内完成，并发的删除要么在写入前完成，要么在锁内看到新的索引而跳过
+	lock := c.fileLock(key)
+	lock.Lock()
+	if err := writeFileAtomic(c.path(key), data); err != nil {
+		lock.Unlock()
+		return err
+	}
+	c.mu.Lock()
+	entry := &fileCacheEntry{key: key, expiresAt: expiresAt, result: result}
+	if el, ok := c.index[key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+	} else {
+		c.index[key] = c.lru.PushFront(entry)
+	}
+	evicted := c.evictLocked()
+	c.mu.Unlock()
+	lock.Unlock()
+
+	c.removeFiles(evicted...)
+	c.counters.sets.Add(1)
+	return nil
+}
+
+// Stats 返回缓存命中统计
+func (c *FileCache) Stats() models.CacheStats {
+	return c.counters.stats()
+}
+
+func (c *FileCache) GenerateKey(proxy *models.CProxy) string {
+	return generateCacheKey(proxy)
+}
+
+// Len 返回缓存项数量（包括尚未清理的过期项）
+func (c *FileCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Close 缓存项写入时已落盘，无需额外处理
+func (c *FileCache) Close() error {
+	return nil
+}
+
+// evictLocked 按 LRU 淘汰超出容量的缓存项，返回被淘汰的 key，其文件由调用方在释放锁后删除
+func (c *FileCache) evictLocked() []string {
+	var evicted []string
+	for c.lru.Len() > c.maxEntries {
+		el := c.lru.Back()
+		c.removeLocked(el)
+		evicted = append(evicted, el.Value.(*fileCacheEntry).key)
+	}
+	return evicted
+}
+
+// removeLocked 从索引中移除缓存项，调用方需持有锁
+func (c *FileCache) removeLocked(el *list.Element) {
+	entry := c.lru.Remove(el).(*fileCacheEntry)
+	delete(c.index, entry.key)
+}
+
+// drop 移除读取失败的缓存项，期间已被 Set 替换的缓存项保持不变
+func (c *FileCache) drop(entry *fileCacheEntry) {
+	c.mu.Lock()
+	el, ok := c.index[entry.key]
+	if !ok || el.Value != entry {
+		c.mu.Unlock()
+		return
+	}
+	c.removeLocked(el)
+	c.mu.Unlock()
+	c.removeFiles(entry.key)
+}
+
+// removeFiles 删除已从索引中移除的缓存项文件，调用方不能持有锁。在文件锁内重新检查索引，
+// 期间已被 Set 重新写入的缓存项保持不变
+func (c *FileCache) removeFiles(keys ...string) {
+	for _, key := range keys {
+		lock := c.fileLock(key)
+		lock.Lock()
+		c.mu.Lock()
+		_, ok := c.index[key]
+		c.mu.Unlock()
+		if !ok {
+			_ = os.Remove(c.path(key))
+		}
+		lock.Unlock()
+	}
+}
+
+// fileLock 返回 key 所在分片的文件锁
+func (c *FileCache) fileLock(key string) *sync.Mutex {
+	hash := md5.Sum([]byte(key))
+	return &c.files[int(hash[0])%fileCacheLockStripes]
+}
+
+// path 缓存项文件路径，key 可能包含任意字符，因此取其哈希作为文件名
+func (c *FileCache) path(key string) string {
+	hash := md5.Sum([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(hash[:])+fileCacheExt)
+}
+
+func readCachedResult(path string) (*models.CachedResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cached models.CachedResult
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, err
+	}
+	return &cached, nil
+}
+
+// rehydrateResult 通过缓存的节点配置重新解析出 C.Proxy
+func rehydrateResult(cached *models.CachedResult) (*models.CProxyWithResult, error) {
+	proxy, err := parseProxy(cached.Config)
+	if err != nil {
+		return nil, fmt.Errorf("rehydrate cached proxy: %w", err)
+	}
+	return &models.CProxyWithResult{
+		Result: cached.Result,
+		Proxy: models.CProxy{
+			Proxy:        proxy,
+			SecretConfig: cached.Config,
+		},
+	}, nil
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免并发读到写了一半的文件
+func writeFileAtomic(path string, data []byte) error {
+	return writeFileAtomicFunc(path, 0o600, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// writeFileAtomicFunc 与 writeFileAtomic 相同，内容由 write 写入；write 失败时不会留下文件，已有的文件保持不变
+func writeFileAtomicFunc(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), atomicTempPattern)
+	if err != nil {
+		return err
+	}
+	if err := write(tmp); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package speedtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/metacubex/mihomo/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+func cacheTestResult(t *testing.T, name string) *models.CProxyWithResult {
+	t.Helper()
+	config := map[string]any{
+		"name": name, "type": "ss", "server": "127.0.0.1", "port": 8388,
+		"cipher": "aes-128-gcm", "password": "secret",
+	}
+	proxy, err := adapter.ParseProxy(config)
+	require.NoError(t, err)
+	return &models.CProxyWithResult{
+		Result: models.Result{
+			Name:         name,
+			Bandwidth:    1024,
+			TTFB:         120 * time.Millisecond,
+			Delay:        80,
+			CheckResults: []models.CheckResult{models.NewCheckResult(models.CheckTypeNetflix, true, "US")},
+		},
+		Proxy: models.CProxy{Proxy: proxy, SecretConfig: config},
+	}
+}
+
+func TestFileCacheSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	cache, err := NewFileCache(dir, time.Hour, 10)
+	require.NoError(t, err)
+	result := cacheTestResult(t, "hk")
+	key := cache.GenerateKey(&result.Proxy)
+	require.NoError(t, cache.Set(ctx, key, result))
+	require.NoError(t, cache.Close())
+
+	reopened, err := NewFileCache(dir, time.Hour, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reopened.Len())
+
+	cached, ok := reopened.Get(ctx, key)
+	require.True(t, ok)
+	assert.Equal(t, result.Result, cached.Result)
+	require.NotNil(t, cached.Proxy.Proxy)
+	assert.Equal(t, "hk", cached.Proxy.Name())
+	assert.Equal(t, "127.0.0.1:8388", cached.Proxy.Addr())
+	assert.Equal(t, key, reopened.GenerateKey(&cached.Proxy))
+}
+
+func TestFileCacheExpires(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	cache, err := NewFileCache(dir, 20*time.Millisecond, 10)
+	require.NoError(t, err)
+	require.NoError(t, cache.Set(ctx, "k", cacheTestResult(t, "hk")))
+	_, ok := cache.Get(ctx, "k")
+	assert.True(t, ok)
+
+	time.Sleep(40 * time.Millisecond)
+	_, ok = cache.Get(ctx, "k")
+	assert.False(t, ok)
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestFileCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	cache, err := NewFileCache(dir, time.Hour, 2)
+	require.NoError(t, err)
+	require.NoError(t, cache.Set(ctx, "a", cacheTestResult(t, "a")))
+	require.NoError(t, cache.Set(ctx, "b", cacheTestResult(t, "b")))
+	_, ok := cache.Get(ctx, "a")
+	require.True(t, ok)
+	require.NoError(t, cache.Set(ctx, "c", cacheTestResult(t, "c")))
+
+	_, ok = cache.Get(ctx, "b")
+	assert.False(t, ok)
+	_, ok = cache.Get(ctx, "a")
+	assert.True(t, ok)
+	_, ok = cache.Get(ctx, "c")
+	assert.True(t, ok)
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestFileCacheConcurrentAccess(t *testing.T) {
+	cache, err := NewFileCache(t.TempDir(), time.Hour, 16)
+	require.NoError(t, err)
+	result := cacheTestResult(t, "hk")
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("k%d", i%20)
+			assert.NoError(t, cache.Set(ctx, key, result))
+			cache.Get(ctx, key)
+		}(i)
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, cache.Len(), 16)
+}
+
+func TestFileCacheConcurrentRehydrate(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	cache, err := NewFileCache(dir, time.Hour, 10)
+	require.NoError(t, err)
+	require.NoError(t, cache.Set(ctx, "good", cacheTestResult(t, "good")))
+	require.NoError(t, cache.Set(ctx, "bad", cacheTestResult(t, "bad")))
+	require.NoError(t, os.WriteFile(cache.path("bad"), []byte("{"), 0o600))
+
+	reopened, err := NewFileCache(dir, time.Hour, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, reopened.Len(), "corrupt file dropped on load")
+	require.NoError(t, reopened.Set(ctx, "bad", cacheTestResult(t, "bad")))
+	reopened.mu.Lock()
+	reopened.index["bad"].Value.(*fileCacheEntry).result = nil
+	reopened.mu.Unlock()
+	require.NoError(t, os.WriteFile(reopened.path("bad"), []byte("{"), 0o600))
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = map[*models.CProxyWithResult]struct{}{}
+	)
+	for i := 0; i < 16; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			result, ok := reopened.Get(ctx, "good")
+			if assert.True(t, ok) {
+				mu.Lock()
+				results[result] = struct{}{}
+				mu.Unlock()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_, ok := reopened.Get(ctx, "bad")
+			assert.False(t, ok)
+		}()
+	}
+	wg.Wait()
+
+	// 后续的 Get 复用第一次解析的结果
+	result, ok := reopened.Get(ctx, "good")
+	require.True(t, ok)
+	assert.Contains(t, results, result)
+	assert.Equal(t, 1, reopened.Len(), "unreadable entry removed")
+	_, err = os.Stat(reopened.path("bad"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFileCacheConcurrentSetAndEvict(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewFileCache(dir, time.Hour, 1)
+	require.NoError(t, err)
+	result := cacheTestResult(t, "hk")
+	ctx := context.Background()
+
+	// 容量为 1 时两个 key 互相淘汰，被淘汰后重新写入的文件不能被之前的淘汰删除
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				assert.NoError(t, cache.Set(ctx, fmt.Sprintf("k%d", (i+j)%2), result))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, cache.Len())
+	for key := range cache.index {
+		_, err := os.Stat(cache.path(key))
+		assert.NoError(t, err, "indexed entry must have its file")
+	}
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	// 淘汰 k0 后在删除文件之前被重新写入，迟到的删除保留新文件
+	require.NoError(t, cache.Set(ctx, "k0", result))
+	require.NoError(t, cache.Set(ctx, "k1", result))
+	require.NoError(t, cache.Set(ctx, "k0", result))
+	cache.removeFiles("k0", "k1")
+	assert.FileExists(t, cache.path("k0"))
+	assert.NoFileExists(t, cache.path("k1"))
+}
+
+func TestFileCacheRemovesStaleTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, ".tmp-stale")
+	fresh := filepath.Join(dir, ".tmp-fresh")
+	require.NoError(t, os.WriteFile(stale, []byte("{"), 0o600))
+	require.NoError(t, os.WriteFile(fresh, []byte("{"), 0o600))
+	old := time.Now().Add(-2 * staleTempFileAge)
+	require.NoError(t, os.Chtimes(stale, old, old))
+
+	_, err := NewFileCache(dir, time.Hour, 10)
+	require.NoError(t, err)
+	assert.NoFileExists(t, stale)
+	// 较新的临时文件可能正被其它进程写入
+	assert.FileExists(t, fresh)
+}
@@ -0,0 +1,400 @@
+package speedtest
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dlclark/regexp2"
+	C "github.com/metacubex/mihomo/constant"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+// evaluatedGroupTypes 需要推断选择结果的代理组类型
+var evaluatedGroupTypes = []string{"url-test", "fallback", "load-balance"}
+
+// groupAdapterTypes 代理组类型对应的 mihomo 出站类型，用于以 exclude-type 排除嵌套的代理组
+var groupAdapterTypes = map[string]C.AdapterType{
+	"select":       C.Selector,
+	"url-test":     C.URLTest,
+	"fallback":     C.Fallback,
+	"load-balance": C.LoadBalance,
+}
+
+// builtinProxies 代理组中可以引用的内置出站，不参与测速与选择
+var builtinProxies = []string{"DIRECT", "REJECT", "REJECT-DROP", "PASS", "COMPATIBLE"}
+
+// proxyGroups 加载过程中收集的各配置的 proxy-groups，nil 时所有方法均为空操作
+type proxyGroups struct {
+	mu      sync.Mutex
+	sources []sourceGroups
+}
+
+type sourceGroups struct {
+	source    string
+	groups    []map[string]any
+	providers map[string]string // provider 名称到其链接或路径
+}
+
+// add 记录 ConfigPath 中来源的 proxy-groups，provider 中的代理组 mihomo 不会读取，被忽略。
+// 同一来源重复解析（如回退到快照）时以最后一次为准
+func (g *proxyGroups) add(task sourceTask, groups []map[string]any, providers map[string]models.ProxyProvider) {
+	if g == nil || len(groups) == 0 || task.source.Provider != "" {
+		return
+	}
+	s := sourceGroups{source: task.source.URL, groups: groups, providers: make(map[string]string, len(providers))}
+	for name, options := range providers {
+		s.providers[name] = providerLocation(s.source, options)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i := range g.sources {
+		if g.sources[i].source == s.source {
+			g.sources[i] = s
+			return
+		}
+	}
+	g.sources = append(g.sources, s)
+}
+
+// evaluate 按测速结果推断各配置中 url-test/fallback/load-balance 组的选择结果，按加载顺序排列
+func (g *proxyGroups) evaluate(results []models.CProxyWithResult) []models.GroupReport {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var reports []models.GroupReport
+	for _, s := range g.sources {
+		e := newGroupEvaluator(s, results)
+		for _, group := range s.groups {
+			if typ, _ := group["type"].(string); slices.Contains(evaluatedGroupTypes, typ) {
+				reports = append(reports, e.report(group))
+			}
+		}
+	}
+	return reports
+}
+
+// groupMember 代理组的成员：节点或嵌套的代理组，result 为 nil 表示没有测速结果；
+// adapter 为 mihomo 的出站类型名称（如 Shadowsocks、URLTest），用于 exclude-type，未知时为空
+type groupMember struct {
+	name    string
+	adapter string
+	result  *models.Result
+}
+
+// groupEvaluator 在单个配置内解析代理组成员。proxies 中的名称对应配置中的节点（含 relay 组生成的链式节点）或其它代理组，
+// use 对应 proxy-providers 中的节点
+type groupEvaluator struct {
+	source    string
+	groups    map[string]map[string]any
+	proxies   map[string]*models.CProxyWithResult   // 配置中的节点，按名称索引
+	ordered   []*models.CProxyWithResult            // 配置中的节点，按来源中的序号排列
+	providers map[string][]*models.CProxyWithResult // provider 中的节点，按 provider 名称索引，重复加载的 provider 按链接匹配
+	selected  map[string]*groupMember               // 已推断的代理组，用于嵌套
+	visiting  map[string]bool
+}
+
+func newGroupEvaluator(s sourceGroups, results []models.CProxyWithResult) *groupEvaluator {
+	e := &groupEvaluator{
+		source:    s.source,
+		groups:    make(map[string]map[string]any),
+		proxies:   make(map[string]*models.CProxyWithResult),
+		providers: make(map[string][]*models.CProxyWithResult),
+		selected:  make(map[string]*groupMember),
+		visiting:  make(map[string]bool),
+	}
+	for _, group := range s.groups {
+		if name, _ := group["name"].(string); name != "" {
+			e.groups[name] = group
+		}
+	}
+	locations := make(map[string][]string, len(s.providers))
+	for name, location := range s.providers {
+		locations[location] = append(locations[location], name)
+	}
+	for i := range results {
+		r := &results[i]
+		switch {
+		case r.Source == nil:
+		case r.Source.Provider != "":
+			for _, name := range locations[r.Source.URL] {
+				e.providers[name] = append(e.providers[name], r)
+			}
+		case r.Source.URL == s.source:
+			if _, ok := e.proxies[r.Name]; !ok {
+				e.proxies[r.Name] = r
+				e.ordered = append(e.ordered, r)
+			}
+		}
+	}
+	sortBySourceIndex := func(list []*models.CProxyWithResult) {
+		slices.SortStableFunc(list, func(a, b *models.CProxyWithResult) int { return a.Source.Index - b.Source.Index })
+	}
+	sortBySourceIndex(e.ordered)
+	for _, list := range e.providers {
+		sortBySourceIndex(list)
+	}
+	return e
+}
+
+// report 推断单个代理组的选择结果
+func (e *groupEvaluator) report(group map[string]any) models.GroupReport {
+	name, _ := group["name"].(string)
+	typ, _ := group["type"].(string)
+	r := models.GroupReport{Source: e.source, Name: name, Type: typ}
+	members, err := e.members(group)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Members = len(members)
+	var alive []groupMember
+	for _, m := range members {
+		switch {
+		case m.result == nil:
+			r.Missing = append(r.Missing, m.name)
+		case m.result.Alive():
+			alive = append(alive, m)
+		}
+	}
+	r.Alive = len(alive)
+	if len(alive) == 0 {
+		return r
+	}
+	if typ == "load-balance" {
+		var delay, bandwidth float64
+		for _, m := range alive {
+			delay += float64(memberDelay(m.result))
+			bandwidth += m.result.Bandwidth
+		}
+		r.Delay = uint16(delay / float64(len(alive)))
+		r.Bandwidth = bandwidth / float64(len(alive))
+		return r
+	}
+	pick := selectMember(typ, members)
+	r.Selected = pick.name
+	r.Delay = memberDelay(pick.result)
+	r.Bandwidth = pick.result.Bandwidth
+	return r
+}
+
+// selectMember 按 mihomo 的规则选择成员：url-test 选延迟最低的有效成员（首次选择不考虑 tolerance），
+// fallback 选第一个有效成员，select 为默认的第一个成员。没有可选成员时返回 nil
+func selectMember(typ string, members []groupMember) *groupMember {
+	var pick *groupMember
+	for i := range members {
+		m := &members[i]
+		if typ == "select" {
+			return m
+		}
+		if m.result == nil || !m.result.Alive() {
+			continue
+		}
+		if typ == "fallback" {
+			return m
+		}
+		if pick == nil || memberDelay(m.result) < memberDelay(pick.result) {
+			pick = m
+		}
+	}
+	return pick
+}
+
+// memberDelay 成员的延迟，未测延迟时使用首字节时间
+func memberDelay(r *models.Result) uint16 {
+	if r.Delay > 0 {
+		return r.Delay
+	}
+	return uint16(r.TTFB.Milliseconds())
+}
+
+// members 按 mihomo 的规则展开代理组的成员：proxies 中的节点与嵌套代理组以及 include-all 的节点组成与代理组同名的
+// compatible provider 排在最前，filter 只作用于 include-all 的节点与 use 的 provider；多个 filter 时各 provider 内按 filter 的顺序排列，
+// 有多个 provider 时再整体按 filter 的顺序重排。exclude-filter/exclude-type 作用于所有成员。正则无效时返回错误
+func (e *groupEvaluator) members(group map[string]any) ([]groupMember, error) {
+	options := models.ProxyProvider{}
+	options.Filter, _ = group["filter"].(string)
+	options.ExcludeFilter, _ = group["exclude-filter"].(string)
+	options.ExcludeType, _ = group["exclude-type"].(string)
+	filter, err := newProviderFilter(options)
+	if err != nil {
+		return nil, err
+	}
+	var filters []*regexp2.Regexp
+	if options.Filter != "" {
+		filters = filter.filters
+	}
+
+	var compatible []groupMember
+	for _, name := range stringList(group["proxies"]) {
+		if slices.Contains(builtinProxies, strings.ToUpper(name)) {
+			continue
+		}
+		if nested, ok := e.groups[name]; ok && nested["type"] != "relay" {
+			m, err := e.nestedGroup(nested)
+			if err != nil {
+				return nil, err
+			}
+			compatible = append(compatible, m)
+			continue
+		}
+		m := groupMember{name: name}
+		if r, ok := e.proxies[name]; ok {
+			m = resultMember(r)
+		}
+		compatible = append(compatible, m)
+	}
+	includeAll, _ := group["include-all"].(bool)
+	if proxies, _ := group["include-all-proxies"].(bool); proxies || includeAll {
+		for _, r := range e.ordered {
+			if _, isGroup := e.groups[r.Name]; !isGroup && matchAny(filters, r.Name) {
+				compatible = append(compatible, resultMember(r))
+			}
+		}
+	}
+
+	var providers [][]groupMember
+	if len(compatible) > 0 {
+		providers = append(providers, compatible)
+	}
+	var use []string
+	if all, _ := group["include-all-providers"].(bool); all || includeAll {
+		for name := range e.providers {
+			use = append(use, name)
+		}
+		slices.Sort(use)
+	} else {
+		use = stringList(group["use"])
+	}
+	for _, name := range use {
+		var list []groupMember
+		for _, r := range e.providers[name] {
+			list = append(list, resultMember(r))
+		}
+		if len(filters) > 0 {
+			list = orderByFilters(filters, list, false)
+		}
+		providers = append(providers, list)
+	}
+
+	var members []groupMember
+	for _, list := range providers {
+		members = append(members, list...)
+	}
+	if len(providers) > 1 && len(filters) > 1 {
+		members = orderByFilters(filters, members, true)
+	}
+	out := members[:0]
+	for _, m := range members {
+		if !excludedMember(filter, m) {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+// orderByFilters 按 filter 的顺序输出匹配的成员，同名成员只保留第一个；keepUnmatched 时未匹配的成员排在最后
+func orderByFilters(filters []*regexp2.Regexp, members []groupMember, keepUnmatched bool) []groupMember {
+	out := make([]groupMember, 0, len(members))
+	seen := make(map[string]struct{}, len(members))
+	add := func(m groupMember) {
+		if _, ok := seen[m.name]; !ok {
+			seen[m.name] = struct{}{}
+			out = append(out, m)
+		}
+	}
+	for _, reg := range filters {
+		for _, m := range members {
+			if mat, _ := reg.MatchString(m.name); mat {
+				add(m)
+			}
+		}
+	}
+	if keepUnmatched {
+		for _, m := range members {
+			add(m)
+		}
+	}
+	return out
+}
+
+// matchAny 名称是否匹配任意一个 filter，没有 filter 时均匹配
+func matchAny(filters []*regexp2.Regexp, name string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, reg := range filters {
+		if mat, _ := reg.MatchString(name); mat {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedMember 成员是否被 exclude-filter 或 exclude-type 排除，exclude-type 与 mihomo 的出站类型名称比较，不区分大小写
+func excludedMember(filter *providerFilter, m groupMember) bool {
+	for _, reg := range filter.excludeFilters {
+		if mat, _ := reg.MatchString(m.name); mat {
+			return true
+		}
+	}
+	for _, excludeType := range filter.excludeTypes {
+		if m.adapter != "" && strings.EqualFold(m.adapter, excludeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// resultMember 以节点的测速结果作为成员
+func resultMember(r *models.CProxyWithResult) groupMember {
+	m := groupMember{name: r.Name, result: &r.Result}
+	if r.Proxy.Proxy != nil {
+		m.adapter = r.Proxy.Type().String()
+	}
+	return m
+}
+
+// nestedGroup 嵌套的代理组作为一个成员，其测速结果为该组选中的成员；load-balance 取延迟最低的有效成员
+func (e *groupEvaluator) nestedGroup(group map[string]any) (groupMember, error) {
+	name, _ := group["name"].(string)
+	if m, ok := e.selected[name]; ok {
+		return *m, nil
+	}
+	typ, _ := group["type"].(string)
+	m := &groupMember{name: name}
+	if adapter, ok := groupAdapterTypes[typ]; ok {
+		m.adapter = adapter.String()
+	}
+	if e.visiting[name] {
+		return *m, nil
+	}
+	e.visiting[name] = true
+	defer delete(e.visiting, name)
+	members, err := e.members(group)
+	if err != nil {
+		return groupMember{}, fmt.Errorf("group %s: %w", name, err)
+	}
+	if typ == "load-balance" {
+		typ = "url-test"
+	}
+	if pick := selectMember(typ, members); pick != nil {
+		m.result = pick.result
+	}
+	e.selected[name] = m
+	return *m, nil
+}
+
+func stringList(v any) []string {
+	items, _ := v.([]any)
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
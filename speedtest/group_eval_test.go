@@ -0,0 +1,199 @@
+package speedtest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+// namedCache 按节点名称返回预设的测速结果，未预设的节点视为无效
+type namedCache struct {
+	results map[string]models.Result
+}
+
+func (c *namedCache) Get(ctx context.Context, key string) (*models.CProxyWithResult, bool) {
+	result := c.results[key]
+	result.Name = key
+	return &models.CProxyWithResult{Result: result}, true
+}
+
+func (c *namedCache) Set(ctx context.Context, key string, result *models.CProxyWithResult) error {
+	return nil
+}
+
+func (c *namedCache) GenerateKey(proxy *models.CProxy) string {
+	return proxy.Name()
+}
+
+func (c *namedCache) Close() error { return nil }
+
+func TestGroupReports(t *testing.T) {
+	provider := filepath.Join(t.TempDir(), "provider.yaml")
+	require.NoError(t, os.WriteFile(provider, []byte(`proxies:
+  - {name: p1, type: ss, server: 127.0.1.1, port: 8388, cipher: aes-128-gcm, password: pass}
+  - {name: p2, type: ss, server: 127.0.1.2, port: 8388, cipher: aes-128-gcm, password: pass}
+  - {name: p3, type: ss, server: 127.0.1.3, port: 8388, cipher: aes-128-gcm, password: pass}
+`), 0644))
+	body := `proxies:
+  - {name: a, type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}
+  - {name: b, type: ss, server: 127.0.0.2, port: 8388, cipher: aes-128-gcm, password: pass}
+  - {name: c, type: ss, server: 127.0.0.3, port: 8388, cipher: aes-128-gcm, password: pass}
+proxy-providers:
+  sub:
+    type: file
+    path: ` + provider + `
+proxy-groups:
+  - {name: auto, type: url-test, proxies: [a, b, c]}
+  - {name: fb, type: fallback, proxies: [c, a, b]}
+  - {name: lb, type: load-balance, proxies: [a, b]}
+  - {name: dead, type: url-test, proxies: [c, REJECT]}
+  - {name: paid, type: url-test, use: [sub], exclude-filter: "p1"}
+  - {name: nested, type: fallback, proxies: [auto, DIRECT, a]}
+  - {name: ghost, type: fallback, proxies: [missing, a]}
+  - {name: select, type: select, proxies: [c, a]}
+  - {name: chain, type: relay, proxies: [a, b]}
+  - {name: via, type: url-test, proxies: [chain, select]}
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(body), 0644))
+
+	cache := &namedCache{results: map[string]models.Result{
+		"a":     {Delay: 300, Bandwidth: 100},
+		"b":     {Delay: 100, Bandwidth: 300},
+		"p1":    {Delay: 20},
+		"p2":    {Delay: 80},
+		"p3":    {Delay: 50},
+		"chain": {Delay: 400},
+	}}
+	tester, err := NewTest(models.Options{ConfigPath: path, Concurrent: 2, Cache: cache, Timeout: time.Second, EvaluateGroups: true})
+	require.NoError(t, err)
+	defer tester.Close()
+	assert.Nil(t, tester.GroupReports(), "no reports before testing")
+	_, err = tester.TestSpeed(context.Background())
+	require.NoError(t, err)
+
+	reports := map[string]models.GroupReport{}
+	var names []string
+	for _, r := range tester.GroupReports() {
+		assert.Equal(t, path, r.Source)
+		reports[r.Name] = r
+		names = append(names, r.Name)
+	}
+	// select 与 relay 组不在报告中
+	assert.Equal(t, []string{"auto", "fb", "lb", "dead", "paid", "nested", "ghost", "via"}, names)
+
+	assert.Equal(t, models.GroupReport{Source: path, Name: "auto", Type: "url-test", Members: 3, Alive: 2, Selected: "b", Delay: 100, Bandwidth: 300}, reports["auto"])
+	assert.Equal(t, "a", reports["fb"].Selected)
+	assert.Equal(t, uint16(300), reports["fb"].Delay)
+
+	lb := reports["lb"]
+	assert.Empty(t, lb.Selected)
+	assert.Equal(t, uint16(200), lb.Delay)
+	assert.Equal(t, float64(200), lb.Bandwidth)
+
+	dead := reports["dead"]
+	assert.False(t, dead.Healthy())
+	assert.Equal(t, 1, dead.Members, "built-in outbounds are not members")
+	assert.Empty(t, dead.Selected)
+
+	assert.Equal(t, 2, reports["paid"].Members)
+	assert.Equal(t, "p3", reports["paid"].Selected)
+
+	// 嵌套的代理组以其选中的成员参与选择
+	nested := reports["nested"]
+	assert.Equal(t, 2, nested.Members)
+	assert.Equal(t, "auto", nested.Selected)
+	assert.Equal(t, uint16(100), nested.Delay)
+
+	assert.Equal(t, []string{"missing"}, reports["ghost"].Missing)
+	assert.Equal(t, "a", reports["ghost"].Selected)
+
+	// relay 组以整条链路的结果参与选择，select 组默认选择第一个成员
+	assert.Equal(t, "chain", reports["via"].Selected)
+	assert.Equal(t, 1, reports["via"].Alive)
+}
+
+func TestGroupReportsDisabled(t *testing.T) {
+	path := writeProxyConfig(t, "a")
+	body, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, append(body, "proxy-groups:\n  - {name: auto, type: url-test, proxies: [a]}\n"...), 0644))
+
+	cache := &namedCache{results: map[string]models.Result{"a": {Delay: 100}}}
+	tester, err := NewTest(models.Options{ConfigPath: path, Cache: cache, Timeout: time.Second})
+	require.NoError(t, err)
+	defer tester.Close()
+	_, err = tester.TestSpeed(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, tester.GroupReports())
+}
+
+func TestGroupReportsFilters(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub.yaml")
+	require.NoError(t, os.WriteFile(sub, []byte(`proxies:
+  - {name: p1, type: ss, server: 127.0.1.1, port: 8388, cipher: aes-128-gcm, password: pass}
+  - {name: p2, type: ss, server: 127.0.1.2, port: 8388, cipher: aes-128-gcm, password: pass}
+  - {name: p3, type: http, server: 127.0.1.3, port: 8080}
+`), 0644))
+	other := filepath.Join(dir, "other.yaml")
+	require.NoError(t, os.WriteFile(other, []byte(`proxies:
+  - {name: q1, type: http, server: 127.0.2.1, port: 8080}
+`), 0644))
+	body := `proxies:
+  - {name: a, type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}
+  - {name: h, type: http, server: 127.0.0.2, port: 8080}
+proxy-providers:
+  sub:
+    type: file
+    path: ` + sub + `
+  other:
+    type: file
+    path: ` + other + `
+proxy-groups:
+  - {name: ordered, type: fallback, use: [sub], filter: "p3` + "`" + `p1"}
+  - {name: merged, type: fallback, use: [sub, other], filter: "q1` + "`" + `p"}
+  - {name: typed, type: fallback, include-all: true, exclude-type: "Shadowsocks"}
+  - {name: raw, type: fallback, include-all-proxies: true, exclude-type: "ss"}
+  - {name: invalid, type: url-test, use: [sub], filter: "("}
+  - {name: outer, type: fallback, proxies: [invalid, a]}
+`
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(body), 0644))
+
+	cache := &namedCache{results: map[string]models.Result{
+		"a": {Delay: 100}, "h": {Delay: 100}, "p1": {Delay: 100}, "p2": {Delay: 100}, "p3": {Delay: 100}, "q1": {Delay: 100},
+	}}
+	tester, err := NewTest(models.Options{ConfigPath: path, Cache: cache, Timeout: time.Second, EvaluateGroups: true})
+	require.NoError(t, err)
+	defer tester.Close()
+	_, err = tester.TestSpeed(context.Background())
+	require.NoError(t, err)
+
+	reports := map[string]models.GroupReport{}
+	for _, r := range tester.GroupReports() {
+		reports[r.Name] = r
+	}
+	// 多个 filter 时成员按 filter 的顺序排列，多个 provider 时整体重排
+	assert.Equal(t, "p3", reports["ordered"].Selected)
+	assert.Equal(t, 2, reports["ordered"].Members)
+	assert.Equal(t, "q1", reports["merged"].Selected)
+	assert.Equal(t, 4, reports["merged"].Members)
+
+	// exclude-type 与 mihomo 的出站类型名称比较，配置中的类型名称不生效
+	assert.Equal(t, "h", reports["typed"].Selected)
+	assert.Equal(t, 3, reports["typed"].Members)
+	assert.Equal(t, "a", reports["raw"].Selected)
+	assert.Equal(t, 2, reports["raw"].Members)
+
+	// 无效的正则作为错误报告，引用该组的代理组同样无法展开
+	assert.Contains(t, reports["invalid"].Error, "invalid filter regex")
+	assert.Zero(t, reports["invalid"].Members)
+	assert.Contains(t, reports["outer"].Error, "group invalid: invalid filter regex")
+}
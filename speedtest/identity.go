@@ -0,0 +1,118 @@
+package speedtest
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// cosmeticKeys 不影响节点身份的配置项：名称以及仅影响本地客户端行为的开关
+var cosmeticKeys = map[string]struct{}{
+	"name":       {},
+	"udp":        {},
+	"tfo":        {},
+	"mptcp":      {},
+	"user-agent": {}, // 订阅转换时会随机生成 User-Agent 请求头
+}
+
+// ProxyIdentity 返回节点的规范身份标识，由类型、服务器、端口、认证信息与传输层配置决定。
+// name、以 _ 或 x- 开头的附加字段等外观性配置不参与计算，
+// 因此同一节点改名或来自不同订阅时得到相同的标识。用于缓存键、去重与历史记录。
+// 链式节点（models.ChainKey）的标识同时包含各前置节点的标识，落地节点相同、链路不同的节点标识不同
+func ProxyIdentity(config map[string]any) string {
+	canonical := canonicalValue(config, true)
+	if chain := chainConfigs(config); len(chain) > 0 {
+		hops := make([]string, 0, len(chain))
+		for _, hop := range chain {
+			hops = append(hops, ProxyIdentity(hop))
+		}
+		canonical = map[string]any{"proxy": canonical, "chain": hops}
+	}
+	bytes, err := json.Marshal(canonical)
+	if err != nil {
+		return ""
+	}
+	hash := md5.Sum(bytes)
+	return hex.EncodeToString(hash[:])
+}
+
+// canonicalValue 规范化配置值：标量统一为字符串（8388 与 "8388" 等价），
+// 空值、false、0 与外观性字段被忽略，map 的键统一为小写字符串（json.Marshal 会排序）
+func canonicalValue(v any, top bool) any {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case map[string]any:
+		return canonicalMap(val, top)
+	case map[any]any:
+		m := make(map[string]any, len(val))
+		for k, item := range val {
+			m[fmt.Sprint(k)] = item
+		}
+		return canonicalMap(m, top)
+	case []any:
+		return canonicalSlice(len(val), func(i int) any { return val[i] })
+	case []string:
+		return canonicalSlice(len(val), func(i int) any { return val[i] })
+	case []map[string]any:
+		return canonicalSlice(len(val), func(i int) any { return val[i] })
+	case string:
+		if val == "" {
+			return nil
+		}
+		return val
+	case bool:
+		// false 与缺省等价，如 tls、skip-cert-verify
+		if !val {
+			return nil
+		}
+		return "true"
+	default:
+		s := fmt.Sprint(val)
+		// 数值 0 与缺省等价，如 alterId
+		if s == "0" {
+			return nil
+		}
+		return s
+	}
+}
+
+func canonicalMap(m map[string]any, top bool) any {
+	out := make(map[string]any, len(m))
+	for k, item := range m {
+		key := strings.ToLower(k)
+		if _, ok := cosmeticKeys[key]; ok {
+			continue
+		}
+		if top && (strings.HasPrefix(key, "_") || strings.HasPrefix(key, "x-")) {
+			continue
+		}
+		if key == "type" || key == "server" {
+			if s, ok := item.(string); ok {
+				item = strings.ToLower(s)
+			}
+		}
+		if c := canonicalValue(item, false); c != nil {
+			out[key] = c
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func canonicalSlice(size int, at func(i int) any) any {
+	out := make([]any, 0, size)
+	for i := 0; i < size; i++ {
+		if c := canonicalValue(at(i), false); c != nil {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package speedtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/metacubex/mihomo/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+func TestProxyIdentityIgnoresCosmeticFields(t *testing.T) {
+	base := map[string]any{
+		"name": "hk-01", "type": "vmess", "server": "Example.com", "port": 443,
+		"uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "alterId": 0, "cipher": "auto",
+		"network": "ws", "ws-opts": map[string]any{
+			"path":    "/ws",
+			"headers": map[string]any{"Host": "cdn.example.com", "User-Agent": "Mozilla/5.0 A"},
+		},
+	}
+	same := map[string]any{
+		"name": "🇭🇰 香港 01", "type": "vmess", "server": "example.com", "port": "443",
+		"uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "cipher": "auto", "udp": true, "tls": false,
+		"_check": map[string]any{"netflix": true}, "x-speedtest": map[string]any{"delay": 10},
+		"network": "ws", "ws-opts": map[any]any{
+			"path":    "/ws",
+			"headers": map[string]any{"Host": "cdn.example.com", "User-Agent": "Mozilla/5.0 B"},
+		},
+	}
+	assert.Equal(t, ProxyIdentity(base), ProxyIdentity(same))
+
+	for key, value := range map[string]any{
+		"server":  "other.example.com",
+		"port":    8443,
+		"uuid":    "00000000-0000-0000-0000-000000000000",
+		"network": "grpc",
+		"ws-opts": map[string]any{"path": "/other"},
+	} {
+		changed := cloneConfig(base)
+		changed[key] = value
+		assert.NotEqual(t, ProxyIdentity(base), ProxyIdentity(changed), key)
+	}
+}
+
+func TestCacheHitReturnsCurrentName(t *testing.T) {
+	cache := NewDefaultCache()
+	defer cache.Close()
+	options := &models.Options{Cache: cache}
+	ctx := context.Background()
+
+	parse := func(name string) models.CProxy {
+		config := map[string]any{
+			"name": name, "type": "ss", "server": "127.0.0.1", "port": 8388,
+			"cipher": "aes-128-gcm", "password": "secret",
+		}
+		proxy, err := adapter.ParseProxy(config)
+		require.NoError(t, err)
+		return models.CProxy{Proxy: proxy, SecretConfig: config}
+	}
+	old := parse("old name")
+	require.NoError(t, cache.Set(ctx, cache.GenerateKey(&old), &models.CProxyWithResult{
+		Result: models.Result{Name: "old name", Delay: 100},
+		Proxy:  old,
+	}))
+
+	renamed := parse("new name")
+	result, err := testspeed(ctx, renamed, options, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "new name", result.Name)
+	assert.Equal(t, uint16(100), result.Delay)
+	assert.Equal(t, "new name", result.Proxy.Name())
+
+	cached, ok := cache.Get(ctx, cache.GenerateKey(&old))
+	require.True(t, ok)
+	assert.Equal(t, "old name", cached.Name, "cached result must not be mutated")
+}
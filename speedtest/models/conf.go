@@ -26,6 +26,28 @@ type Result struct {
 	URLForTest    map[string]bool `json:"url_for_test"`
 	TestDuration  time.Duration   `json:"test_duration"`
 	DownloadBytes int64           `json:"download_bytes"`
+	Partial       bool            `json:"partial,omitempty"` // 结果不完整，如测速中途超时或解锁检测请求失败
+	Sources       []string        `json:"sources,omitempty"` // 去重策略为 merge_sources 时，节点及其重复节点的来源
+	Source        *ProxySource    `json:"source,omitempty"`  // 节点来源，直接通过 AddProxy/AddProxies 添加的节点为空
+	Chain         []string        `json:"chain,omitempty"`   // 链式节点经过的节点名称，按连接顺序排列，最后一个为落地节点；结果为整条链路的测速结果
+}
+
+// ProxySource 节点来源
+type ProxySource struct {
+	URL      string `json:"url" yaml:"url"`                               // 订阅链接或本地路径，proxy-providers 中的节点为 provider 的链接
+	Provider string `json:"provider,omitempty" yaml:"provider,omitempty"` // proxy-providers 中的 provider 名称
+	Index    int    `json:"index" yaml:"index"`                           // 节点在来源中的输出序号，从 0 开始；被 provider 过滤的节点不计入，relay 组生成的链式节点排在最后，因此不一定等于节点在文件中的位置
+}
+
+// String 返回来源的展示名称，如 "provider1 (https://example.com/sub)"
+func (s *ProxySource) String() string {
+	if s == nil {
+		return ""
+	}
+	if s.Provider != "" {
+		return s.Provider + " (" + s.URL + ")"
+	}
+	return s.URL
 }
 
 func (r *Result) Alive() bool {
@@ -82,3 +104,26 @@ func (r *Result) FormattedUrlCheck() string {
 	var rs, _ = json.Marshal(r.URLForTest)
 	return string(rs)
 }
+
+// CachedResult 可序列化的缓存结果，C.Proxy 无法序列化，读取时通过 Config 重新解析
+type CachedResult struct {
+	Key       string         `json:"key"`
+	Result    Result         `json:"result"`
+	Config    map[string]any `json:"config"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+// NewCachedResult 生成可序列化的缓存结果
+func NewCachedResult(key string, r *CProxyWithResult, expiresAt time.Time) *CachedResult {
+	return &CachedResult{
+		Key:       key,
+		Result:    r.Result,
+		Config:    r.Proxy.SecretConfig,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// Expired 是否已过期，ExpiresAt 为零值表示永不过期
+func (c *CachedResult) Expired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && now.After(c.ExpiresAt)
+}
@@ -0,0 +1,72 @@
+package models
+
+// AnnotationMode 导出节点配置时附带测速结果的方式
+type AnnotationMode string
+
+const (
+	AnnotationNone      AnnotationMode = ""          // 默认，仅导出纯净的节点配置
+	AnnotationExtension AnnotationMode = "extension" // 测速结果写入命名空间字段 AnnotationKey
+
+	// AnnotationKey 命名空间扩展字段名，mihomo 会忽略未知字段
+	AnnotationKey = "x-speedtest"
+)
+
+// ChainKey 链式节点的前置节点配置，按连接顺序排列（第一个直接连接），
+// 由 ProxySourceLoader 解析 relay 组与 dialer-proxy 时附加，导出时移除
+const ChainKey = "x-speedtest-chain"
+
+// ExportOptions 导出相关配置，每种导出格式可单独选择是否附带测速结果
+type ExportOptions struct {
+	YAMLAnnotation AnnotationMode `json:"yaml_annotation"` // WriteToYaml 导出的注解方式
+	JSONAnnotation AnnotationMode `json:"json_annotation"` // AliveProxiesToJson/ProxiesToJson 导出的注解方式
+	CSV            CSVOptions     `json:"csv"`             // WriteToCsv 导出的列与格式
+}
+
+// CSVOptions CSV 导出配置
+type CSVOptions struct {
+	// Columns 导出列，为空时使用 name、bandwidth、ttfb、delay。
+	// 可用列: name type server alive country bandwidth ttfb delay p50 p90 p95 jitter loss
+	// checks（按检测类型展开为多列）check:<type> url_tests（按 URL 展开为多列）url:<url>
+	// test_duration download_bytes source
+	Columns     []string `json:"columns"`
+	Language    string   `json:"language"`     // 表头语言，zh（默认）或 en
+	NoBOM       bool     `json:"no_bom"`       // 不写入 UTF-8 BOM，默认写入以便 Excel 正确识别编码
+	IncludeDead bool     `json:"include_dead"` // 同时导出无效节点，便于完整审计
+}
+
+// ProxyAnnotation 节点测速结果注解，与节点配置分开存放
+type ProxyAnnotation struct {
+	Delay        uint16          `json:"delay" yaml:"delay"`
+	Bandwidth    float64         `json:"bandwidth" yaml:"bandwidth"` // B/s
+	TTFB         int64           `json:"ttfb_ms" yaml:"ttfb_ms"`
+	Country      string          `json:"country,omitempty" yaml:"country,omitempty"`
+	CheckResults []CheckResult   `json:"check_results,omitempty" yaml:"check_results,omitempty"`
+	URLForTest   map[string]bool `json:"url_for_test,omitempty" yaml:"url_for_test,omitempty"`
+	Source       *ProxySource    `json:"source,omitempty" yaml:"source,omitempty"`
+}
+
+// AnnotatedProxy 纯净的节点配置及其旁路注解
+type AnnotatedProxy struct {
+	Config     map[string]any  `json:"config" yaml:"config"`
+	Annotation ProxyAnnotation `json:"annotation" yaml:"annotation"`
+}
+
+func NewProxyAnnotation(r *Result) ProxyAnnotation {
+	return ProxyAnnotation{
+		Delay:        r.Delay,
+		Bandwidth:    r.Bandwidth,
+		TTFB:         r.TTFB.Milliseconds(),
+		Country:      r.Country,
+		CheckResults: r.CheckResults,
+		URLForTest:   r.URLForTest,
+		Source:       r.Source,
+	}
+}
+
+// ExportRecord 导出用的单条测速结果，包含 Result 的全部字段
+type ExportRecord struct {
+	Result
+	Type   string         `json:"type"`
+	Server string         `json:"server"`
+	Config map[string]any `json:"config,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// GroupReport 按测速结果推断的 proxy-groups 选择结果，仅包含 url-test、fallback 与 load-balance 组
+type GroupReport struct {
+	Source string `json:"source"` // 所在配置的链接或路径
+	Name   string `json:"name"`
+	Type   string `json:"type"` // url-test、fallback 或 load-balance
+
+	Members int      `json:"members"`           // 成员数，嵌套的代理组计为一个成员，use 的 provider 与 include-all 展开为其中的节点
+	Alive   int      `json:"alive"`             // 有效成员数
+	Missing []string `json:"missing,omitempty"` // 没有测速结果的成员，如被名称正则过滤、去重丢弃或解析失败的节点
+	Error   string   `json:"error,omitempty"`   // 无法展开成员的原因，如 filter 正则无效（mihomo 同样无法加载该配置），此时其余字段为空
+
+	// Selected mihomo 会选择的成员：url-test 为延迟最低的有效成员，fallback 为第一个有效成员；
+	// load-balance 在所有有效成员间分配连接，为空
+	Selected  string  `json:"selected,omitempty"`
+	Delay     uint16  `json:"delay"`     // 组的有效延迟 (ms)：选中成员的延迟，load-balance 为有效成员的平均延迟
+	Bandwidth float64 `json:"bandwidth"` // 组的有效带宽 (B/s)：选中成员的带宽，load-balance 为有效成员的平均带宽
+}
+
+// Healthy 组内至少有一个有效成员
+func (r *GroupReport) Healthy() bool {
+	return r.Alive > 0
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestRawConfigDecodesProviderFields(t *testing.T) {
+	const body = `
+proxy-providers:
+  paid:
+    type: http
+    url: https://example.com/sub
+    path: ./providers/paid.yaml
+    proxy: DIRECT
+    interval: 3600
+    header:
+      User-Agent: [ClashX/1.0]
+    filter: "HK|SG"
+    exclude-filter: "test"
+    exclude-type: "vmess|ss"
+    override:
+      udp: true
+      skip-cert-verify: false
+      additional-prefix: "[paid] "
+      proxy-name:
+        - pattern: "IPLC-(.*?)倍"
+          target: "iplc x $1"
+`
+	var cfg RawConfig
+	if err := yaml.Unmarshal([]byte(body), &cfg); err != nil {
+		t.Fatalf("Unmarshal error = %v", err)
+	}
+	p, ok := cfg.Providers["paid"]
+	if !ok {
+		t.Fatal("provider paid not decoded")
+	}
+	if p.Type != "http" || p.Url != "https://example.com/sub" || p.Path != "./providers/paid.yaml" ||
+		p.Proxy != "DIRECT" || p.Interval != 3600 || p.Filter != "HK|SG" ||
+		p.ExcludeFilter != "test" || p.ExcludeType != "vmess|ss" {
+		t.Fatalf("unexpected provider: %+v", p)
+	}
+	if got := p.Header["User-Agent"]; len(got) != 1 || got[0] != "ClashX/1.0" {
+		t.Fatalf("header = %v", p.Header)
+	}
+	o := p.Override
+	if o.UDP == nil || !*o.UDP || o.SkipCertVerify == nil || *o.SkipCertVerify || o.TFO != nil {
+		t.Fatalf("unexpected override flags: %+v", o)
+	}
+	if o.AdditionalPrefix == nil || *o.AdditionalPrefix != "[paid] " {
+		t.Fatalf("additional-prefix = %v", o.AdditionalPrefix)
+	}
+	if len(o.ProxyName) != 1 || o.ProxyName[0].Target != "iplc x $1" {
+		t.Fatalf("proxy-name = %+v", o.ProxyName)
+	}
+}
@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// SourceStatus 配置源的加载状态
+type SourceStatus string
+
+const (
+	SourceStatusOK    SourceStatus = "ok"    // 加载并解析成功
+	SourceStatusError SourceStatus = "error" // 下载或解析失败，未贡献任何节点
+)
+
+// SourceData 配置源节点的数据来源
+type SourceData string
+
+const (
+	SourceDataLive   SourceData = "live"   // 本次下载或读取的内容
+	SourceDataCached SourceData = "cached" // 本地快照：订阅未更新（304），或下载失败、未解析出节点时回退到上一次成功的内容
+)
+
+// SourceReport 单个配置源（订阅链接、本地文件或 proxy-providers 中的 provider）的健康报告
+type SourceReport struct {
+	Source   string       `json:"source"`             // 订阅链接或本地路径
+	Provider string       `json:"provider,omitempty"` // proxy-providers 中的 provider 名称
+	Status   SourceStatus `json:"status"`             // 加载状态
+	Error    string       `json:"error,omitempty"`    // 加载失败的原因；回退到快照时为本次下载失败的原因
+	Data     SourceData   `json:"data,omitempty"`     // 节点来自本次下载的内容还是本地快照
+
+	SnapshotTime time.Time `json:"snapshot_time,omitzero"` // 使用的快照的下载时间，Data 为 cached 时有效
+
+	HTTPStatus int           `json:"http_status,omitempty"` // HTTP 状态码，本地文件为 0
+	Bytes      int64         `json:"bytes"`                 // 下载/读取的字节数
+	Format     string        `json:"format,omitempty"`      // 识别出的配置格式，如 clash、base64、uri
+	FetchTime  time.Duration `json:"fetch_time"`            // 下载/读取耗时
+
+	Subscription *SubscriptionInfo `json:"subscription,omitempty"` // 订阅流量与到期时间，来自 subscription-userinfo 响应头
+
+	Parsed        int `json:"parsed"`         // 从配置中解析出的节点数
+	Excluded      int `json:"excluded"`       // 被 provider 的 filter/exclude-filter/exclude-type 排除的节点数
+	Chains        int `json:"chains"`         // 链式节点数：解析了 dialer-proxy 的节点与 relay 组
+	ParseFailures int `json:"parse_failures"` // 无法解析为 mihomo 节点的配置数
+
+	Unsupported map[string]int `json:"unsupported,omitempty"`  // 无法转换为 mihomo 节点的节点类型及数量，如 sing-box 的 shadowtls
+	LineErrors  []string       `json:"line_errors,omitempty"`  // Surge/Loon/Quantumult X 节点列表或 YAML proxies 中无法解析的行及原因
+	ChainErrors []string       `json:"chain_errors,omitempty"` // dialer-proxy 无法解析（如引用代理组、循环引用）而被丢弃的节点与无法解析的 relay 组及原因
+	Filtered    int            `json:"filtered"`               // 被名称正则或 Options.Filter 过滤的节点数
+	Duplicates  int            `json:"duplicates"`             // 去重阶段丢弃的重复节点数
+	Tested      int            `json:"tested"`                 // 完成测速的节点数
+	Alive       int            `json:"alive"`                  // 有效节点数
+
+	MedianBandwidth float64 `json:"median_bandwidth"` // 有效节点带宽中位数，单位为 B/s
+}
+
+// Key 报告对应的来源标识，与 ProxySource.String() 一致
+func (r *SourceReport) Key() string {
+	return (&ProxySource{URL: r.Source, Provider: r.Provider}).String()
+}
+
+// SubscriptionInfo 订阅流量与到期信息，单位为字节
+type SubscriptionInfo struct {
+	Upload    int64 `json:"upload"`
+	Download  int64 `json:"download"`
+	Total     int64 `json:"total"`            // 总流量，0 表示未提供
+	Remaining int64 `json:"remaining"`        // 剩余流量，Total 为 0 时为 0
+	Expire    int64 `json:"expire,omitempty"` // 到期时间（Unix 秒），0 表示长期有效
+}
+
+// ExpireTime 到期时间，长期有效时返回零值
+func (s *SubscriptionInfo) ExpireTime() time.Time {
+	if s == nil || s.Expire <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(s.Expire, 0)
+}
+
+// ExpiresWithin 订阅是否已过期或将在 d 内过期
+func (s *SubscriptionInfo) ExpiresWithin(d time.Duration, now time.Time) bool {
+	expire := s.ExpireTime()
+	return !expire.IsZero() && expire.Sub(now) <= d
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"time"
 
@@ -21,6 +22,25 @@ const (
 	DefaultLivenessAddr = "https://github.com/aboutcode-org/scancode-toolkit/releases/download/v32.4.1/scancode-toolkit-v32.4.1_py3.13-linux.tar.gz"
 )
 
+// DedupPolicy 按节点身份（类型、服务器、端口、认证信息）去重时保留哪个节点
+type DedupPolicy string
+
+const (
+	DedupNone         DedupPolicy = ""              // 不去重
+	DedupFirst        DedupPolicy = "first"         // 保留最先出现的节点
+	DedupShortestName DedupPolicy = "shortest_name" // 保留名称最短的节点（含其配置与来源）
+	DedupMergeSources DedupPolicy = "merge_sources" // 保留最先出现的节点，并合并所有重复节点的来源
+)
+
+// Valid 是否为支持的去重策略
+func (p DedupPolicy) Valid() bool {
+	switch p {
+	case DedupNone, DedupFirst, DedupShortestName, DedupMergeSources:
+		return true
+	}
+	return false
+}
+
 type Cache interface {
 	Get(ctx context.Context, key string) (*CProxyWithResult, bool)
 	Set(ctx context.Context, key string, result *CProxyWithResult) error
@@ -29,6 +49,57 @@ type Cache interface {
 	Close() error
 }
 
+// CacheTTLPolicy 按测速结果决定缓存时长
+type CacheTTLPolicy struct {
+	AliveTTL     time.Duration `json:"alive_ttl"`     // 有效节点的缓存时长，<=0 表示不缓存有效节点
+	DeadTTL      time.Duration `json:"dead_ttl"`      // 无效节点的缓存时长（负缓存），<=0 表示不缓存无效节点
+	CachePartial bool          `json:"cache_partial"` // 是否缓存不完整的结果（如解锁检测超时），默认不缓存
+}
+
+// TTL 返回结果对应的缓存时长，ok 为 false 表示不应缓存
+func (p CacheTTLPolicy) TTL(r *Result) (ttl time.Duration, ok bool) {
+	if r.Partial && !p.CachePartial {
+		return 0, false
+	}
+	if r.Alive() {
+		ttl = p.AliveTTL
+	} else {
+		ttl = p.DeadTTL
+	}
+	return ttl, ttl > 0
+}
+
+// CacheStats 缓存命中统计
+type CacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Sets    int64 `json:"sets"`
+	Skipped int64 `json:"skipped"` // 按 CacheTTLPolicy 未缓存的结果数
+}
+
+// Sub 返回两次统计之间的差值
+func (s CacheStats) Sub(o CacheStats) CacheStats {
+	return CacheStats{
+		Hits:    s.Hits - o.Hits,
+		Misses:  s.Misses - o.Misses,
+		Sets:    s.Sets - o.Sets,
+		Skipped: s.Skipped - o.Skipped,
+	}
+}
+
+// HitRate 命中率 (0.0-1.0)
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// CacheStatsReporter 可选接口，Cache 实现后 LogNum 会输出缓存命中统计
+type CacheStatsReporter interface {
+	Stats() CacheStats
+}
+
 type ProgressConfig struct {
 	PrintProgress    bool          `json:"print_progress"`    // 是否打印进度
 	ProgressInterval time.Duration `json:"progress_interval"` // 进度打印间隔
@@ -38,9 +109,10 @@ type Options struct {
 	LivenessAddr         string           `json:"liveness_addr"`            // 测速时调用的地址，可下载的任意地址
 	DownloadSize         int              `json:"download_size"`            // 测速时下载的文件大小，单位为 bit，默认下载10M
 	Timeout              time.Duration    `json:"timeout"`                  // 每个代理测速的超时时间
-	ConfigPath           string           `json:"config_path"`              // 配置文件地址，可以为 URL 或者本地路径，多个使用 | 分隔
+	ConfigPath           string           `json:"config_path"`              // 配置文件地址，可以为 URL、本地路径或 - (标准输入)，多个使用 | 分隔
 	NameRegexContain     string           `json:"name_regex_contain"`       // 通过名字过滤代理，只测试过滤部分，格式为正则，默认全部测
 	NameRegexNonContain  string           `json:"name_regex_not_contain"`   // 通过名字过滤代理，跳过过滤部分，格式为正则
+	Filter               string           `json:"filter"`                   // 按节点配置字段与类型过滤代理的表达式，如 type in (hysteria2, tuic) && port not in (80, 443)，与名称正则同时生效
 	SortField            SortField        `json:"sort_field"`               // 排序方式，b 带宽 t 延迟
 	URLForTest           []string         `json:"url_for_test"`             // 测试 URL 是否可访问
 	ProxyUrl             string           `json:"proxy_url"`                // ConfigPath 为网络链接时可使用指定代理下载
@@ -50,7 +122,7 @@ type Options struct {
 	DisableBandwidthTest bool             `json:"disable_bandwidth_test"`   // 禁用带宽下载测速，仅保留探活/延迟/URL/解锁检查
 	MaxBandwidthMBPerSec float64          `json:"max_bandwidth_mb_per_sec"` // Test 级下载速率上限，单位 MB/s，<=0 表示不限制
 	SourceConcurrency    int              `json:"source_concurrency"`       // 配置源加载并发，默认 1，避免多个大订阅同时驻留内存
-	SourceBatchSize      int              `json:"source_batch_size"`        // 配置源加载后回调批大小，默认 200
+	SourceBatchSize      int              `json:"source_batch_size"`        // 配置源加载后回调批大小，默认 200；Clash/mihomo YAML 边下载边解析，每凑满一批即回调
 	EnableLatencyMetrics bool             `json:"enable_latency_metrics"`   // 是否采集延迟分布指标（P50/P90/P95/Jitter/LossRate）
 	LatencySamples       int              `json:"latency_samples"`          // 启用延迟分布指标后，预热请求后的真实延迟采样次数
 	ProbeTimeout         time.Duration    `json:"probe_timeout"`            // 探活超时，用于快速淘汰失效节点
@@ -58,8 +130,27 @@ type Options struct {
 	Logger               *slog.Logger     `json:"-"`                        // 日志输出，nil 时回退到 slog.Default()
 	Cache                Cache            `json:"-"`                        // 缓存实现，不序列化
 	Proxies              []map[string]any `json:"-"`                        // 支持传入 proxy 配置来测速
+	Stdin                io.Reader        `json:"-"`                        // ConfigPath 中 "-" 读取的内容，为空时不接受 "-"
 	Progress             ProgressConfig   `json:"progress"`                 // 进度配置
 	ForceCertVerify      bool             `json:"force_cert_verify"`        // 若为 true，有 skip-cert-verify 字段的节点强制设置为 false（强制验证证书）
+	RenameTemplate       string           `json:"rename_template"`          // 导出时的节点重命名模板，如 "{flag} {country} {idx} | {bandwidth} | {delay}ms"，为空则保留原名
+	Export               ExportOptions    `json:"export"`                   // 导出配置，默认导出纯净的节点配置
+	Dedup                DedupPolicy      `json:"dedup"`                    // 测速前按节点身份去重，为空则不去重，可用值请参考 DedupPolicy
+	// SourceTimeout 单个订阅从发起下载到读完内容的时间上限（边下载边测速时包含测速耗时），默认 30 分钟，< 0 表示不限制。
+	// 此外读取过程中超过 60 秒没有收到数据时下载失败
+	SourceTimeout time.Duration `json:"source_timeout"`
+	// SubscriptionExpiryWarning 订阅将在此时间内到期时输出警告，默认 7 天
+	SubscriptionExpiryWarning time.Duration `json:"subscription_expiry_warning"`
+	// SourceOptions ConfigPath 中各来源的下载与过滤选项（header、proxy、filter、override 等），
+	// 键为 ConfigPath 中的链接或路径，键 "*" 用于没有单独配置的来源
+	SourceOptions map[string]ProxyProvider `json:"source_options"`
+	// SnapshotDir 订阅快照目录，为空则不保存。设置后按 ETag/Last-Modified 发起条件请求，
+	// 下载失败或未解析出节点时回退到上一次成功的内容。与 Cache 一样由调用方设置，不从 JSON 读取，
+	// 避免请求指定服务端写入的目录
+	SnapshotDir string `json:"-"`
+	// EvaluateGroups 测速结束后按结果推断 ConfigPath 中各配置的 url-test/fallback/load-balance 组会选择的节点，
+	// 见 Test.GroupReports
+	EvaluateGroups bool `json:"evaluate_groups"`
 }
 
 // bandwidthBurstBytes is the token-bucket burst size for BandwidthLimiter.
@@ -115,6 +206,8 @@ func (b *BandwidthLimiter) Wait(ctx context.Context, bytes int64) error {
 type CProxy struct {
 	constant.Proxy
 	SecretConfig map[string]any
+	Source       *ProxySource // 节点来源，由 ProxySourceLoader 加载时附加
+	Identity     string       // 去重时按处理前的配置计算的节点身份，未去重的节点为空
 }
 
 // https://wiki.metacubex.one/en/config/proxy-providers/
@@ -124,12 +217,49 @@ type CProxy struct {
 //    url: "http://test.com"
 //    path: ./proxy_providers/provider1.yaml
 
+// ProxyProvider proxy-providers 中的 provider 配置，字段与 mihomo 一致。
+// 也用作 ConfigPath 中每个来源的下载与过滤选项，见 Options.SourceOptions
 type ProxyProvider struct {
-	Url string `json:"url"`
-	// 支持自定义 header，后续再加吧
+	Type          string              `json:"type,omitempty" yaml:"type"`
+	Url           string              `json:"url" yaml:"url"`
+	Path          string              `json:"path,omitempty" yaml:"path"`         // type 为 file 时的配置文件路径，相对路径相对所在配置解析
+	Proxy         string              `json:"proxy,omitempty" yaml:"proxy"`       // 下载时使用的代理：DIRECT、代理链接或所在配置中的节点名称
+	Interval      int                 `json:"interval,omitempty" yaml:"interval"` // 更新间隔（秒），仅为兼容 mihomo 配置，单次测速不使用
+	Header        map[string][]string `json:"header,omitempty" yaml:"header"`     // 下载时的请求头，可覆盖默认的 User-Agent: clash-meta
+	Filter        string              `json:"filter,omitempty" yaml:"filter"`
+	ExcludeFilter string              `json:"exclude-filter,omitempty" yaml:"exclude-filter"`
+	ExcludeType   string              `json:"exclude-type,omitempty" yaml:"exclude-type"`
+	DialerProxy   string              `json:"dialer-proxy,omitempty" yaml:"dialer-proxy"`
+	Override      ProviderOverride    `json:"override,omitempty" yaml:"override"`
+}
+
+// ProviderOverride provider 的 override 配置，为空的字段不覆盖节点配置
+type ProviderOverride struct {
+	TFO            *bool   `json:"tfo,omitempty" yaml:"tfo"`
+	MPTcp          *bool   `json:"mptcp,omitempty" yaml:"mptcp"`
+	UDP            *bool   `json:"udp,omitempty" yaml:"udp"`
+	UDPOverTCP     *bool   `json:"udp-over-tcp,omitempty" yaml:"udp-over-tcp"`
+	Up             *string `json:"up,omitempty" yaml:"up"`
+	Down           *string `json:"down,omitempty" yaml:"down"`
+	DialerProxy    *string `json:"dialer-proxy,omitempty" yaml:"dialer-proxy"`
+	SkipCertVerify *bool   `json:"skip-cert-verify,omitempty" yaml:"skip-cert-verify"`
+	Interface      *string `json:"interface-name,omitempty" yaml:"interface-name"`
+	RoutingMark    *int    `json:"routing-mark,omitempty" yaml:"routing-mark"`
+	IPVersion      *string `json:"ip-version,omitempty" yaml:"ip-version"`
+
+	AdditionalPrefix *string                 `json:"additional-prefix,omitempty" yaml:"additional-prefix"`
+	AdditionalSuffix *string                 `json:"additional-suffix,omitempty" yaml:"additional-suffix"`
+	ProxyName        []OverrideProxyNameRule `json:"proxy-name,omitempty" yaml:"proxy-name"`
+}
+
+// OverrideProxyNameRule 按正则替换节点名称
+type OverrideProxyNameRule struct {
+	Pattern string `json:"pattern" yaml:"pattern"`
+	Target  string `json:"target" yaml:"target"`
 }
 
 type RawConfig struct {
-	Providers map[string]ProxyProvider `yaml:"proxy-providers"`
-	Proxies   []map[string]any         `yaml:"proxies"`
+	Providers   map[string]ProxyProvider `yaml:"proxy-providers"`
+	Proxies     []map[string]any         `yaml:"proxies"`
+	ProxyGroups []map[string]any         `yaml:"proxy-groups"` // 仅 relay 组会作为链式节点测速
 }
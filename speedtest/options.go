@@ -71,6 +71,12 @@ func normalizeOptions(options *models.Options) (bool, string) {
 	if options.Progress.ProgressInterval <= 0 {
 		options.Progress.ProgressInterval = 3 * time.Second
 	}
+	if options.SubscriptionExpiryWarning <= 0 {
+		options.SubscriptionExpiryWarning = defaultSubscriptionExpiryWarning
+	}
+	if !options.Dedup.Valid() {
+		return false, fmt.Sprintf("不支持的去重策略: %s", options.Dedup)
+	}
 
 	return true, ""
 }
@@ -0,0 +1,194 @@
+package speedtest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dlclark/regexp2"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+// providerFilter 按 mihomo 的规则对 provider 中的节点进行过滤与覆写：
+// filter/exclude-filter 以 ` 分隔多个正则（regexp2 语法），exclude-type 以 | 分隔且不区分大小写，
+// 同一 provider 内同名节点只保留第一个，随后依次应用 dialer-proxy 与 override
+type providerFilter struct {
+	filter         string
+	filters        []*regexp2.Regexp
+	excludeFilters []*regexp2.Regexp
+	excludeTypes   []string
+	dialerProxy    string
+	override       models.ProviderOverride
+	proxyNames     []proxyNameRule
+}
+
+type proxyNameRule struct {
+	pattern *regexp2.Regexp
+	target  string
+}
+
+// newProviderFilter 编译 provider 的过滤与覆写选项
+func newProviderFilter(options models.ProxyProvider) (*providerFilter, error) {
+	f := &providerFilter{
+		filter:      options.Filter,
+		dialerProxy: options.DialerProxy,
+		override:    options.Override,
+	}
+	if options.ExcludeType != "" {
+		f.excludeTypes = strings.Split(options.ExcludeType, "|")
+	}
+	if options.ExcludeFilter != "" {
+		for _, expr := range strings.Split(options.ExcludeFilter, "`") {
+			reg, err := regexp2.Compile(expr, regexp2.None)
+			if err != nil {
+				return nil, fmt.Errorf("invalid exclude-filter regex: %w", err)
+			}
+			f.excludeFilters = append(f.excludeFilters, reg)
+		}
+	}
+	// filter 为空时仍编译一个空正则，与 mihomo 一致地执行一轮遍历
+	for _, expr := range strings.Split(options.Filter, "`") {
+		reg, err := regexp2.Compile(expr, regexp2.None)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter regex: %w", err)
+		}
+		f.filters = append(f.filters, reg)
+	}
+	for _, rule := range options.Override.ProxyName {
+		reg, err := regexp2.Compile(rule.Pattern, regexp2.None)
+		if err != nil {
+			return nil, fmt.Errorf("invalid override proxy-name pattern: %w", err)
+		}
+		f.proxyNames = append(f.proxyNames, proxyNameRule{pattern: reg, target: rule.Target})
+	}
+	return f, nil
+}
+
+// apply 返回过滤并覆写后的节点，多个 filter 时按 filter 的顺序输出匹配的节点
+func (f *providerFilter) apply(proxies []map[string]any) ([]map[string]any, error) {
+	out := make([]map[string]any, 0, len(proxies))
+	seen := make(map[string]struct{}, len(proxies))
+	for _, filterReg := range f.filters {
+		for idx, mapping := range proxies {
+			ok, err := f.admit(filterReg, mapping, seen)
+			if err != nil {
+				return nil, fmt.Errorf("proxy %d override error: %w", idx, err)
+			}
+			if ok {
+				out = append(out, mapping)
+			}
+		}
+	}
+	return out, nil
+}
+
+// streamable 只有一个 filter 时输出顺序与输入一致，可以逐个节点处理
+func (f *providerFilter) streamable() bool {
+	return len(f.filters) == 1
+}
+
+// admit 按单个 filter 判断节点是否保留，保留时应用 dialer-proxy 与 override；seen 记录已保留的节点名称
+func (f *providerFilter) admit(filterReg *regexp2.Regexp, mapping map[string]any, seen map[string]struct{}) (bool, error) {
+	if f.excluded(mapping) {
+		return false, nil
+	}
+	name, _ := mapping["name"].(string)
+	if f.filter != "" {
+		if mat, _ := filterReg.MatchString(name); !mat {
+			return false, nil
+		}
+	}
+	if _, ok := seen[name]; ok {
+		return false, nil
+	}
+	if err := f.rewrite(mapping); err != nil {
+		return false, err
+	}
+	seen[name] = struct{}{}
+	return true, nil
+}
+
+// rewrite 对保留的节点应用 dialer-proxy 与 override
+func (f *providerFilter) rewrite(mapping map[string]any) error {
+	if f.dialerProxy != "" {
+		mapping["dialer-proxy"] = f.dialerProxy
+	}
+	return f.applyOverride(mapping)
+}
+
+// excluded 节点是否被 exclude-type/exclude-filter 排除，没有名称的节点同样被排除
+func (f *providerFilter) excluded(mapping map[string]any) bool {
+	if len(f.excludeTypes) > 0 {
+		tp, ok := mapping["type"].(string)
+		if !ok {
+			return true
+		}
+		for _, excludeType := range f.excludeTypes {
+			if strings.EqualFold(tp, excludeType) {
+				return true
+			}
+		}
+	}
+	name, ok := mapping["name"].(string)
+	if !ok {
+		return true
+	}
+	for _, reg := range f.excludeFilters {
+		if mat, _ := reg.MatchString(name); mat {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *providerFilter) applyOverride(mapping map[string]any) error {
+	o := f.override
+	if o.TFO != nil {
+		mapping["tfo"] = *o.TFO
+	}
+	if o.MPTcp != nil {
+		mapping["mptcp"] = *o.MPTcp
+	}
+	if o.UDP != nil {
+		mapping["udp"] = *o.UDP
+	}
+	if o.UDPOverTCP != nil {
+		mapping["udp-over-tcp"] = *o.UDPOverTCP
+	}
+	if o.Up != nil {
+		mapping["up"] = *o.Up
+	}
+	if o.Down != nil {
+		mapping["down"] = *o.Down
+	}
+	if o.DialerProxy != nil {
+		mapping["dialer-proxy"] = *o.DialerProxy
+	}
+	if o.SkipCertVerify != nil {
+		mapping["skip-cert-verify"] = *o.SkipCertVerify
+	}
+	if o.Interface != nil {
+		mapping["interface-name"] = *o.Interface
+	}
+	if o.RoutingMark != nil {
+		mapping["routing-mark"] = *o.RoutingMark
+	}
+	if o.IPVersion != nil {
+		mapping["ip-version"] = *o.IPVersion
+	}
+
+	for _, rule := range f.proxyNames {
+		name, _ := mapping["name"].(string)
+		newName, err := rule.pattern.Replace(name, rule.target, 0, -1)
+		if err != nil {
+			return fmt.Errorf("proxy name replace error: %w", err)
+		}
+		mapping["name"] = newName
+	}
+	if o.AdditionalPrefix != nil {
+		mapping["name"] = fmt.Sprintf("%s%s", *o.AdditionalPrefix, mapping["name"])
+	}
+	if o.AdditionalSuffix != nil {
+		mapping["name"] = fmt.Sprintf("%s%s", mapping["name"], *o.AdditionalSuffix)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package speedtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+func filterTestProxies() []map[string]any {
+	return []map[string]any{
+		{"name": "hk-01", "type": "ss"},
+		{"name": "jp-01", "type": "vmess"},
+		{"name": "hk-02", "type": "trojan"},
+		{"name": "us-01", "type": "ss"},
+		{"name": "hk-01", "type": "vmess"},
+		{"type": "ss"},
+	}
+}
+
+func proxyNames(proxies []map[string]any) string {
+	names := make([]string, 0, len(proxies))
+	for _, proxy := range proxies {
+		names = append(names, fmt.Sprint(proxy["name"]))
+	}
+	return sprintStrings(names)
+}
+
+func TestProviderFilter(t *testing.T) {
+	prefix, suffix, udp := "[p] ", " *", true
+	for _, tc := range []struct {
+		name    string
+		options models.ProxyProvider
+		want    string
+	}{
+		{name: "empty", want: "hk-01,jp-01,hk-02,us-01"},
+		{name: "filter", options: models.ProxyProvider{Filter: "^hk"}, want: "hk-01,hk-02"},
+		// 多个 filter 时按 filter 顺序输出
+		{name: "multiple filters", options: models.ProxyProvider{Filter: "us`jp`hk"}, want: "us-01,jp-01,hk-01,hk-02"},
+		{name: "exclude filter", options: models.ProxyProvider{ExcludeFilter: "02`us"}, want: "hk-01,jp-01"},
+		{name: "exclude type", options: models.ProxyProvider{ExcludeType: "VMess|trojan"}, want: "hk-01,us-01"},
+		{name: "override name", options: models.ProxyProvider{Override: models.ProviderOverride{
+			ProxyName:        []models.OverrideProxyNameRule{{Pattern: "-(\\d+)", Target: " $1"}},
+			AdditionalPrefix: &prefix,
+			AdditionalSuffix: &suffix,
+			UDP:              &udp,
+		}}, want: "[p] hk 01 *,[p] jp 01 *,[p] hk 02 *,[p] us 01 *"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := newProviderFilter(tc.options)
+			if err != nil {
+				t.Fatalf("newProviderFilter error = %v", err)
+			}
+			got, err := filter.apply(filterTestProxies())
+			if err != nil {
+				t.Fatalf("apply error = %v", err)
+			}
+			if names := proxyNames(got); names != tc.want {
+				t.Fatalf("names = %s, want %s", names, tc.want)
+			}
+			if tc.options.Override.UDP != nil && got[0]["udp"] != true {
+				t.Fatalf("udp override not applied: %v", got[0])
+			}
+		})
+	}
+}
+
+func TestProviderFilterDialerProxy(t *testing.T) {
+	override := "relay-b"
+	filter, err := newProviderFilter(models.ProxyProvider{DialerProxy: "relay-a"})
+	if err != nil {
+		t.Fatalf("newProviderFilter error = %v", err)
+	}
+	got, _ := filter.apply(filterTestProxies()[:1])
+	if got[0]["dialer-proxy"] != "relay-a" {
+		t.Fatalf("dialer-proxy = %v, want relay-a", got[0]["dialer-proxy"])
+	}
+	// override.dialer-proxy 在 provider 的 dialer-proxy 之后应用
+	filter, _ = newProviderFilter(models.ProxyProvider{DialerProxy: "relay-a", Override: models.ProviderOverride{DialerProxy: &override}})
+	got, _ = filter.apply(filterTestProxies()[:1])
+	if got[0]["dialer-proxy"] != "relay-b" {
+		t.Fatalf("dialer-proxy = %v, want relay-b", got[0]["dialer-proxy"])
+	}
+}
+
+func TestProviderFilterInvalidRegex(t *testing.T) {
+	for _, options := range []models.ProxyProvider{
+		{Filter: "("},
+		{ExcludeFilter: "hk`("},
+		{Override: models.ProviderOverride{ProxyName: []models.OverrideProxyNameRule{{Pattern: "["}}}},
+	} {
+		if _, err := newProviderFilter(options); err == nil {
+			t.Fatalf("expected error for %+v", options)
+		}
+	}
+}
@@ -0,0 +1,481 @@
+package speedtest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Surge、Loon 与 Quantumult X 的节点列表格式：
+//
+//	Surge:        name = ss, host, port, encrypt-method=aes-128-gcm, password=pwd, udp-relay=true
+//	Loon:         name = Shadowsocks, host, port, aes-128-gcm, "pwd", udp=true
+//	Quantumult X: shadowsocks=host:port, method=aes-128-gcm, password=pwd, tag=name
+const (
+	sourceFormatSurge sourceFormat = "surge"
+	sourceFormatLoon  sourceFormat = "loon"
+	sourceFormatQuanX sourceFormat = "quantumult-x"
+)
+
+var (
+	surgeLinePattern = regexp.MustCompile(`(?i)^[^=,\[]+=\s*(ss|shadowsocks|vmess|vless|trojan|http|https|socks5|socks5-tls|snell|tuic|tuic-v5|hysteria2)\s*,`)
+	quanXLinePattern = regexp.MustCompile(`(?i)^(shadowsocks|vmess|vless|trojan|http|socks5)\s*=\s*[^,\s]+:\d+\s*(,|$)`)
+	// surgeNonProxyTypes [Proxy] 中不代表节点的类型，解析时直接忽略
+	surgeNonProxyTypes = map[string]bool{"direct": true, "reject": true, "reject-tinygif": true, "reject-drop": true}
+)
+
+// proxyListLine 节点列表中的一行，line 为从 1 开始的行号
+type proxyListLine struct {
+	line int
+	text string
+}
+
+// proxyListLines 返回节点列表中需要解析的行，跳过空行与注释；
+// 内容包含 [Section] 时仅返回 [Proxy]（Surge/Loon）与 [server_local]（Quantumult X）中的行
+func proxyListLines(body []byte) []proxyListLine {
+	var lines []proxyListLine
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(body)+1)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";") || strings.HasPrefix(text, "//") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			section = strings.ToLower(strings.Trim(text, "[] "))
+			continue
+		}
+		if section != "" && section != "proxy" && section != "server_local" {
+			continue
+		}
+		lines = append(lines, proxyListLine{line: n, text: text})
+	}
+	return lines
+}
+
+// sniffProxyList 节点行占多数时识别为 Surge/Loon/Quantumult X 节点列表
+func sniffProxyList(body []byte) (sourceFormat, bool) {
+	var surge, quanX, others int
+	for _, line := range proxyListLines(body) {
+		switch {
+		case quanXLinePattern.MatchString(line.text):
+			quanX++
+		case surgeLinePattern.MatchString(line.text):
+			surge++
+		default:
+			others++
+		}
+	}
+	switch {
+	case quanX > 0 && quanX > surge+others:
+		return sourceFormatQuanX, true
+	case surge > 0 && surge > quanX+others:
+		// Loon 与 Surge 的结构相同，解析时按凭据的写法区分
+		return sourceFormatSurge, true
+	}
+	return sourceFormatUnknown, false
+}
+
+// parseProxyList 解析 Surge/Loon/Quantumult X 节点列表，无法解析的行记录为 "line N: 原因"。
+// 列表中出现 Loon 风格的行（凭据按位置给出）时格式记为 loon
+func parseProxyList(body []byte, format sourceFormat) (sourceFormat, []map[string]any, []string) {
+	var proxies []map[string]any
+	var lineErrors []string
+	loon := false
+	for _, line := range proxyListLines(body) {
+		var proxy map[string]any
+		var err error
+		if format == sourceFormatQuanX {
+			proxy, err = parseQuanXLine(line.text)
+		} else {
+			var isLoon bool
+			proxy, isLoon, err = parseSurgeLine(line.text)
+			loon = loon || isLoon
+		}
+		if err != nil {
+			lineErrors = append(lineErrors, fmt.Sprintf("line %d: %s", line.line, err))
+			continue
+		}
+		if proxy != nil {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if loon {
+		format = sourceFormatLoon
+	}
+	return format, proxies, lineErrors
+}
+
+// lineFields 解析后的一行，positional 为类型、服务器、端口之后不含 = 的字段
+type lineFields struct {
+	positional []string
+	options    map[string]string
+}
+
+// splitLineFields 以逗号分隔字段，双引号包裹的值中可以包含逗号，引号保留由 parseLineFields 处理
+func splitLineFields(s string) []string {
+	var fields []string
+	var b strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			b.WriteRune(r)
+		case r == ',' && !quoted:
+			fields = append(fields, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return append(fields, strings.TrimSpace(b.String()))
+}
+
+func parseLineFields(fields []string) lineFields {
+	lf := lineFields{options: make(map[string]string)}
+	for _, field := range fields {
+		if field == "" {
+			continue
+		}
+		// 双引号包裹的字段（如 Loon 的密码）总是位置参数，其中的 = 不作为分隔符
+		if strings.HasPrefix(field, `"`) {
+			lf.positional = append(lf.positional, strings.Trim(field, `"`))
+			continue
+		}
+		k, v, ok := strings.Cut(field, "=")
+		if !ok {
+			lf.positional = append(lf.positional, field)
+			continue
+		}
+		lf.options[strings.ToLower(strings.TrimSpace(k))] = strings.Trim(strings.TrimSpace(v), `"`)
+	}
+	return lf
+}
+
+// get 返回第一个存在的键对应的值
+func (lf lineFields) get(keys ...string) string {
+	for _, key := range keys {
+		if v, ok := lf.options[key]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func (lf lineFields) bool(keys ...string) bool {
+	b, _ := strconv.ParseBool(lf.get(keys...))
+	return b
+}
+
+// arg 返回第 i 个位置参数，不存在时返回按键查找的值；hasArg 表示使用了位置参数
+func (lf lineFields) arg(i int, keys ...string) (value string, hasArg bool) {
+	if v := lf.get(keys...); v != "" {
+		return v, false
+	}
+	if i < len(lf.positional) {
+		return lf.positional[i], true
+	}
+	return "", false
+}
+
+// parseSurgeLine 解析 Surge/Loon 的 "name = type, server, port, ..." 行，loon 表示凭据按位置给出
+func parseSurgeLine(text string) (proxy map[string]any, loon bool, err error) {
+	name, rest, ok := strings.Cut(text, "=")
+	if !ok {
+		return nil, false, fmt.Errorf("missing '='")
+	}
+	fields := splitLineFields(rest)
+	for i := 0; i < len(fields) && i < 3; i++ {
+		fields[i] = strings.Trim(fields[i], `"`)
+	}
+	tp := strings.ToLower(fields[0])
+	if surgeNonProxyTypes[tp] {
+		return nil, false, nil
+	}
+	if len(fields) < 3 {
+		return nil, false, fmt.Errorf("%s: missing server or port", tp)
+	}
+	port, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: invalid port %q", tp, fields[2])
+	}
+	lf := parseLineFields(fields[3:])
+	config := map[string]any{
+		"name":   strings.TrimSpace(name),
+		"server": fields[1],
+		"port":   port,
+	}
+	if lf.bool("udp-relay", "udp") {
+		config["udp"] = true
+	}
+	if lf.bool("tfo", "fast-open") {
+		config["tfo"] = true
+	}
+	if lf.bool("skip-cert-verify") {
+		config["skip-cert-verify"] = true
+	}
+	sni := lf.get("sni", "tls-name")
+
+	var positional bool
+	switch tp {
+	case "ss", "shadowsocks":
+		config["type"] = "ss"
+		cipher, p1 := lf.arg(0, "encrypt-method")
+		password, p2 := lf.arg(1, "password")
+		config["cipher"], config["password"] = cipher, password
+		positional = p1 || p2
+		switch obfs := lf.get("obfs", "obfs-name"); obfs {
+		case "":
+		case "http", "tls":
+			config["plugin"] = "obfs"
+			config["plugin-opts"] = map[string]any{"mode": obfs, "host": lf.get("obfs-host")}
+		default:
+			return nil, false, fmt.Errorf("ss: unsupported obfs %s", obfs)
+		}
+	case "vmess":
+		config["type"] = "vmess"
+		// Loon 为 "vmess, server, port, cipher, uuid"
+		if len(lf.positional) >= 2 && lf.get("username") == "" {
+			config["cipher"], config["uuid"] = lf.positional[0], lf.positional[1]
+			positional = true
+		} else {
+			config["uuid"] = lf.get("username")
+			config["cipher"] = "auto"
+			if method := lf.get("encrypt-method"); method != "" {
+				config["cipher"] = method
+			}
+		}
+		config["alterId"], _ = strconv.Atoi(lf.get("alterid"))
+		applyLineTLS(config, lf, "servername", sni)
+		applyLineTransport(config, lf)
+	case "vless":
+		config["type"] = "vless"
+		uuid, p := lf.arg(0, "username", "uuid")
+		config["uuid"] = uuid
+		positional = p
+		setNonEmpty(config, "flow", lf.get("flow"))
+		applyLineTLS(config, lf, "servername", sni)
+		if pub := lf.get("public-key"); pub != "" {
+			config["tls"] = true
+			config["reality-opts"] = map[string]any{"public-key": pub, "short-id": lf.get("short-id")}
+		}
+		applyLineTransport(config, lf)
+	case "trojan":
+		config["type"] = "trojan"
+		password, p := lf.arg(0, "password")
+		config["password"] = password
+		positional = p
+		setNonEmpty(config, "sni", sni)
+		applyLineTransport(config, lf)
+	case "http", "https", "socks5", "socks5-tls":
+		config["type"] = "http"
+		if strings.HasPrefix(tp, "socks5") {
+			config["type"] = "socks5"
+		}
+		// Surge 与 Loon 的用户名、密码均按位置给出
+		username, _ := lf.arg(0, "username")
+		password, _ := lf.arg(1, "password")
+		setNonEmpty(config, "username", username)
+		setNonEmpty(config, "password", password)
+		if tp == "https" || tp == "socks5-tls" || lf.bool("over-tls", "tls") {
+			config["tls"] = true
+			setNonEmpty(config, "sni", sni)
+		}
+	case "snell":
+		config["type"] = "snell"
+		config["psk"] = lf.get("psk")
+		if version := lf.get("version"); version != "" {
+			config["version"], _ = strconv.Atoi(version)
+		}
+		if obfs := lf.get("obfs"); obfs != "" {
+			config["obfs-opts"] = map[string]any{"mode": obfs, "host": lf.get("obfs-host")}
+		}
+	case "tuic", "tuic-v5":
+		config["type"] = "tuic"
+		if token := lf.get("token"); token != "" && tp == "tuic" {
+			config["token"] = token
+		} else {
+			config["uuid"] = lf.get("uuid")
+			config["password"] = lf.get("password")
+		}
+		setNonEmpty(config, "sni", sni)
+		if alpn := lf.get("alpn"); alpn != "" {
+			config["alpn"] = strings.Split(alpn, "|")
+		}
+	case "hysteria2":
+		config["type"] = "hysteria2"
+		password, p := lf.arg(0, "password")
+		config["password"] = password
+		positional = p
+		setNonEmpty(config, "sni", sni)
+		if down := lf.get("download-bandwidth"); down != "" {
+			config["down"] = down
+		}
+	default:
+		return nil, false, fmt.Errorf("unsupported type %s", tp)
+	}
+	return config, positional, nil
+}
+
+// applyLineTLS Surge 的 tls=true 与 Loon 的 over-tls=true
+func applyLineTLS(config map[string]any, lf lineFields, sniKey, sni string) {
+	if lf.bool("tls", "over-tls") {
+		config["tls"] = true
+		setNonEmpty(config, sniKey, sni)
+	}
+}
+
+// applyLineTransport Surge 的 ws=true/ws-path/ws-headers 与 Loon 的 transport=ws/path/host
+func applyLineTransport(config map[string]any, lf lineFields) {
+	if !lf.bool("ws") && !strings.EqualFold(lf.get("transport"), "ws") {
+		return
+	}
+	opts := map[string]any{}
+	setNonEmpty(opts, "path", lf.get("ws-path", "path"))
+	host := lf.get("host")
+	// ws-headers 形如 "Host:example.com|User-Agent:xxx"
+	for _, header := range strings.Split(lf.get("ws-headers"), "|") {
+		if k, v, ok := strings.Cut(header, ":"); ok && strings.EqualFold(strings.TrimSpace(k), "host") {
+			host = strings.TrimSpace(v)
+		}
+	}
+	if host != "" {
+		opts["headers"] = map[string]any{"Host": host}
+	}
+	config["network"] = "ws"
+	config["ws-opts"] = opts
+}
+
+// parseQuanXLine 解析 Quantumult X 的 "type=server:port, key=value, ..., tag=name" 行
+func parseQuanXLine(text string) (map[string]any, error) {
+	fields := splitLineFields(text)
+	tp, address, _ := strings.Cut(fields[0], "=")
+	tp = strings.ToLower(strings.TrimSpace(tp))
+	host, portText, err := net.SplitHostPort(strings.TrimSpace(address))
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid address %q", tp, address)
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid port %q", tp, portText)
+	}
+	lf := parseLineFields(fields[1:])
+	name := lf.get("tag")
+	if name == "" {
+		name = net.JoinHostPort(host, portText)
+	}
+	config := map[string]any{
+		"name":   name,
+		"server": host,
+		"port":   port,
+	}
+	if lf.bool("udp-relay") {
+		config["udp"] = true
+	}
+	if lf.bool("fast-open") {
+		config["tfo"] = true
+	}
+	// tls-verification=false 时跳过证书校验
+	if v := lf.get("tls-verification"); v != "" && !lf.bool("tls-verification") {
+		config["skip-cert-verify"] = true
+	}
+	obfs := strings.ToLower(lf.get("obfs"))
+	tlsHost := lf.get("tls-host")
+
+	switch tp {
+	case "shadowsocks":
+		config["type"] = "ss"
+		config["cipher"] = lf.get("method")
+		config["password"] = lf.get("password")
+		switch obfs {
+		case "":
+		case "http", "tls":
+			config["plugin"] = "obfs"
+			config["plugin-opts"] = map[string]any{"mode": obfs, "host": lf.get("obfs-host")}
+		case "ws", "wss":
+			opts := map[string]any{"mode": "websocket", "host": lf.get("obfs-host"), "path": lf.get("obfs-uri")}
+			if obfs == "wss" {
+				opts["tls"] = true
+			}
+			config["plugin"] = "v2ray-plugin"
+			config["plugin-opts"] = opts
+		default:
+			return nil, fmt.Errorf("shadowsocks: unsupported obfs %s", obfs)
+		}
+	case "vmess", "vless":
+		config["type"] = tp
+		config["uuid"] = lf.get("password")
+		if tp == "vmess" {
+			config["cipher"] = lf.get("method")
+			config["alterId"] = 0
+		}
+		setNonEmpty(config, "flow", lf.get("vless-flow"))
+		if err := applyQuanXObfs(config, lf, obfs, "servername", tlsHost); err != nil {
+			return nil, fmt.Errorf("%s: %w", tp, err)
+		}
+		if pub := lf.get("reality-base64-pubkey"); pub != "" {
+			config["tls"] = true
+			config["reality-opts"] = map[string]any{"public-key": pub, "short-id": lf.get("reality-hex-shortid")}
+		}
+	case "trojan":
+		config["type"] = "trojan"
+		config["password"] = lf.get("password")
+		if err := applyQuanXObfs(config, lf, obfs, "sni", tlsHost); err != nil {
+			return nil, fmt.Errorf("%s: %w", tp, err)
+		}
+		// trojan 总是使用 TLS，mihomo 中无需 tls 字段
+		delete(config, "tls")
+	case "http", "socks5":
+		config["type"] = tp
+		setNonEmpty(config, "username", lf.get("username"))
+		setNonEmpty(config, "password", lf.get("password"))
+		if lf.bool("over-tls") {
+			config["tls"] = true
+			setNonEmpty(config, "sni", tlsHost)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %s", tp)
+	}
+	return config, nil
+}
+
+// applyQuanXObfs 转换 vmess/vless/trojan 的 obfs：over-tls、ws、wss、http
+func applyQuanXObfs(config map[string]any, lf lineFields, obfs, sniKey, tlsHost string) error {
+	if obfs == "over-tls" || obfs == "wss" || lf.bool("over-tls") {
+		config["tls"] = true
+		if tlsHost == "" {
+			tlsHost = lf.get("obfs-host")
+		}
+		setNonEmpty(config, sniKey, tlsHost)
+	}
+	switch obfs {
+	case "", "over-tls":
+	case "ws", "wss":
+		opts := map[string]any{}
+		setNonEmpty(opts, "path", lf.get("obfs-uri"))
+		if host := lf.get("obfs-host"); host != "" {
+			opts["headers"] = map[string]any{"Host": host}
+		}
+		config["network"] = "ws"
+		config["ws-opts"] = opts
+	case "http":
+		opts := map[string]any{}
+		if path := lf.get("obfs-uri"); path != "" {
+			opts["path"] = []string{path}
+		}
+		if host := lf.get("obfs-host"); host != "" {
+			opts["headers"] = map[string]any{"Host": []string{host}}
+		}
+		config["network"] = "http"
+		config["http-opts"] = opts
+	default:
+		return fmt.Errorf("unsupported obfs %s", obfs)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package speedtest
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/metacubex/mihomo/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testUUID = "b831381d-6324-4d53-ad4f-8cda48b30811"
+
+func TestParseSurgeList(t *testing.T) {
+	body := `[General]
+loglevel = notify
+
+[Proxy]
+DIRECT = direct
+# comment
+ss-01 = ss, 1.1.1.1, 8388, encrypt-method=aes-128-gcm, password=pass, obfs=http, obfs-host=bing.com, udp-relay=true
+vmess-ws = vmess, vmess.example.com, 443, username=` + testUUID + `, ws=true, ws-path=/ws, ws-headers=Host:cdn.example.com, tls=true, sni=sni.example.com, vmess-aead=true
+trojan = trojan, 3.3.3.3, 443, password=secret, sni=t.example.com, skip-cert-verify=true
+https = https, 4.4.4.4, 443, user, pass
+broken = ss, 5.5.5.5, port
+
+[Rule]
+FINAL,DIRECT
+`
+	parsed, err := parseSourceContent([]byte(body))
+	require.NoError(t, err)
+	assert.Equal(t, sourceFormatSurge, parsed.format)
+	assert.Equal(t, []string{`line 11: ss: invalid port "port"`}, parsed.lineErrors)
+	proxies := parsed.config.Proxies
+	require.Len(t, proxies, 4)
+
+	assert.Equal(t, map[string]any{
+		"name": "ss-01", "type": "ss", "server": "1.1.1.1", "port": 8388, "udp": true,
+		"cipher": "aes-128-gcm", "password": "pass",
+		"plugin": "obfs", "plugin-opts": map[string]any{"mode": "http", "host": "bing.com"},
+	}, proxies[0])
+	assert.Equal(t, "ws", proxies[1]["network"])
+	assert.Equal(t, map[string]any{"path": "/ws", "headers": map[string]any{"Host": "cdn.example.com"}}, proxies[1]["ws-opts"])
+	assert.Equal(t, "sni.example.com", proxies[1]["servername"])
+	assert.Equal(t, "t.example.com", proxies[2]["sni"])
+	assert.Equal(t, map[string]any{
+		"name": "https", "type": "http", "server": "4.4.4.4", "port": 443,
+		"username": "user", "password": "pass", "tls": true,
+	}, proxies[3])
+
+	for _, proxy := range proxies {
+		_, err := adapter.ParseProxy(proxy)
+		assert.NoError(t, err, proxy["name"])
+	}
+}
+
+func TestParseLoonList(t *testing.T) {
+	body := `ss = Shadowsocks, 1.1.1.1, 8388, aes-128-gcm, "pa,ss=", udp=true
+vmess = vmess, 2.2.2.2, 443, auto, "` + testUUID + `", transport=ws, path=/ws, host=cdn.example.com, over-tls=true, tls-name=sni.example.com
+vless = VLESS, 3.3.3.3, 443, "` + testUUID + `", flow=xtls-rprx-vision, over-tls=true, sni=www.apple.com, public-key=pubkey, short-id=0123
+hy2 = Hysteria2, 4.4.4.4, 8443, "hypass", tls-name=h.example.com
+`
+	// Loon 订阅通常以 base64 提供
+	parsed, err := parseSourceContent([]byte(base64.StdEncoding.EncodeToString([]byte(body))))
+	require.NoError(t, err)
+	assert.Equal(t, sourceFormatLoon, parsed.format)
+	assert.Empty(t, parsed.lineErrors)
+	proxies := parsed.config.Proxies
+	require.Len(t, proxies, 4)
+
+	assert.Equal(t, "pa,ss=", proxies[0]["password"])
+	assert.Equal(t, "aes-128-gcm", proxies[0]["cipher"])
+	assert.Equal(t, testUUID, proxies[1]["uuid"])
+	assert.Equal(t, "sni.example.com", proxies[1]["servername"])
+	assert.Equal(t, "ws", proxies[1]["network"])
+	assert.Equal(t, map[string]any{"public-key": "pubkey", "short-id": "0123"}, proxies[2]["reality-opts"])
+	assert.Equal(t, "xtls-rprx-vision", proxies[2]["flow"])
+	assert.Equal(t, "hypass", proxies[3]["password"])
+	assert.Equal(t, "h.example.com", proxies[3]["sni"])
+}
+
+func TestParseQuanXList(t *testing.T) {
+	body := `[server_local]
+shadowsocks=1.1.1.1:8388, method=aes-128-gcm, password=pass, obfs=wss, obfs-host=cdn.example.com, obfs-uri=/ss, udp-relay=true, tag=ss-01
+vmess=vmess.example.com:443, method=chacha20-poly1305, password=` + testUUID + `, obfs=wss, obfs-host=cdn.example.com, obfs-uri=/ws, tls-verification=false, tag=vmess-ws
+trojan=[2001:db8::1]:443, password=secret, over-tls=true, tls-host=t.example.com, tag=trojan
+http=4.4.4.4:8080, username=user, password=pass
+vmess=5.5.5.5:443, method=auto, password=` + testUUID + `, obfs=quic, tag=bad
+
+[filter_local]
+final, direct
+`
+	parsed, err := parseSourceContent([]byte(body))
+	require.NoError(t, err)
+	assert.Equal(t, sourceFormatQuanX, parsed.format)
+	assert.Equal(t, []string{"line 6: vmess: unsupported obfs quic"}, parsed.lineErrors)
+	proxies := parsed.config.Proxies
+	require.Len(t, proxies, 4)
+
+	assert.Equal(t, "v2ray-plugin", proxies[0]["plugin"])
+	assert.Equal(t, map[string]any{"mode": "websocket", "host": "cdn.example.com", "path": "/ss", "tls": true}, proxies[0]["plugin-opts"])
+
+	vmess := proxies[1]
+	assert.Equal(t, true, vmess["tls"])
+	assert.Equal(t, true, vmess["skip-cert-verify"])
+	assert.Equal(t, "cdn.example.com", vmess["servername"])
+	assert.Equal(t, map[string]any{"path": "/ws", "headers": map[string]any{"Host": "cdn.example.com"}}, vmess["ws-opts"])
+
+	assert.Equal(t, "2001:db8::1", proxies[2]["server"])
+	assert.Equal(t, "t.example.com", proxies[2]["sni"])
+	assert.NotContains(t, proxies[2], "tls")
+	// 没有 tag 时以地址作为名称
+	assert.Equal(t, "4.4.4.4:8080", proxies[3]["name"])
+
+	for _, proxy := range proxies {
+		_, err := adapter.ParseProxy(proxy)
+		assert.NoError(t, err, proxy["name"])
+	}
+}
+
+func TestParseProxyListWithoutValidLines(t *testing.T) {
+	parsed, err := parseSourceContent([]byte("a = ss, 1.1.1.1, x\nb = ss, 1.1.1.2, y\n"))
+	require.Error(t, err)
+	assert.Equal(t, sourceFormatSurge, parsed.format)
+	assert.Len(t, parsed.lineErrors, 2)
+}
@@ -0,0 +1,124 @@
+package speedtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+// renameResults 按模板生成导出用的节点名称，返回新的结果切片。
+// 模板可用占位符：
+//
+//	{name}      原始名称
+//	{flag}      国家/地区旗帜 emoji
+//	{country}   国家/地区代码，未知时为 Unknown
+//	{idx}       同一国家/地区内的序号（两位补零）
+//	{seq}       全局序号（两位补零）
+//	{bandwidth} 带宽，如 12.34MB/s
+//	{ttfb}      首字节时间
+//	{delay}     延迟 (ms)
+//	{p50} {p90} {p95} {jitter} 延迟分布指标 (ms)
+//	{loss}      丢包率百分比
+//	{type}      节点协议类型
+//	{server}    节点服务器地址
+//
+// 重名节点会追加 -01、-02 等后缀；原始结果及其 SecretConfig 不会被修改。
+func renameResults(results []models.CProxyWithResult, template string) []models.CProxyWithResult {
+	if template == "" || len(results) == 0 {
+		return results
+	}
+
+	var (
+		out          = make([]models.CProxyWithResult, 0, len(results))
+		countryIndex = make(map[string]int)
+		names        = make(map[string]int, len(results))
+	)
+	for i, result := range results {
+		country := result.Country
+		if country == "" {
+			country = "Unknown"
+		}
+		countryIndex[country]++
+
+		name := strings.Join(strings.Fields(renderTemplate(template, &result, i+1, countryIndex[country])), " ")
+		if name == "" {
+			name = result.Name
+		}
+		name = uniqueExportName(names, name)
+
+		renamed := result
+		renamed.Name = name
+		renamed.Proxy.SecretConfig = cloneConfig(result.Proxy.SecretConfig)
+		if renamed.Proxy.SecretConfig == nil {
+			renamed.Proxy.SecretConfig = map[string]any{}
+		}
+		renamed.Proxy.SecretConfig["name"] = name
+		out = append(out, renamed)
+	}
+	return out
+}
+
+func renderTemplate(template string, result *models.CProxyWithResult, seq, idx int) string {
+	country := result.Country
+	if country == "" {
+		country = "Unknown"
+	}
+	var tp, server string
+	if result.Proxy.SecretConfig != nil {
+		tp, _ = result.Proxy.SecretConfig["type"].(string)
+		server, _ = result.Proxy.SecretConfig["server"].(string)
+	}
+
+	r := strings.NewReplacer(
+		"{name}", result.Name,
+		"{flag}", countryFlag(result.Country),
+		"{country}", country,
+		"{idx}", fmt.Sprintf("%02d", idx),
+		"{seq}", fmt.Sprintf("%02d", seq),
+		"{bandwidth}", result.FormattedBandwidth(),
+		"{ttfb}", result.FormattedTTFB(),
+		"{delay}", strconv.Itoa(int(result.Delay)),
+		"{p50}", strconv.Itoa(int(result.DelayP50)),
+		"{p90}", strconv.Itoa(int(result.DelayP90)),
+		"{p95}", strconv.Itoa(int(result.DelayP95)),
+		"{jitter}", strconv.Itoa(int(result.Jitter)),
+		"{loss}", fmt.Sprintf("%.0f%%", result.LossRate*100),
+		"{type}", tp,
+		"{server}", server,
+	)
+	return r.Replace(template)
+}
+
+// countryFlag 将两位国家代码转换为旗帜 emoji，无法识别时返回空字符串
+func countryFlag(code string) string {
+	if len(code) != 2 {
+		return ""
+	}
+	code = strings.ToUpper(code)
+	var flag []rune
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+		flag = append(flag, 0x1F1E6+c-'A')
+	}
+	return string(flag)
+}
+
+// uniqueExportName 与 mihomo convert 保持一致，重名时追加 -01、-02 后缀
+func uniqueExportName(names map[string]int, name string) string {
+	if _, ok := names[name]; !ok {
+		names[name] = 0
+		return name
+	}
+	for {
+		names[name]++
+		candidate := fmt.Sprintf("%s-%02d", name, names[name])
+		if _, ok := names[candidate]; !ok {
+			names[candidate] = 0
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package speedtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+func TestRenameResults(t *testing.T) {
+	original := map[string]any{"name": "provider-a", "type": "ss", "server": "1.1.1.1"}
+	results := []models.CProxyWithResult{
+		{
+			Result: models.Result{Name: "provider-a", Country: "HK", Bandwidth: 1024 * 1024, Delay: 120},
+			Proxy:  models.CProxy{SecretConfig: original},
+		},
+		{
+			Result: models.Result{Name: "provider-b", Country: "HK", Bandwidth: 2 * 1024 * 1024, Delay: 80},
+			Proxy:  models.CProxy{SecretConfig: map[string]any{"name": "provider-b", "type": "vmess"}},
+		},
+		{
+			Result: models.Result{Name: "provider-c", Delay: 50},
+			Proxy:  models.CProxy{SecretConfig: map[string]any{"name": "provider-c", "type": "trojan"}},
+		},
+	}
+
+	renamed := renameResults(results, "{flag} {country} {idx} | {bandwidth} | {delay}ms")
+
+	assert.Equal(t, "🇭🇰 HK 01 | 1.00MB/s | 120ms", renamed[0].Name)
+	assert.Equal(t, "🇭🇰 HK 02 | 2.00MB/s | 80ms", renamed[1].Name)
+	assert.Equal(t, "Unknown 01 | N/A | 50ms", renamed[2].Name)
+	assert.Equal(t, renamed[0].Name, renamed[0].Proxy.SecretConfig["name"])
+
+	// 原始结果与配置保持不变
+	assert.Equal(t, "provider-a", results[0].Name)
+	assert.Equal(t, "provider-a", original["name"])
+}
+
+func TestRenameResultsDeduplicatesNames(t *testing.T) {
+	results := []models.CProxyWithResult{
+		{Result: models.Result{Name: "a", Country: "US"}, Proxy: models.CProxy{SecretConfig: map[string]any{"name": "a"}}},
+		{Result: models.Result{Name: "b", Country: "US"}, Proxy: models.CProxy{SecretConfig: map[string]any{"name": "b"}}},
+		{Result: models.Result{Name: "c", Country: "US"}, Proxy: models.CProxy{SecretConfig: map[string]any{"name": "c"}}},
+	}
+
+	renamed := renameResults(results, "{country}")
+
+	assert.Equal(t, "US", renamed[0].Name)
+	assert.Equal(t, "US-01", renamed[1].Name)
+	assert.Equal(t, "US-02", renamed[2].Name)
+}
+
+func TestRenameResultsEmptyTemplateKeepsResults(t *testing.T) {
+	results := []models.CProxyWithResult{{Result: models.Result{Name: "a"}}}
+	assert.Equal(t, results, renameResults(results, ""))
+}
@@ -22,7 +22,9 @@ type RequestOption struct {
 	Body    []byte
 	JSON    interface{} // 传递这个参数可以为任意可 json 序列化值，会自动加上 Content-Type, 并覆盖 Body 内容
 	Headers map[string]string
-	Timeout time.Duration // 单次请求超时时间
+	Timeout time.Duration // 单次请求超时时间；RequestStream 中限制连接、等待响应头以及读取响应体时每次等待数据的时间
+	// MaxDuration RequestStream 从发起请求到读完响应体的总时间上限，0 表示不限制；Request 使用 Timeout
+	MaxDuration time.Duration
 	// 重试相关
 	RetryTimes   int           // 重试次数
 	RetryTimeOut time.Duration // 重试超时时间
@@ -39,6 +41,7 @@ type RequestOption struct {
 type XcResponse struct {
 	Body       []byte
 	StatusCode int
+	Header     http.Header
 }
 
 func checkedOption(option *RequestOption) (*RequestOption, error) {
@@ -62,11 +65,11 @@ func checkedOption(option *RequestOption) (*RequestOption, error) {
 	return option, nil
 }
 
-func request(ctx context.Context, option *RequestOption) (*XcResponse, error) {
-	var (
-		req *http.Request
-		err error
-	)
+// do 发送请求并返回未读取的响应，调用方负责关闭 resp.Body。
+// stream 为 true 时 Timeout 只作用于连接、等待响应头与每次读取响应体时等待数据的时间，
+// 调用方两次读取之间的处理耗时不计入，可以边读取边处理；整体耗时受 MaxDuration 限制
+func do(ctx context.Context, option *RequestOption, stream bool) (resp *http.Response, err error) {
+	var req *http.Request
 	logger := option.Logger
 	if logger == nil {
 		logger = slog.Default()
@@ -102,6 +105,28 @@ func request(ctx context.Context, option *RequestOption) (*XcResponse, error) {
 			Timeout:   option.Timeout,
 		}
 	}
+	if stream {
+		client = streamClient(client, option.Timeout)
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		if option.MaxDuration > 0 {
+			timer := time.AfterFunc(option.MaxDuration, func() {
+				cancel(fmt.Errorf("request exceeded %s", option.MaxDuration))
+			})
+			stop := cancel
+			cancel = func(cause error) {
+				timer.Stop()
+				stop(cause)
+			}
+		}
+		defer func() {
+			if err != nil {
+				cancel(nil)
+				return
+			}
+			resp.Body = newIdleTimeoutBody(ctx, resp.Body, option.Timeout, cancel)
+		}()
+	}
 
 	req, err = http.NewRequestWithContext(ctx, option.Method, option.URL, bytes.NewReader(option.Body))
 	if err != nil {
@@ -114,23 +139,84 @@ func request(ctx context.Context, option *RequestOption) (*XcResponse, error) {
 		req.Header.Set(k, v)
 	}
 
-	resp, err := client.Do(req)
+	resp, err = client.Do(req)
 	if err != nil {
 		if option.Verbose {
 			logger.Error("client.Do error", slog.Any("error", err))
 		}
 		return nil, fmt.Errorf("client.Do error: %w", err)
 	}
-	defer resp.Body.Close()
-
 	if err := ctx.Err(); err != nil {
+		resp.Body.Close()
 		return nil, err
 	}
+	return resp, nil
+}
+
+// streamClient 返回不限制整体耗时的 client 副本，改为以 ResponseHeaderTimeout 限制等待响应头的时间
+func streamClient(client *http.Client, timeout time.Duration) *http.Client {
+	c := *client
+	c.Timeout = 0
+	if transport, ok := c.Transport.(*http.Transport); ok {
+		transport = transport.Clone()
+		transport.TLSHandshakeTimeout = timeout
+		transport.ResponseHeaderTimeout = timeout
+		c.Transport = transport
+	}
+	return &c
+}
+
+// idleTimeoutBody 读取响应体时等待数据超过 idle 即取消请求，计时只在 Read 期间进行，
+// 调用方两次 Read 之间的处理耗时不计入。关闭时取消请求以释放 MaxDuration 的计时器
+type idleTimeoutBody struct {
+	ctx    context.Context
+	body   io.ReadCloser
+	timer  *time.Timer
+	idle   time.Duration
+	cancel context.CancelCauseFunc
+}
+
+func newIdleTimeoutBody(ctx context.Context, body io.ReadCloser, idle time.Duration, cancel context.CancelCauseFunc) *idleTimeoutBody {
+	b := &idleTimeoutBody{ctx: ctx, body: body, idle: idle, cancel: cancel}
+	b.timer = time.AfterFunc(idle, func() {
+		cancel(fmt.Errorf("no data received for %s", idle))
+	})
+	b.timer.Stop()
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.idle)
+	n, err := b.body.Read(p)
+	b.timer.Stop()
+	if err != nil && err != io.EOF && b.ctx.Err() != nil {
+		err = fmt.Errorf("read body: %w", context.Cause(b.ctx))
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.body.Close()
+	b.cancel(nil)
+	return err
+}
+
+func request(ctx context.Context, option *RequestOption) (*XcResponse, error) {
+	resp, err := do(ctx, option, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
 	var body []byte
 	body, err = io.ReadAll(resp.Body)
 	if err != nil {
 		if option.Verbose {
+			logger := option.Logger
+			if logger == nil {
+				logger = slog.Default()
+			}
 			logger.Error("io.ReadAll error", slog.Any("error", err))
 		}
 		return nil, fmt.Errorf("io.ReadAll error: %w", err)
@@ -138,11 +224,25 @@ func request(ctx context.Context, option *RequestOption) (*XcResponse, error) {
 	return &XcResponse{
 		Body:       body,
 		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
 	}, nil
 }
 
 func Request(ctx context.Context, option *RequestOption) (*XcResponse, error) {
-	resp, err := request(ctx, option)
+	return withRetry(ctx, option, request)
+}
+
+// RequestStream 与 Request 相同，但不读取响应体，调用方负责关闭 resp.Body。
+// 仅在取得响应前失败时重试，读取响应体过程中的错误由调用方处理；读取响应体时超过 Timeout 没有收到数据，
+// 或从发起请求起超过 MaxDuration 时请求被取消，Read 返回相应的错误
+func RequestStream(ctx context.Context, option *RequestOption) (*http.Response, error) {
+	return withRetry(ctx, option, func(ctx context.Context, option *RequestOption) (*http.Response, error) {
+		return do(ctx, option, true)
+	})
+}
+
+func withRetry[T any](ctx context.Context, option *RequestOption, fn func(context.Context, *RequestOption) (*T, error)) (*T, error) {
+	resp, err := fn(ctx, option)
 	if option.RetryTimes > 0 && (err != nil || resp == nil) {
 		var timeout = option.RetryTimeOut
 		if timeout <= 0 {
@@ -159,7 +259,7 @@ func Request(ctx context.Context, option *RequestOption) (*XcResponse, error) {
 			}
 			var t = time.Second + timeout
 			timeout = t
-			resp, err = request(ctx, option)
+			resp, err = fn(ctx, option)
 			if resp != nil {
 				break
 			}
@@ -0,0 +1,324 @@
+package speedtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+// ProxyToURI 将 mihomo 节点配置转换为分享链接，与 mihomo 的订阅解析（convert.ConvertsV2Ray）互逆。
+// 支持 ss、vmess、vless、trojan、hysteria2、tuic，其余类型返回错误
+func ProxyToURI(config map[string]any) (string, error) {
+	tp := strings.ToLower(cfgString(config, "type"))
+	switch tp {
+	case "ss":
+		return ssURI(config)
+	case "vmess":
+		return vmessURI(config)
+	case "vless":
+		return vlessURI(config)
+	case "trojan":
+		return trojanURI(config)
+	case "hysteria2":
+		return hysteria2URI(config)
+	case "tuic":
+		return tuicURI(config)
+	default:
+		return "", fmt.Errorf("share link not supported for proxy type: %s", tp)
+	}
+}
+
+// shareURL 生成 scheme://user@server:port?query#name 形式的链接
+func shareURL(scheme string, user *url.Userinfo, config map[string]any, query url.Values) (string, error) {
+	server := cfgString(config, "server")
+	port := cfgString(config, "port")
+	if server == "" || port == "" {
+		return "", fmt.Errorf("%s: server or port is empty", scheme)
+	}
+	u := url.URL{
+		Scheme:   scheme,
+		User:     user,
+		Host:     net.JoinHostPort(server, port),
+		RawQuery: query.Encode(),
+		Fragment: cfgString(config, "name"),
+	}
+	return u.String(), nil
+}
+
+func ssURI(config map[string]any) (string, error) {
+	// SIP002: userinfo 为 base64url(cipher:password)，SS2022 的 2022-blake3-* 加密方式不使用 base64，直接百分号编码
+	cipher, password := cfgString(config, "cipher"), cfgString(config, "password")
+	user := url.User(base64.RawURLEncoding.EncodeToString([]byte(cipher + ":" + password)))
+	if strings.HasPrefix(strings.ToLower(cipher), "2022-") {
+		user = url.UserPassword(cipher, password)
+	}
+	query := url.Values{}
+	if cfgBool(config, "udp-over-tcp") {
+		query.Set("uot", "1")
+	}
+	opts := cfgMap(config, "plugin-opts")
+	switch cfgString(config, "plugin") {
+	case "":
+	case "obfs":
+		query.Set("plugin", fmt.Sprintf("obfs-local;obfs=%s;obfs-host=%s", cfgString(opts, "mode"), cfgString(opts, "host")))
+	case "v2ray-plugin":
+		plugin := fmt.Sprintf("v2ray-plugin;mode=%s;host=%s;path=%s", cfgString(opts, "mode"), cfgString(opts, "host"), cfgString(opts, "path"))
+		if cfgBool(opts, "tls") {
+			plugin += ";tls"
+		}
+		query.Set("plugin", plugin)
+	default:
+		return "", fmt.Errorf("ss: share link not supported for plugin: %s", cfgString(config, "plugin"))
+	}
+	return shareURL("ss", user, config, query)
+}
+
+// vmessURI 生成 V2RayN 格式的 vmess 链接，兼容性最好
+func vmessURI(config map[string]any) (string, error) {
+	values := map[string]any{
+		"v":    "2",
+		"ps":   cfgString(config, "name"),
+		"add":  cfgString(config, "server"),
+		"port": cfgString(config, "port"),
+		"id":   cfgString(config, "uuid"),
+		"aid":  strconv.Itoa(cfgInt(config, "alterId")),
+		"scy":  cfgString(config, "cipher"),
+		"net":  "tcp",
+		"type": "none",
+	}
+	if cfgBool(config, "tls") {
+		values["tls"] = "tls"
+		if alpn := cfgStrings(config, "alpn"); len(alpn) > 0 {
+			values["alpn"] = strings.Join(alpn, ",")
+		}
+		if cfgBool(config, "skip-cert-verify") {
+			values["insecure"] = "1"
+		}
+	}
+	if sni := cfgString(config, "servername"); sni != "" {
+		values["sni"] = sni
+	}
+	switch network := cfgString(config, "network"); network {
+	case "", "tcp":
+	case "http":
+		// V2RayN 中 tcp + http 伪装对应 mihomo 的 http 网络
+		opts := cfgMap(config, "http-opts")
+		values["type"] = "http"
+		values["host"] = cfgFirstString(cfgMap(opts, "headers"), "Host")
+		values["path"] = cfgFirstString(opts, "path")
+	case "h2":
+		opts := cfgMap(config, "h2-opts")
+		values["net"] = "http"
+		values["host"] = cfgFirstString(opts, "host")
+		values["path"] = cfgString(opts, "path")
+	case "ws", "httpupgrade":
+		opts := cfgMap(config, "ws-opts")
+		values["net"] = network
+		values["host"] = cfgString(cfgMap(opts, "headers"), "Host")
+		values["path"] = cfgString(opts, "path")
+	case "grpc":
+		values["net"] = "grpc"
+		values["path"] = cfgString(cfgMap(config, "grpc-opts"), "grpc-service-name")
+	default:
+		return "", fmt.Errorf("vmess: share link not supported for network: %s", network)
+	}
+	if values["add"] == "" || values["port"] == "" {
+		return "", fmt.Errorf("vmess: server or port is empty")
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return "vmess://" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+func vlessURI(config map[string]any) (string, error) {
+	query := url.Values{}
+	security := "none"
+	if reality := cfgMap(config, "reality-opts"); reality != nil {
+		security = "reality"
+		query.Set("pbk", cfgString(reality, "public-key"))
+		if sid := cfgString(reality, "short-id"); sid != "" {
+			query.Set("sid", sid)
+		}
+	} else if cfgBool(config, "tls") {
+		security = "tls"
+	}
+	query.Set("security", security)
+	if security != "none" {
+		setQuery(query, "fp", cfgString(config, "client-fingerprint"))
+		setQuery(query, "alpn", strings.Join(cfgStrings(config, "alpn"), ","))
+		setQuery(query, "pcs", cfgString(config, "fingerprint"))
+		if cfgBool(config, "skip-cert-verify") {
+			query.Set("allowInsecure", "1")
+		}
+	}
+	setQuery(query, "sni", cfgString(config, "servername"))
+	setQuery(query, "flow", cfgString(config, "flow"))
+	setQuery(query, "encryption", cfgString(config, "encryption"))
+	if cfgBool(config, "packet-addr") {
+		query.Set("packetEncoding", "packet")
+	} else if _, ok := config["xudp"]; ok && !cfgBool(config, "xudp") {
+		query.Set("packetEncoding", "none")
+	}
+	if err := setTransportQuery(query, config); err != nil {
+		return "", fmt.Errorf("vless: %w", err)
+	}
+	return shareURL("vless", url.User(cfgString(config, "uuid")), config, query)
+}
+
+func trojanURI(config map[string]any) (string, error) {
+	query := url.Values{}
+	setQuery(query, "sni", cfgString(config, "sni"))
+	setQuery(query, "alpn", strings.Join(cfgStrings(config, "alpn"), ","))
+	setQuery(query, "fp", cfgString(config, "client-fingerprint"))
+	setQuery(query, "pcs", cfgString(config, "fingerprint"))
+	if cfgBool(config, "skip-cert-verify") {
+		query.Set("allowInsecure", "1")
+	}
+	switch network := cfgString(config, "network"); network {
+	case "", "tcp":
+	case "ws":
+		query.Set("type", "ws")
+		setQuery(query, "path", cfgString(cfgMap(config, "ws-opts"), "path"))
+	case "grpc":
+		query.Set("type", "grpc")
+		setQuery(query, "serviceName", cfgString(cfgMap(config, "grpc-opts"), "grpc-service-name"))
+	default:
+		return "", fmt.Errorf("trojan: share link not supported for network: %s", network)
+	}
+	return shareURL("trojan", url.User(cfgString(config, "password")), config, query)
+}
+
+func hysteria2URI(config map[string]any) (string, error) {
+	query := url.Values{}
+	setQuery(query, "sni", cfgString(config, "sni"))
+	setQuery(query, "obfs", cfgString(config, "obfs"))
+	setQuery(query, "obfs-password", cfgString(config, "obfs-password"))
+	setQuery(query, "alpn", strings.Join(cfgStrings(config, "alpn"), ","))
+	setQuery(query, "pinSHA256", cfgString(config, "fingerprint"))
+	setQuery(query, "up", cfgString(config, "up"))
+	setQuery(query, "down", cfgString(config, "down"))
+	if cfgBool(config, "skip-cert-verify") {
+		query.Set("insecure", "1")
+	}
+	var user *url.Userinfo
+	if password := cfgString(config, "password"); password != "" {
+		user = url.User(password)
+	}
+	return shareURL("hysteria2", user, config, query)
+}
+
+func tuicURI(config map[string]any) (string, error) {
+	var user *url.Userinfo
+	if token := cfgString(config, "token"); token != "" {
+		user = url.User(token)
+	} else {
+		user = url.UserPassword(cfgString(config, "uuid"), cfgString(config, "password"))
+	}
+	query := url.Values{}
+	setQuery(query, "congestion_control", cfgString(config, "congestion-controller"))
+	setQuery(query, "alpn", strings.Join(cfgStrings(config, "alpn"), ","))
+	setQuery(query, "sni", cfgString(config, "sni"))
+	setQuery(query, "udp_relay_mode", cfgString(config, "udp-relay-mode"))
+	if cfgBool(config, "disable-sni") {
+		query.Set("disable_sni", "1")
+	}
+	return shareURL("tuic", user, config, query)
+}
+
+// setTransportQuery 按 Xray 分享链接标准写入 type/host/path/serviceName 等传输层参数
+func setTransportQuery(query url.Values, config map[string]any) error {
+	switch network := cfgString(config, "network"); network {
+	case "", "tcp":
+		query.Set("type", "tcp")
+	case "http":
+		opts := cfgMap(config, "http-opts")
+		query.Set("type", "tcp")
+		query.Set("headerType", "http")
+		setQuery(query, "method", cfgString(opts, "method"))
+		setQuery(query, "path", cfgFirstString(opts, "path"))
+		setQuery(query, "host", cfgFirstString(cfgMap(opts, "headers"), "Host"))
+	case "h2":
+		opts := cfgMap(config, "h2-opts")
+		query.Set("type", "http")
+		setQuery(query, "path", cfgFirstString(opts, "path"))
+		setQuery(query, "host", cfgFirstString(opts, "host"))
+	case "ws", "httpupgrade":
+		opts := cfgMap(config, "ws-opts")
+		query.Set("type", network)
+		setQuery(query, "path", cfgString(opts, "path"))
+		setQuery(query, "host", cfgString(cfgMap(opts, "headers"), "Host"))
+	case "grpc":
+		query.Set("type", "grpc")
+		setQuery(query, "serviceName", cfgString(cfgMap(config, "grpc-opts"), "grpc-service-name"))
+	default:
+		return fmt.Errorf("share link not supported for network: %s", network)
+	}
+	return nil
+}
+
+func setQuery(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+// shareLinks 将结果转换为分享链接，无法转换的节点被跳过，按节点类型计数
+func shareLinks(results []models.CProxyWithResult) ([]string, map[string]int) {
+	links := make([]string, 0, len(results))
+	skipped := make(map[string]int)
+	for _, result := range results {
+		link, err := ProxyToURI(result.Proxy.SecretConfig)
+		if err != nil {
+			skipped[strings.ToLower(cfgString(result.Proxy.SecretConfig, "type"))]++
+			continue
+		}
+		links = append(links, link)
+	}
+	return links, skipped
+}
+
+// warnSkipped 记录导出时被跳过的节点类型及数量
+func warnSkipped(logger *slog.Logger, format string, skipped map[string]int) {
+	if len(skipped) == 0 {
+		return
+	}
+	resolveLogger(logger).Warn(fmt.Sprintf("%s 导出跳过了无法转换的节点: %s", format, formatTypeCounts(skipped)))
+}
+
+// URIExporter 逐行导出分享链接，无法转换为分享链接的节点会被跳过，跳过的节点类型及数量记录到 Logger
+type URIExporter struct {
+	Logger *slog.Logger // 为空时使用 Options.Logger，未设置时使用 slog.Default()
+}
+
+func (e *URIExporter) Export(w io.Writer, results []models.CProxyWithResult) error {
+	links, skipped := shareLinks(results)
+	warnSkipped(e.Logger, "uri", skipped)
+	if len(links) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(w, strings.Join(links, "\n")+"\n")
+	return err
+}
+
+// SubscriptionExporter 导出 base64 编码的订阅内容，可直接作为 v2ray 订阅使用，跳过的节点同 URIExporter
+type SubscriptionExporter struct {
+	Logger *slog.Logger // 为空时使用 Options.Logger，未设置时使用 slog.Default()
+}
+
+func (e *SubscriptionExporter) Export(w io.Writer, results []models.CProxyWithResult) error {
+	links, skipped := shareLinks(results)
+	warnSkipped(e.Logger, "base64", skipped)
+	content := strings.Join(links, "\n")
+	_, err := io.WriteString(w, base64.StdEncoding.EncodeToString([]byte(content)))
+	return err
+}
@@ -0,0 +1,309 @@
+package speedtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/metacubex/mihomo/adapter"
+	"github.com/metacubex/mihomo/common/convert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+func shareLinkConfigs() []map[string]any {
+	return []map[string]any{
+		{
+			"name": "ss 01", "type": "ss", "server": "1.1.1.1", "port": 8388,
+			"cipher": "aes-128-gcm", "password": "p@ss:word",
+			"plugin": "obfs", "plugin-opts": map[string]any{"mode": "http", "host": "bing.com"},
+		},
+		{
+			"name": "vmess-ws", "type": "vmess", "server": "vmess.example.com", "port": 443,
+			"uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "alterId": 0, "cipher": "auto",
+			"tls": true, "servername": "sni.example.com", "network": "ws",
+			"ws-opts": map[string]any{"path": "/ws", "headers": map[string]any{"Host": "cdn.example.com"}},
+		},
+		{
+			"name": "vless-reality", "type": "vless", "server": "2.2.2.2", "port": 443,
+			"uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "flow": "xtls-rprx-vision",
+			"tls": true, "servername": "www.apple.com", "client-fingerprint": "safari",
+			"reality-opts": map[string]any{"public-key": "pubkey", "short-id": "0123"},
+			"network":      "grpc", "grpc-opts": map[string]any{"grpc-service-name": "svc"},
+		},
+		{
+			"name": "trojan", "type": "trojan", "server": "3.3.3.3", "port": 443,
+			"password": "secret", "sni": "t.example.com", "skip-cert-verify": true,
+		},
+		{
+			"name": "hy2", "type": "hysteria2", "server": "4.4.4.4", "port": 8443,
+			"password": "hypass", "obfs": "salamander", "obfs-password": "ob", "sni": "h.example.com",
+		},
+		{
+			"name": "tuic", "type": "tuic", "server": "5.5.5.5", "port": 10443,
+			"uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "password": "tp",
+			"congestion-controller": "bbr", "alpn": []string{"h3"}, "udp-relay-mode": "native",
+		},
+	}
+}
+
+func TestProxyToURIRoundTrip(t *testing.T) {
+	configs := shareLinkConfigs()
+	links := make([]string, 0, len(configs))
+	for _, config := range configs {
+		link, err := ProxyToURI(config)
+		require.NoError(t, err, config["name"])
+		links = append(links, link)
+	}
+
+	parsed, err := convert.ConvertsV2Ray([]byte(strings.Join(links, "\n")))
+	require.NoError(t, err)
+	require.Len(t, parsed, len(configs))
+
+	for i, want := range configs {
+		got := parsed[i]
+		assert.Equal(t, want["name"], got["name"])
+		assert.Equal(t, want["type"], got["type"])
+		assert.Equal(t, want["server"], got["server"])
+		assert.Equal(t, fmt.Sprint(want["port"]), fmt.Sprint(got["port"]))
+	}
+
+	assert.Equal(t, "aes-128-gcm", parsed[0]["cipher"])
+	assert.Equal(t, "p@ss:word", parsed[0]["password"])
+	assert.Equal(t, map[string]any{"mode": "http", "host": "bing.com"}, parsed[0]["plugin-opts"])
+
+	assert.Equal(t, "ws", parsed[1]["network"])
+	assert.Equal(t, true, parsed[1]["tls"])
+	assert.Equal(t, "sni.example.com", parsed[1]["servername"])
+	assert.Equal(t, "/ws", cfgString(cfgMap(parsed[1], "ws-opts"), "path"))
+
+	assert.Equal(t, "xtls-rprx-vision", parsed[2]["flow"])
+	assert.Equal(t, "safari", parsed[2]["client-fingerprint"])
+	assert.Equal(t, map[string]any{"public-key": "pubkey", "short-id": "0123"}, parsed[2]["reality-opts"])
+	assert.Equal(t, "svc", cfgString(cfgMap(parsed[2], "grpc-opts"), "grpc-service-name"))
+
+	assert.Equal(t, "secret", parsed[3]["password"])
+	assert.Equal(t, true, parsed[3]["skip-cert-verify"])
+
+	assert.Equal(t, "hypass", parsed[4]["password"])
+	assert.Equal(t, "salamander", parsed[4]["obfs"])
+
+	assert.Equal(t, "tp", parsed[5]["password"])
+	assert.Equal(t, "bbr", parsed[5]["congestion-controller"])
+	assert.Equal(t, []string{"h3"}, parsed[5]["alpn"])
+}
+
+func TestProxyToURIUnsupportedType(t *testing.T) {
+	_, err := ProxyToURI(map[string]any{"name": "wg", "type": "wireguard", "server": "1.1.1.1", "port": 51820})
+	assert.Error(t, err)
+}
+
+func TestProxyToURISkipCertVerify(t *testing.T) {
+	vmess := map[string]any{
+		"name": "vmess", "type": "vmess", "server": "1.1.1.1", "port": 443,
+		"uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "cipher": "auto", "tls": true, "skip-cert-verify": true,
+	}
+	link, err := ProxyToURI(vmess)
+	require.NoError(t, err)
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(link, "vmess://"))
+	require.NoError(t, err)
+	var values map[string]any
+	require.NoError(t, json.Unmarshal(data, &values))
+	assert.Equal(t, "1", values["insecure"])
+
+	vless := map[string]any{
+		"name": "vless", "type": "vless", "server": "2.2.2.2", "port": 443,
+		"uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "tls": true, "skip-cert-verify": true,
+	}
+	link, err = ProxyToURI(vless)
+	require.NoError(t, err)
+	assert.Contains(t, link, "allowInsecure=1")
+
+	// 未开启 TLS 时不输出
+	delete(vless, "tls")
+	link, err = ProxyToURI(vless)
+	require.NoError(t, err)
+	assert.NotContains(t, link, "allowInsecure")
+}
+
+func shareLinkResults() []models.CProxyWithResult {
+	configs := append(shareLinkConfigs(), map[string]any{"name": "socks", "type": "socks5", "server": "6.6.6.6", "port": 1080})
+	results := make([]models.CProxyWithResult, 0, len(configs))
+	for _, config := range configs {
+		results = append(results, models.CProxyWithResult{
+			Result: models.Result{Name: config["name"].(string)},
+			Proxy:  models.CProxy{SecretConfig: config},
+		})
+	}
+	return results
+}
+
+func TestProxyToURIShadowsocks2022(t *testing.T) {
+	config := map[string]any{
+		"name": "ss2022", "type": "ss", "server": "1.1.1.1", "port": 8388,
+		"cipher": "2022-blake3-aes-128-gcm", "password": "3OsIh+6/8vYDzy5Z1GGUzA==:yPxE/mh7EnNb0FWQ6fYHmA==",
+	}
+	link, err := ProxyToURI(config)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(link, "ss://2022-blake3-aes-128-gcm:"), link)
+
+	parsed, err := convert.ConvertsV2Ray([]byte(link))
+	require.NoError(t, err)
+	require.Len(t, parsed, 1)
+	assert.Equal(t, config["cipher"], parsed[0]["cipher"])
+	assert.Equal(t, config["password"], parsed[0]["password"])
+}
+
+func TestSubscriptionExporterSkipsUnsupported(t *testing.T) {
+	var uri, logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	assert.NoError(t, (&URIExporter{Logger: logger}).Export(&uri, shareLinkResults()))
+	lines := strings.Split(strings.TrimSpace(uri.String()), "\n")
+	assert.Len(t, lines, len(shareLinkConfigs()))
+	assert.Contains(t, logs.String(), "socks5×1")
+
+	var sub bytes.Buffer
+	assert.NoError(t, (&SubscriptionExporter{}).Export(&sub, shareLinkResults()))
+	decoded, err := base64.StdEncoding.DecodeString(sub.String())
+	require.NoError(t, err)
+	assert.Equal(t, strings.TrimSpace(uri.String()), string(decoded))
+}
+
+func TestSingBoxExporter(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, (&SingBoxExporter{}).Export(&buf, shareLinkResults()))
+
+	var out struct {
+		Outbounds []map[string]any `json:"outbounds"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	require.Len(t, out.Outbounds, len(shareLinkConfigs())+1)
+
+	ss := out.Outbounds[0]
+	assert.Equal(t, "shadowsocks", ss["type"])
+	assert.Equal(t, "ss 01", ss["tag"])
+	assert.Equal(t, float64(8388), ss["server_port"])
+	assert.Equal(t, "obfs-local", ss["plugin"])
+
+	vmess := out.Outbounds[1]
+	assert.Equal(t, map[string]any{"type": "ws", "path": "/ws", "headers": map[string]any{"Host": "cdn.example.com"}}, vmess["transport"])
+
+	vless := out.Outbounds[2]
+	tls := vless["tls"].(map[string]any)
+	assert.Equal(t, "www.apple.com", tls["server_name"])
+	assert.Equal(t, map[string]any{"enabled": true, "public_key": "pubkey", "short_id": "0123"}, tls["reality"])
+
+	assert.Equal(t, map[string]any{"type": "salamander", "password": "ob"}, out.Outbounds[4]["obfs"])
+	assert.Equal(t, "socks", out.Outbounds[6]["type"])
+
+	var logs bytes.Buffer
+	results := append(shareLinkResults(), models.CProxyWithResult{
+		Proxy: models.CProxy{SecretConfig: map[string]any{"name": "wg", "type": "wireguard", "server": "1.1.1.1", "port": 51820}},
+	})
+	buf.Reset()
+	assert.NoError(t, (&SingBoxExporter{Logger: slog.New(slog.NewTextHandler(&logs, nil))}).Export(&buf, results))
+	assert.Contains(t, logs.String(), "wireguard×1")
+}
+
+func TestProxyToSingBoxHysteria2Bandwidth(t *testing.T) {
+	for _, tc := range []struct {
+		value any
+		mbps  int
+	}{
+		{value: 50, mbps: 50},
+		{value: "80", mbps: 80},
+		{value: "100 Mbps", mbps: 100},
+		{value: "1 Gbps", mbps: 1000},
+		{value: "10 MBps", mbps: 80},
+		{value: "fast", mbps: 0},
+	} {
+		config := map[string]any{"name": "hy2", "type": "hysteria2", "server": "4.4.4.4", "port": 8443,
+			"password": "p", "up": tc.value, "down": tc.value}
+		outbound, err := ProxyToSingBox(config)
+		require.NoError(t, err)
+		if tc.mbps == 0 {
+			assert.NotContains(t, outbound, "up_mbps", tc.value)
+			continue
+		}
+		assert.Equal(t, tc.mbps, outbound["up_mbps"], tc.value)
+		assert.Equal(t, tc.mbps, outbound["down_mbps"], tc.value)
+	}
+}
+
+func TestSingBoxToProxyRoundTrip(t *testing.T) {
+	for _, config := range shareLinkConfigs() {
+		outbound, err := ProxyToSingBox(config)
+		require.NoError(t, err, config["name"])
+		// 模拟从 JSON 文件读取，数字为 float64
+		data, err := json.Marshal(outbound)
+		require.NoError(t, err)
+		var decoded map[string]any
+		require.NoError(t, json.Unmarshal(data, &decoded))
+
+		got, err := SingBoxToProxy(decoded)
+		require.NoError(t, err, config["name"])
+		for _, key := range []string{"name", "type", "server", "password", "uuid", "cipher", "sni", "servername", "network", "plugin", "obfs", "flow"} {
+			if want, ok := config[key]; ok {
+				assert.Equal(t, want, got[key], "%s %s", config["name"], key)
+			}
+		}
+		assert.Equal(t, cfgInt(config, "port"), got["port"], config["name"])
+		for _, key := range []string{"ws-opts", "grpc-opts", "reality-opts", "plugin-opts"} {
+			if want, ok := config[key]; ok {
+				assert.Equal(t, want, got[key], "%s %s", config["name"], key)
+			}
+		}
+	}
+}
+
+func TestSingBoxToProxies(t *testing.T) {
+	body := `{
+  "outbounds": [
+    {"type": "selector", "tag": "select", "outbounds": ["ss"]},
+    {"type": "shadowsocks", "tag": "ss", "server": "1.1.1.1", "server_port": 8388, "method": "aes-128-gcm", "password": "p"},
+    {"type": "vless", "tag": "h2", "server": "2.2.2.2", "server_port": 443, "uuid": "b831381d-6324-4d53-ad4f-8cda48b30811",
+     "tls": {"enabled": true, "server_name": "h2.example.com"}, "transport": {"type": "http", "host": ["h2.example.com"], "path": "/h2"}},
+    {"type": "wireguard", "tag": "wg-legacy", "server": "3.3.3.3", "server_port": 51820, "local_address": ["172.16.0.2/32", "fd00::2/128"],
+     "private_key": "cHJpdmF0ZQ==", "peer_public_key": "cHVibGlj", "reserved": [1, 2, 3], "mtu": 1280},
+    {"type": "shadowtls", "tag": "stls", "server": "4.4.4.4", "server_port": 443},
+    {"type": "direct", "tag": "direct"}
+  ],
+  "endpoints": [
+    {"type": "wireguard", "tag": "wg", "address": ["10.0.0.2/32"], "private_key": "cHJpdmF0ZQ==",
+     "peers": [{"address": "5.5.5.5", "port": 51820, "public_key": "cHVibGlj", "allowed_ips": ["0.0.0.0/0"]}]}
+  ]
+}`
+	proxies, unsupported, err := singBoxToProxies([]byte(body))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"shadowtls": 1}, unsupported)
+	require.Len(t, proxies, 4)
+
+	assert.Equal(t, "ss", proxies[0]["type"])
+	assert.Equal(t, 8388, proxies[0]["port"])
+	assert.Equal(t, true, proxies[0]["udp"])
+
+	assert.Equal(t, "h2", proxies[1]["network"])
+	assert.Equal(t, map[string]any{"host": []string{"h2.example.com"}, "path": "/h2"}, proxies[1]["h2-opts"])
+
+	legacy := proxies[2]
+	assert.Equal(t, "172.16.0.2", legacy["ip"])
+	assert.Equal(t, "fd00::2", legacy["ipv6"])
+	assert.Equal(t, "cHVibGlj", legacy["public-key"])
+	assert.Equal(t, []int{1, 2, 3}, legacy["reserved"])
+
+	endpoint := proxies[3]
+	assert.Equal(t, "5.5.5.5", endpoint["server"])
+	assert.Equal(t, 51820, endpoint["port"])
+	assert.Equal(t, []string{"0.0.0.0/0"}, endpoint["allowed-ips"])
+
+	// 转换结果可以直接被 mihomo 解析
+	for _, proxy := range proxies[:2] {
+		_, err := adapter.ParseProxy(proxy)
+		assert.NoError(t, err, proxy["name"])
+	}
+}
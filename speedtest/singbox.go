@@ -0,0 +1,538 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+
+	"github.com/metacubex/mihomo/adapter/outbound"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+// ProxyToSingBox 将 mihomo 节点配置转换为 sing-box outbound。
+// 支持 ss、vmess、vless、trojan、hysteria2、tuic、socks5、http，其余类型返回错误
+func ProxyToSingBox(config map[string]any) (map[string]any, error) {
+	tp := strings.ToLower(cfgString(config, "type"))
+	outbound := map[string]any{
+		"tag":         cfgString(config, "name"),
+		"server":      cfgString(config, "server"),
+		"server_port": cfgInt(config, "port"),
+	}
+	switch tp {
+	case "ss":
+		outbound["type"] = "shadowsocks"
+		outbound["method"] = cfgString(config, "cipher")
+		outbound["password"] = cfgString(config, "password")
+		if cfgBool(config, "udp-over-tcp") {
+			outbound["udp_over_tcp"] = true
+		}
+		opts := cfgMap(config, "plugin-opts")
+		switch cfgString(config, "plugin") {
+		case "":
+		case "obfs":
+			outbound["plugin"] = "obfs-local"
+			outbound["plugin_opts"] = fmt.Sprintf("obfs=%s;obfs-host=%s", cfgString(opts, "mode"), cfgString(opts, "host"))
+		case "v2ray-plugin":
+			pluginOpts := fmt.Sprintf("mode=%s;host=%s;path=%s", cfgString(opts, "mode"), cfgString(opts, "host"), cfgString(opts, "path"))
+			if cfgBool(opts, "tls") {
+				pluginOpts += ";tls"
+			}
+			outbound["plugin"] = "v2ray-plugin"
+			outbound["plugin_opts"] = pluginOpts
+		default:
+			return nil, fmt.Errorf("ss: sing-box not supported for plugin: %s", cfgString(config, "plugin"))
+		}
+	case "vmess":
+		outbound["type"] = "vmess"
+		outbound["uuid"] = cfgString(config, "uuid")
+		outbound["alter_id"] = cfgInt(config, "alterId")
+		outbound["security"] = cfgString(config, "cipher")
+		if cfgBool(config, "tls") {
+			outbound["tls"] = singBoxTLS(config, cfgString(config, "servername"))
+		}
+	case "vless":
+		outbound["type"] = "vless"
+		outbound["uuid"] = cfgString(config, "uuid")
+		if flow := cfgString(config, "flow"); flow != "" {
+			outbound["flow"] = flow
+		}
+		if cfgBool(config, "packet-addr") {
+			outbound["packet_encoding"] = "packetaddr"
+		} else if _, ok := config["xudp"]; !ok || cfgBool(config, "xudp") {
+			outbound["packet_encoding"] = "xudp"
+		}
+		if reality := cfgMap(config, "reality-opts"); reality != nil || cfgBool(config, "tls") {
+			tls := singBoxTLS(config, cfgString(config, "servername"))
+			if reality != nil {
+				tls["reality"] = map[string]any{
+					"enabled":    true,
+					"public_key": cfgString(reality, "public-key"),
+					"short_id":   cfgString(reality, "short-id"),
+				}
+			}
+			outbound["tls"] = tls
+		}
+	case "trojan":
+		outbound["type"] = "trojan"
+		outbound["password"] = cfgString(config, "password")
+		outbound["tls"] = singBoxTLS(config, cfgString(config, "sni"))
+	case "hysteria2":
+		outbound["type"] = "hysteria2"
+		outbound["password"] = cfgString(config, "password")
+		if up := cfgMbps(config, "up"); up > 0 {
+			outbound["up_mbps"] = up
+		}
+		if down := cfgMbps(config, "down"); down > 0 {
+			outbound["down_mbps"] = down
+		}
+		if obfs := cfgString(config, "obfs"); obfs != "" {
+			outbound["obfs"] = map[string]any{
+				"type":     obfs,
+				"password": cfgString(config, "obfs-password"),
+			}
+		}
+		outbound["tls"] = singBoxTLS(config, cfgString(config, "sni"))
+	case "tuic":
+		outbound["type"] = "tuic"
+		outbound["uuid"] = cfgString(config, "uuid")
+		outbound["password"] = cfgString(config, "password")
+		if cfgString(config, "token") != "" {
+			return nil, fmt.Errorf("tuic: sing-box does not support tuic v4 token")
+		}
+		if cc := cfgString(config, "congestion-controller"); cc != "" {
+			outbound["congestion_control"] = cc
+		}
+		if mode := cfgString(config, "udp-relay-mode"); mode != "" {
+			outbound["udp_relay_mode"] = mode
+		}
+		tls := singBoxTLS(config, cfgString(config, "sni"))
+		if cfgBool(config, "disable-sni") {
+			tls["disable_sni"] = true
+		}
+		outbound["tls"] = tls
+	case "socks5":
+		outbound["type"] = "socks"
+		outbound["version"] = "5"
+		setNonEmpty(outbound, "username", cfgString(config, "username"))
+		setNonEmpty(outbound, "password", cfgString(config, "password"))
+	case "http":
+		outbound["type"] = "http"
+		setNonEmpty(outbound, "username", cfgString(config, "username"))
+		setNonEmpty(outbound, "password", cfgString(config, "password"))
+		if cfgBool(config, "tls") {
+			outbound["tls"] = singBoxTLS(config, cfgString(config, "sni"))
+		}
+	default:
+		return nil, fmt.Errorf("sing-box not supported for proxy type: %s", tp)
+	}
+	if tp == "vmess" || tp == "vless" || tp == "trojan" {
+		transport, err := singBoxTransport(config)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tp, err)
+		}
+		if transport != nil {
+			outbound["transport"] = transport
+		}
+	}
+	return outbound, nil
+}
+
+// cfgMbps 读取 hysteria2 的 up/down 并换算为 Mbps。与 mihomo 一致，纯数字按 Mbps 计，
+// 也接受 "100 Mbps"、"1 Gbps"、"10 MBps" 等带单位的写法，无法识别时返回 0
+func cfgMbps(config map[string]any, key string) int {
+	bps := outbound.StringToBps(strings.TrimSpace(cfgString(config, key)))
+	if bps == 0 {
+		return 0
+	}
+	return max(1, int(math.Round(float64(bps)*8/1e6)))
+}
+
+func singBoxTLS(config map[string]any, serverName string) map[string]any {
+	tls := map[string]any{"enabled": true}
+	setNonEmpty(tls, "server_name", serverName)
+	if cfgBool(config, "skip-cert-verify") {
+		tls["insecure"] = true
+	}
+	if alpn := cfgStrings(config, "alpn"); len(alpn) > 0 {
+		tls["alpn"] = alpn
+	}
+	if fp := cfgString(config, "client-fingerprint"); fp != "" {
+		tls["utls"] = map[string]any{"enabled": true, "fingerprint": fp}
+	}
+	return tls
+}
+
+func singBoxTransport(config map[string]any) (map[string]any, error) {
+	switch network := cfgString(config, "network"); network {
+	case "", "tcp":
+		return nil, nil
+	case "http":
+		opts := cfgMap(config, "http-opts")
+		transport := map[string]any{"type": "http"}
+		setNonEmpty(transport, "method", cfgString(opts, "method"))
+		setNonEmpty(transport, "path", cfgFirstString(opts, "path"))
+		if hosts := cfgStrings(cfgMap(opts, "headers"), "Host"); len(hosts) > 0 {
+			transport["host"] = hosts
+		}
+		return transport, nil
+	case "h2":
+		opts := cfgMap(config, "h2-opts")
+		transport := map[string]any{"type": "http"}
+		setNonEmpty(transport, "path", cfgFirstString(opts, "path"))
+		if hosts := cfgStrings(opts, "host"); len(hosts) > 0 {
+			transport["host"] = hosts
+		}
+		return transport, nil
+	case "ws":
+		opts := cfgMap(config, "ws-opts")
+		transport := map[string]any{"type": "ws"}
+		setNonEmpty(transport, "path", cfgString(opts, "path"))
+		if host := cfgString(cfgMap(opts, "headers"), "Host"); host != "" {
+			transport["headers"] = map[string]any{"Host": host}
+		}
+		if med := cfgInt(opts, "max-early-data"); med > 0 {
+			transport["max_early_data"] = med
+			setNonEmpty(transport, "early_data_header_name", cfgString(opts, "early-data-header-name"))
+		}
+		return transport, nil
+	case "httpupgrade":
+		opts := cfgMap(config, "ws-opts")
+		transport := map[string]any{"type": "httpupgrade"}
+		setNonEmpty(transport, "path", cfgString(opts, "path"))
+		setNonEmpty(transport, "host", cfgString(cfgMap(opts, "headers"), "Host"))
+		return transport, nil
+	case "grpc":
+		return map[string]any{
+			"type":         "grpc",
+			"service_name": cfgString(cfgMap(config, "grpc-opts"), "grpc-service-name"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("sing-box not supported for network: %s", network)
+	}
+}
+
+func setNonEmpty(m map[string]any, key, value string) {
+	if value != "" {
+		m[key] = value
+	}
+}
+
+// SingBoxExporter 导出 sing-box 配置片段 {"outbounds": [...]}，不支持的节点会被跳过，跳过的节点类型及数量记录到 Logger
+type SingBoxExporter struct {
+	Logger *slog.Logger // 为空时使用 Options.Logger，未设置时使用 slog.Default()
+}
+
+func (e *SingBoxExporter) Export(w io.Writer, results []models.CProxyWithResult) error {
+	outbounds := make([]map[string]any, 0, len(results))
+	skipped := make(map[string]int)
+	for _, result := range results {
+		outbound, err := ProxyToSingBox(result.Proxy.SecretConfig)
+		if err != nil {
+			skipped[strings.ToLower(cfgString(result.Proxy.SecretConfig, "type"))]++
+			continue
+		}
+		outbounds = append(outbounds, outbound)
+	}
+	warnSkipped(e.Logger, "sing-box", skipped)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]any{"outbounds": outbounds})
+}
+
+// singBoxNonProxyTypes 不代表节点的 sing-box outbound 类型，导入时直接忽略
+var singBoxNonProxyTypes = map[string]bool{
+	"direct": true, "block": true, "dns": true, "selector": true, "urltest": true,
+}
+
+// SingBoxToProxy 将 sing-box outbound/endpoint 转换为 mihomo 节点配置，是 ProxyToSingBox 的逆过程。
+// 支持 shadowsocks、vmess、vless（含 reality）、trojan、hysteria2、tuic、wireguard、socks、http，其余类型返回错误
+func SingBoxToProxy(outbound map[string]any) (map[string]any, error) {
+	tp := strings.ToLower(cfgString(outbound, "type"))
+	config := map[string]any{
+		"name":   cfgString(outbound, "tag"),
+		"server": cfgString(outbound, "server"),
+		"port":   cfgInt(outbound, "server_port"),
+		// sing-box 默认同时代理 TCP 与 UDP
+		"udp": cfgString(outbound, "network") != "tcp",
+	}
+	tls := cfgMap(outbound, "tls")
+	switch tp {
+	case "shadowsocks":
+		config["type"] = "ss"
+		config["cipher"] = cfgString(outbound, "method")
+		config["password"] = cfgString(outbound, "password")
+		if cfgBool(outbound, "udp_over_tcp") || cfgBool(cfgMap(outbound, "udp_over_tcp"), "enabled") {
+			config["udp-over-tcp"] = true
+		}
+		opts := parseSingBoxPluginOpts(cfgString(outbound, "plugin_opts"))
+		switch plugin := cfgString(outbound, "plugin"); plugin {
+		case "":
+		case "obfs-local":
+			config["plugin"] = "obfs"
+			config["plugin-opts"] = map[string]any{"mode": opts["obfs"], "host": opts["obfs-host"]}
+		case "v2ray-plugin":
+			pluginOpts := map[string]any{"mode": opts["mode"], "host": opts["host"], "path": opts["path"]}
+			if _, ok := opts["tls"]; ok {
+				pluginOpts["tls"] = true
+			}
+			config["plugin"] = "v2ray-plugin"
+			config["plugin-opts"] = pluginOpts
+		default:
+			return nil, fmt.Errorf("shadowsocks: mihomo not supported for plugin: %s", plugin)
+		}
+	case "vmess":
+		config["type"] = "vmess"
+		config["uuid"] = cfgString(outbound, "uuid")
+		config["alterId"] = cfgInt(outbound, "alter_id")
+		config["cipher"] = cfgString(outbound, "security")
+		if config["cipher"] == "" {
+			config["cipher"] = "auto"
+		}
+		if cfgBool(tls, "enabled") {
+			config["tls"] = true
+			setNonEmpty(config, "servername", cfgString(tls, "server_name"))
+			applySingBoxTLS(config, tls)
+		}
+	case "vless":
+		config["type"] = "vless"
+		config["uuid"] = cfgString(outbound, "uuid")
+		setNonEmpty(config, "flow", cfgString(outbound, "flow"))
+		switch cfgString(outbound, "packet_encoding") {
+		case "packetaddr":
+			config["packet-addr"] = true
+		case "xudp":
+			config["xudp"] = true
+		}
+		if cfgBool(tls, "enabled") {
+			config["tls"] = true
+			setNonEmpty(config, "servername", cfgString(tls, "server_name"))
+			applySingBoxTLS(config, tls)
+			if reality := cfgMap(tls, "reality"); cfgBool(reality, "enabled") {
+				config["reality-opts"] = map[string]any{
+					"public-key": cfgString(reality, "public_key"),
+					"short-id":   cfgString(reality, "short_id"),
+				}
+			}
+		}
+	case "trojan":
+		config["type"] = "trojan"
+		config["password"] = cfgString(outbound, "password")
+		setNonEmpty(config, "sni", cfgString(tls, "server_name"))
+		applySingBoxTLS(config, tls)
+	case "hysteria2":
+		config["type"] = "hysteria2"
+		config["password"] = cfgString(outbound, "password")
+		if up := cfgInt(outbound, "up_mbps"); up > 0 {
+			config["up"] = up
+		}
+		if down := cfgInt(outbound, "down_mbps"); down > 0 {
+			config["down"] = down
+		}
+		if obfs := cfgMap(outbound, "obfs"); obfs != nil {
+			config["obfs"] = cfgString(obfs, "type")
+			config["obfs-password"] = cfgString(obfs, "password")
+		}
+		setNonEmpty(config, "sni", cfgString(tls, "server_name"))
+		applySingBoxTLS(config, tls)
+	case "tuic":
+		config["type"] = "tuic"
+		config["uuid"] = cfgString(outbound, "uuid")
+		config["password"] = cfgString(outbound, "password")
+		setNonEmpty(config, "congestion-controller", cfgString(outbound, "congestion_control"))
+		setNonEmpty(config, "udp-relay-mode", cfgString(outbound, "udp_relay_mode"))
+		if cfgBool(outbound, "zero_rtt_handshake") {
+			config["reduce-rtt"] = true
+		}
+		setNonEmpty(config, "sni", cfgString(tls, "server_name"))
+		if cfgBool(tls, "disable_sni") {
+			config["disable-sni"] = true
+		}
+		applySingBoxTLS(config, tls)
+	case "wireguard":
+		if err := singBoxWireGuard(config, outbound); err != nil {
+			return nil, err
+		}
+	case "socks":
+		config["type"] = "socks5"
+		setNonEmpty(config, "username", cfgString(outbound, "username"))
+		setNonEmpty(config, "password", cfgString(outbound, "password"))
+	case "http":
+		config["type"] = "http"
+		setNonEmpty(config, "username", cfgString(outbound, "username"))
+		setNonEmpty(config, "password", cfgString(outbound, "password"))
+		if cfgBool(tls, "enabled") {
+			config["tls"] = true
+			setNonEmpty(config, "sni", cfgString(tls, "server_name"))
+			applySingBoxTLS(config, tls)
+		}
+	default:
+		return nil, fmt.Errorf("mihomo not supported for sing-box type: %s", tp)
+	}
+	if tp == "vmess" || tp == "vless" || tp == "trojan" {
+		if err := applySingBoxTransport(config, cfgMap(outbound, "transport"), cfgBool(tls, "enabled")); err != nil {
+			return nil, fmt.Errorf("%s: %w", tp, err)
+		}
+	}
+	return config, nil
+}
+
+// applySingBoxTLS 转换 sing-box tls 中除 server_name 外的通用字段
+func applySingBoxTLS(config, tls map[string]any) {
+	if cfgBool(tls, "insecure") {
+		config["skip-cert-verify"] = true
+	}
+	if alpn := cfgStrings(tls, "alpn"); len(alpn) > 0 {
+		config["alpn"] = alpn
+	}
+	if utls := cfgMap(tls, "utls"); cfgBool(utls, "enabled") {
+		setNonEmpty(config, "client-fingerprint", cfgString(utls, "fingerprint"))
+	}
+}
+
+// applySingBoxTransport 转换 sing-box transport，http 传输在启用 TLS 时对应 mihomo 的 h2
+func applySingBoxTransport(config, transport map[string]any, tls bool) error {
+	switch tp := cfgString(transport, "type"); tp {
+	case "":
+	case "ws", "httpupgrade":
+		opts := map[string]any{}
+		setNonEmpty(opts, "path", cfgString(transport, "path"))
+		host := cfgString(cfgMap(transport, "headers"), "Host")
+		if tp == "httpupgrade" {
+			host = cfgString(transport, "host")
+			opts["v2ray-http-upgrade"] = true
+		}
+		if host != "" {
+			opts["headers"] = map[string]any{"Host": host}
+		}
+		if med := cfgInt(transport, "max_early_data"); med > 0 {
+			opts["max-early-data"] = med
+			setNonEmpty(opts, "early-data-header-name", cfgString(transport, "early_data_header_name"))
+		}
+		config["network"] = "ws"
+		config["ws-opts"] = opts
+	case "http":
+		hosts := cfgStrings(transport, "host")
+		path := cfgString(transport, "path")
+		if tls {
+			opts := map[string]any{}
+			if len(hosts) > 0 {
+				opts["host"] = hosts
+			}
+			setNonEmpty(opts, "path", path)
+			config["network"] = "h2"
+			config["h2-opts"] = opts
+			return nil
+		}
+		opts := map[string]any{}
+		setNonEmpty(opts, "method", cfgString(transport, "method"))
+		if path != "" {
+			opts["path"] = []string{path}
+		}
+		if len(hosts) > 0 {
+			opts["headers"] = map[string]any{"Host": hosts}
+		}
+		config["network"] = "http"
+		config["http-opts"] = opts
+	case "grpc":
+		config["network"] = "grpc"
+		config["grpc-opts"] = map[string]any{"grpc-service-name": cfgString(transport, "service_name")}
+	default:
+		return fmt.Errorf("mihomo not supported for transport: %s", tp)
+	}
+	return nil
+}
+
+// singBoxWireGuard 转换 wireguard outbound（旧格式）或 endpoint（sing-box 1.11 起）
+func singBoxWireGuard(config, outbound map[string]any) error {
+	config["type"] = "wireguard"
+	config["private-key"] = cfgString(outbound, "private_key")
+	if mtu := cfgInt(outbound, "mtu"); mtu > 0 {
+		config["mtu"] = mtu
+	}
+	addresses := cfgStrings(outbound, "local_address")
+	if len(addresses) == 0 {
+		addresses = cfgStrings(outbound, "address")
+	}
+	for _, address := range addresses {
+		ip, _, _ := strings.Cut(address, "/")
+		key := "ip"
+		if strings.Contains(ip, ":") {
+			key = "ipv6"
+		}
+		if _, ok := config[key]; !ok {
+			config[key] = ip
+		}
+	}
+
+	peer := outbound
+	if peers, ok := outbound["peers"].([]any); ok {
+		if len(peers) != 1 {
+			return fmt.Errorf("wireguard: only a single peer is supported, got %d", len(peers))
+		}
+		if peer, ok = peers[0].(map[string]any); !ok {
+			return fmt.Errorf("wireguard: invalid peer")
+		}
+		config["server"] = cfgString(peer, "address")
+		config["port"] = cfgInt(peer, "port")
+		config["public-key"] = cfgString(peer, "public_key")
+	} else {
+		config["public-key"] = cfgString(outbound, "peer_public_key")
+	}
+	setNonEmpty(config, "pre-shared-key", cfgString(peer, "pre_shared_key"))
+	if allowed := cfgStrings(peer, "allowed_ips"); len(allowed) > 0 {
+		config["allowed-ips"] = allowed
+	}
+	if reserved, ok := peer["reserved"].([]any); ok && len(reserved) > 0 {
+		values := make([]int, 0, len(reserved))
+		for _, v := range reserved {
+			if n, ok := v.(float64); ok {
+				values = append(values, int(n))
+			}
+		}
+		config["reserved"] = values
+	}
+	return nil
+}
+
+// parseSingBoxPluginOpts 解析 "k=v;k2=v2;flag" 形式的插件参数
+func parseSingBoxPluginOpts(s string) map[string]string {
+	opts := make(map[string]string)
+	for _, item := range strings.Split(s, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(item, "=")
+		opts[k] = v
+	}
+	return opts
+}
+
+// singBoxToProxies 将 sing-box 配置中的 outbounds 与 endpoints 转换为 mihomo 节点，
+// 非节点类型（direct、selector 等）被忽略，无法转换的节点按 sing-box 类型计数
+func singBoxToProxies(body []byte) ([]map[string]any, map[string]int, error) {
+	var cfg struct {
+		Outbounds []map[string]any `json:"outbounds"`
+		Endpoints []map[string]any `json:"endpoints"`
+	}
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		return nil, nil, err
+	}
+	var proxies []map[string]any
+	unsupported := make(map[string]int)
+	for _, outbound := range append(cfg.Outbounds, cfg.Endpoints...) {
+		tp := strings.ToLower(cfgString(outbound, "type"))
+		if singBoxNonProxyTypes[tp] {
+			continue
+		}
+		proxy, err := SingBoxToProxy(outbound)
+		if err != nil {
+			unsupported[tp]++
+			continue
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies, unsupported, nil
+}
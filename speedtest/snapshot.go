@@ -0,0 +1,121 @@
+package speedtest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	snapshotBodyExt = ".body"
+	snapshotMetaExt = ".meta"
+)
+
+// snapshotStore 远程订阅的本地快照，每个订阅对应 <hash>.body（原始内容）与 <hash>.meta（JSON 元数据）。
+// 仅在解析出节点后才更新快照，下载失败、内容为空或解析不出节点时回退到上一次的快照
+type snapshotStore struct {
+	dir string
+	url string
+}
+
+// sourceSnapshot 已保存的快照
+type sourceSnapshot struct {
+	snapshotMeta
+	path string
+}
+
+type snapshotMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	UserInfo     string    `json:"subscription_userinfo,omitempty"` // subscription-userinfo 响应头，304 时沿用
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// snapshotStore 未设置 Options.SnapshotDir 或来源不是订阅链接时返回 nil
+func (l *ProxySourceLoader) snapshotStore(url string) *snapshotStore {
+	if l.Options == nil || l.Options.SnapshotDir == "" || !isRemoteSource(url) {
+		return nil
+	}
+	return &snapshotStore{dir: l.Options.SnapshotDir, url: url}
+}
+
+func (s *snapshotStore) path(ext string) string {
+	hash := sha256.Sum256([]byte(s.url))
+	return filepath.Join(s.dir, hex.EncodeToString(hash[:])+ext)
+}
+
+// load 读取快照元数据，快照不存在或已损坏时返回 nil
+func (s *snapshotStore) load() *sourceSnapshot {
+	if s == nil {
+		return nil
+	}
+	data, err := os.ReadFile(s.path(snapshotMetaExt))
+	if err != nil {
+		return nil
+	}
+	var meta snapshotMeta
+	if err := json.Unmarshal(data, &meta); err != nil || meta.URL != s.url {
+		return nil
+	}
+	snapshot := &sourceSnapshot{snapshotMeta: meta, path: s.path(snapshotBodyExt)}
+	if _, err := os.Stat(snapshot.path); err != nil {
+		return nil
+	}
+	return snapshot
+}
+
+// create 创建用于保存本次下载内容的临时文件
+func (s *snapshotStore) create() (*os.File, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create snapshot dir: %w", err)
+	}
+	return os.CreateTemp(s.dir, ".tmp-*")
+}
+
+// commit 以临时文件替换快照内容并写入元数据
+func (s *snapshotStore) commit(tmp *os.File, header http.Header) error {
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path(snapshotBodyExt)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	data, err := json.Marshal(snapshotMeta{
+		URL:          s.url,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		UserInfo:     header.Get("subscription-userinfo"),
+		FetchedAt:    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(snapshotMetaExt), data)
+}
+
+// discard 丢弃本次下载的内容，保留原有快照
+func (s *snapshotStore) discard(tmp *os.File) {
+	_ = tmp.Close()
+	_ = os.Remove(tmp.Name())
+}
+
+// conditionalHeaders 按快照的 ETag/Last-Modified 设置条件请求头
+func (s *sourceSnapshot) conditionalHeaders(headers map[string]string) {
+	if s == nil {
+		return
+	}
+	if s.ETag != "" {
+		headers["If-None-Match"] = s.ETag
+	}
+	if s.LastModified != "" {
+		headers["If-Modified-Since"] = s.LastModified
+	}
+}
@@ -0,0 +1,162 @@
+package speedtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+// snapshotServer 按当前设置返回订阅内容，带有 etag 时支持 If-None-Match
+type snapshotServer struct {
+	mu     sync.Mutex
+	status int
+	etag   string
+	body   string
+}
+
+func (s *snapshotServer) set(status int, etag, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status, s.etag, s.body = status, etag, body
+}
+
+func (s *snapshotServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status != http.StatusOK {
+		w.WriteHeader(s.status)
+		return
+	}
+	if s.etag != "" {
+		w.Header().Set("ETag", s.etag)
+		if r.Header.Get("If-None-Match") == s.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Header().Set("subscription-userinfo", "upload=1; download=2; total=10")
+	_, _ = w.Write([]byte(s.body))
+}
+
+// loadWithSnapshot 加载一次订阅，返回节点名称与报告
+func loadWithSnapshot(t *testing.T, dir, url string) (string, models.SourceReport) {
+	t.Helper()
+	loader := &ProxySourceLoader{Options: &models.Options{SnapshotDir: dir}, reports: newSourceReports()}
+	var proxies []map[string]any
+	err := loader.LoadStream(context.Background(), url, 10, func(batch []map[string]any) error {
+		proxies = append(proxies, batch...)
+		return nil
+	})
+	reports := loader.reports.list()
+	require.Len(t, reports, 1)
+	if reports[0].Status == models.SourceStatusOK {
+		require.NoError(t, err)
+	}
+	return proxyNames(proxies), reports[0]
+}
+
+const (
+	snapshotV1 = "proxies:\n" +
+		"  - {name: a, type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}\n" +
+		"  - {name: b, type: ss, server: 127.0.0.2, port: 8388, cipher: aes-128-gcm, password: pass}\n"
+	snapshotV2 = "proxies:\n" +
+		"  - {name: c, type: ss, server: 127.0.0.3, port: 8388, cipher: aes-128-gcm, password: pass}\n"
+)
+
+func TestProxySourceLoaderSnapshotNotModified(t *testing.T) {
+	server := &snapshotServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	dir := t.TempDir()
+
+	server.set(http.StatusOK, `"v1"`, snapshotV1)
+	names, report := loadWithSnapshot(t, dir, ts.URL)
+	assert.Equal(t, "a,b", names)
+	assert.Equal(t, models.SourceDataLive, report.Data)
+	assert.True(t, report.SnapshotTime.IsZero())
+
+	names, report = loadWithSnapshot(t, dir, ts.URL)
+	assert.Equal(t, "a,b", names)
+	assert.Equal(t, models.SourceStatusOK, report.Status)
+	assert.Equal(t, http.StatusNotModified, report.HTTPStatus)
+	assert.Equal(t, models.SourceDataCached, report.Data)
+	assert.False(t, report.SnapshotTime.IsZero())
+	assert.Empty(t, report.Error)
+	// 304 响应不带 subscription-userinfo 时沿用快照中的
+	require.NotNil(t, report.Subscription)
+	assert.EqualValues(t, 10, report.Subscription.Total)
+
+	server.set(http.StatusOK, `"v2"`, snapshotV2)
+	names, report = loadWithSnapshot(t, dir, ts.URL)
+	assert.Equal(t, "c", names)
+	assert.Equal(t, models.SourceDataLive, report.Data)
+}
+
+func TestProxySourceLoaderSnapshotFallback(t *testing.T) {
+	server := &snapshotServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	dir := t.TempDir()
+
+	// 没有快照时下载失败即为加载失败
+	server.set(http.StatusInternalServerError, "", "")
+	names, report := loadWithSnapshot(t, dir, ts.URL)
+	assert.Equal(t, "", names)
+	assert.Equal(t, models.SourceStatusError, report.Status)
+
+	server.set(http.StatusOK, "", snapshotV1)
+	names, _ = loadWithSnapshot(t, dir, ts.URL)
+	assert.Equal(t, "a,b", names)
+
+	for _, tc := range []struct {
+		name   string
+		status int
+		body   string
+		reason string
+	}{
+		{name: "server error", status: http.StatusInternalServerError, reason: "status code 500"},
+		{name: "empty body", status: http.StatusOK, body: "\n", reason: "empty content"},
+		{name: "no proxies", status: http.StatusOK, body: "proxies: []\n", reason: "no proxies found"},
+		{name: "invalid", status: http.StatusOK, body: "not a config", reason: "unrecognized config format"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server.set(tc.status, "", tc.body)
+			names, report := loadWithSnapshot(t, dir, ts.URL)
+			// 失败的下载不会覆盖快照
+			assert.Equal(t, "a,b", names)
+			assert.Equal(t, models.SourceStatusOK, report.Status)
+			assert.Equal(t, models.SourceDataCached, report.Data)
+			assert.Contains(t, report.Error, tc.reason)
+			assert.Equal(t, 2, report.Parsed)
+		})
+	}
+
+	// 临时文件不会残留
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, filepath.Base(entry.Name()), ".tmp-")
+	}
+}
+
+func TestProxySourceLoaderSnapshotLocalFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(snapshotV1), 0644))
+	snapshots := filepath.Join(dir, "snapshots")
+
+	names, report := loadWithSnapshot(t, snapshots, path)
+	assert.Equal(t, "a,b", names)
+	assert.Equal(t, models.SourceDataLive, report.Data)
+	// 本地文件不保存快照
+	_, err := os.Stat(snapshots)
+	assert.True(t, os.IsNotExist(err))
+}
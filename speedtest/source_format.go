@@ -0,0 +1,236 @@
+package speedtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/metacubex/mihomo/common/convert"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+	"gopkg.in/yaml.v3"
+)
+
+// sourceFormat 配置内容的格式，记录在 SourceReport.Format 中
+type sourceFormat string
+
+const (
+	sourceFormatClash   sourceFormat = "clash"    // 仅包含 proxies/proxy-providers 的 Clash YAML
+	sourceFormatMihomo  sourceFormat = "mihomo"   // 完整的 mihomo 配置，包含 rules、proxy-groups 等
+	sourceFormatBase64  sourceFormat = "base64"   // base64 编码的分享链接列表
+	sourceFormatURI     sourceFormat = "uri"      // 明文分享链接列表
+	sourceFormatJSON    sourceFormat = "json"     // JSON 格式的 Clash 配置或节点数组
+	sourceFormatSingBox sourceFormat = "sing-box" // sing-box 配置中的 outbounds/endpoints
+	sourceFormatUnknown sourceFormat = "unknown"  // 无法识别
+)
+
+var (
+	errEmptySource   = errors.New("empty content")
+	errUnknownFormat = errors.New("unrecognized config format, expected clash/mihomo yaml, json, sing-box json, surge/loon/quantumult-x list, base64 or uri subscription")
+
+	uriLinePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*://\S`)
+	yamlKeyPattern = regexp.MustCompile(`^([A-Za-z][\w-]*)\s*:`)
+)
+
+// clashKeys 包含节点的顶层键；mihomoKeys 仅在完整 mihomo 配置中出现的顶层键
+var (
+	clashKeys  = []string{"proxies", "proxy-providers"}
+	mihomoKeys = []string{
+		"rules", "rule-providers", "proxy-groups", "port", "socks-port", "mixed-port", "redir-port", "tproxy-port",
+		"mode", "log-level", "allow-lan", "external-controller", "dns", "tun", "sniffer", "listeners",
+	}
+)
+
+// sniffSourceFormat 识别配置内容的格式，返回该格式下待解析的内容（base64 订阅返回解码后的分享链接列表）
+func sniffSourceFormat(body []byte) (sourceFormat, []byte) {
+	body = bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))
+	if len(body) == 0 {
+		return sourceFormatUnknown, body
+	}
+	if (body[0] == '{' || body[0] == '[') && json.Valid(body) {
+		if isSingBoxConfig(body) {
+			return sourceFormatSingBox, body
+		}
+		return sourceFormatJSON, body
+	}
+	if isURIList(body) {
+		return sourceFormatURI, body
+	}
+	if format, ok := sniffProxyList(body); ok {
+		return format, body
+	}
+	if decoded, ok := decodeBase64Subscription(body); ok {
+		if isURIList(decoded) {
+			return sourceFormatBase64, decoded
+		}
+		// base64 编码的 Surge/Loon/Quantumult X 节点列表按解码后的格式记录
+		if format, ok := sniffProxyList(decoded); ok {
+			return format, decoded
+		}
+	}
+	keys := topLevelYAMLKeys(body)
+	switch {
+	case containsAny(keys, mihomoKeys):
+		return sourceFormatMihomo, body
+	case containsAny(keys, clashKeys):
+		return sourceFormatClash, body
+	}
+	return sourceFormatUnknown, body
+}
+
+// isSingBoxConfig JSON 对象包含 outbounds 或 endpoints 且不包含 proxies 时视为 sing-box 配置
+func isSingBoxConfig(body []byte) bool {
+	if body[0] != '{' {
+		return false
+	}
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return false
+	}
+	_, hasOutbounds := keys["outbounds"]
+	_, hasEndpoints := keys["endpoints"]
+	_, hasProxies := keys["proxies"]
+	return (hasOutbounds || hasEndpoints) && !hasProxies
+}
+
+// isURIList 非空、非注释行中分享链接占多数时视为分享链接列表，备注中包含 server 等字样不影响判断
+func isURIList(body []byte) bool {
+	var uris, others int
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(body)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if uriLinePattern.MatchString(line) {
+			uris++
+		} else {
+			others++
+		}
+	}
+	return uris > 0 && uris > others
+}
+
+// decodeBase64Subscription 解码 base64 订阅，支持标准与 URL 安全字符集、有无填充以及换行分隔
+func decodeBase64Subscription(body []byte) ([]byte, bool) {
+	compact := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		}
+		return r
+	}, string(body))
+	compact = strings.TrimRight(compact, "=")
+	if compact == "" {
+		return nil, false
+	}
+	for _, enc := range []*base64.Encoding{base64.RawStdEncoding, base64.RawURLEncoding} {
+		if decoded, err := enc.DecodeString(compact); err == nil {
+			return decoded, true
+		}
+	}
+	return nil, false
+}
+
+// topLevelYAMLKeys 逐行扫描 YAML 的顶层键，不做完整解析
+func topLevelYAMLKeys(body []byte) []string {
+	var keys []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(body)+1)
+	for scanner.Scan() {
+		if m := yamlKeyPattern.FindStringSubmatch(scanner.Text()); m != nil {
+			keys = append(keys, m[1])
+		}
+	}
+	return keys
+}
+
+func containsAny(keys, want []string) bool {
+	for _, key := range keys {
+		for _, w := range want {
+			if key == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parsedSource 解析后的配置内容
+type parsedSource struct {
+	format      sourceFormat
+	config      *models.RawConfig
+	unsupported map[string]int // 无法转换为 mihomo 节点的节点类型及数量，目前仅用于 sing-box 配置
+	lineErrors  []string       // 节点列表中无法解析的行，形如 "line N: 原因"
+}
+
+// parseSourceContent 按识别出的格式解析配置内容，解析失败时错误中带上格式
+func parseSourceContent(body []byte) (*parsedSource, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return &parsedSource{format: sourceFormatUnknown}, errEmptySource
+	}
+	format, payload := sniffSourceFormat(body)
+	parsed := &parsedSource{
+		format: format,
+		config: &models.RawConfig{
+			Proxies: []map[string]any{},
+		},
+	}
+	switch format {
+	case sourceFormatURI, sourceFormatBase64:
+		proxies, err := convert.ConvertsV2Ray(payload)
+		if err != nil {
+			return parsed, fmt.Errorf("parse %s subscription: %w", format, err)
+		}
+		parsed.config.Proxies = proxies
+	case sourceFormatClash, sourceFormatMihomo:
+		if err := yaml.Unmarshal(payload, parsed.config); err != nil {
+			return parsed, fmt.Errorf("parse %s config: %w", format, err)
+		}
+	case sourceFormatJSON:
+		if err := parseJSONSource(payload, parsed.config); err != nil {
+			return parsed, fmt.Errorf("parse json config: %w", err)
+		}
+	case sourceFormatSurge, sourceFormatQuanX:
+		format, proxies, lineErrors := parseProxyList(payload, format)
+		parsed.format = format
+		parsed.config.Proxies = append(parsed.config.Proxies, proxies...)
+		parsed.lineErrors = lineErrors
+		if len(proxies) == 0 {
+			return parsed, fmt.Errorf("parse %s list: no valid proxy lines", format)
+		}
+	case sourceFormatSingBox:
+		proxies, unsupported, err := singBoxToProxies(payload)
+		if err != nil {
+			return parsed, fmt.Errorf("parse sing-box config: %w", err)
+		}
+		parsed.config.Proxies = append(parsed.config.Proxies, proxies...)
+		parsed.unsupported = unsupported
+	default:
+		return parsed, errUnknownFormat
+	}
+	return parsed, nil
+}
+
+// parseJSONSource 解析 JSON 格式的 Clash 配置（包含 proxies/proxy-providers 的对象）或节点数组
+func parseJSONSource(body []byte, rawCfg *models.RawConfig) error {
+	if body[0] == '[' {
+		return yaml.Unmarshal(body, &rawCfg.Proxies)
+	}
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return err
+	}
+	_, hasProxies := keys["proxies"]
+	_, hasProviders := keys["proxy-providers"]
+	if !hasProxies && !hasProviders {
+		return errors.New("no proxies or proxy-providers found")
+	}
+	// JSON 是 YAML 的子集，数字按整数解码，与 YAML 配置保持一致
+	return yaml.Unmarshal(body, rawCfg)
+}
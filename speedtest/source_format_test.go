@@ -0,0 +1,89 @@
+package speedtest
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+const (
+	testSSLink     = "ss://YWVzLTEyOC1nY206cGFzcw@127.0.0.1:8388#server-hk"
+	testTrojanLink = "trojan://pass@127.0.0.1:443?sni=example.com#server-jp"
+)
+
+func TestSniffSourceFormat(t *testing.T) {
+	links := testSSLink + "\n" + testTrojanLink + "\n"
+	std := base64.StdEncoding.EncodeToString([]byte(links))
+	for _, tc := range []struct {
+		name string
+		body string
+		want sourceFormat
+	}{
+		{"clash", "proxies:\n  - {name: a, type: ss, server: 1.1.1.1, port: 1, cipher: aes-128-gcm, password: p}\n", sourceFormatClash},
+		{"providers only", "proxy-providers:\n  p:\n    type: http\n    url: https://example.com\n", sourceFormatClash},
+		{"mihomo", "mixed-port: 7890\nproxies: []\nrules:\n  - MATCH,DIRECT\n", sourceFormatMihomo},
+		// 备注中包含 server 的分享链接列表
+		{"uri", "# comment\n" + links, sourceFormatURI},
+		{"base64 std", std, sourceFormatBase64},
+		{"base64 raw", strings.TrimRight(std, "="), sourceFormatBase64},
+		{"base64 url safe", base64.URLEncoding.EncodeToString([]byte(links)), sourceFormatBase64},
+		{"base64 wrapped", std[:20] + "\r\n" + std[20:], sourceFormatBase64},
+		{"json", `{"proxies":[{"name":"a","type":"ss","server":"1.1.1.1","port":1,"cipher":"aes-128-gcm","password":"p"}]}`, sourceFormatJSON},
+		{"json array", `[{"name":"a"}]`, sourceFormatJSON},
+		{"sing-box", `{"log":{},"outbounds":[{"type":"direct","tag":"direct"}]}`, sourceFormatSingBox},
+		{"bom", "\xef\xbb\xbfproxies: []\n", sourceFormatClash},
+		{"html", "<html><body>server error</body></html>", sourceFormatUnknown},
+		{"empty", "  \n", sourceFormatUnknown},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, _ := sniffSourceFormat([]byte(tc.body)); got != tc.want {
+				t.Fatalf("format = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseSourceContent(t *testing.T) {
+	links := testSSLink + "\n" + testTrojanLink + "\n"
+	for _, body := range []string{
+		links,
+		base64.RawURLEncoding.EncodeToString([]byte(links)),
+		`{"proxies":[{"name":"server-hk","type":"ss","server":"127.0.0.1","port":8388,"cipher":"aes-128-gcm","password":"pass"},{"name":"server-jp","type":"trojan","server":"127.0.0.1","port":443,"password":"pass"}]}`,
+	} {
+		parsed, err := parseSourceContent([]byte(body))
+		if err != nil {
+			t.Fatalf("parseSourceContent(%s) error = %v", parsed.format, err)
+		}
+		if got, want := proxyNames(parsed.config.Proxies), "server-hk,server-jp"; got != want {
+			t.Fatalf("%s names = %s, want %s", parsed.format, got, want)
+		}
+	}
+	if port := mustParseSourceContent(t, `[{"name":"a","type":"ss","port":8388}]`).Proxies[0]["port"]; port != 8388 {
+		t.Fatalf("json port = %#v, want int 8388", port)
+	}
+
+	for _, tc := range []struct {
+		body, format, err string
+	}{
+		{"<html>server error</html>", "unknown", "unrecognized config format"},
+		{"", "unknown", "empty content"},
+		{"proxies:\n  - name: [a\n", "clash", "parse clash config"},
+		{`{"proxy-groups":[]}`, "json", "no proxies or proxy-providers"},
+	} {
+		parsed, err := parseSourceContent([]byte(tc.body))
+		if format := parsed.format; string(format) != tc.format || err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("parseSourceContent(%q) = %s, %v, want %s, %s", tc.body, parsed.format, err, tc.format, tc.err)
+		}
+	}
+}
+
+func mustParseSourceContent(t *testing.T, body string) *models.RawConfig {
+	t.Helper()
+	parsed, err := parseSourceContent([]byte(body))
+	if err != nil {
+		t.Fatalf("parseSourceContent error = %v", err)
+	}
+	return parsed.config
+}
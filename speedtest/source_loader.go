@@ -1,33 +1,57 @@
 package speedtest
 
 import (
-	"bytes"
+	"bufio"
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/metacubex/mihomo/adapter/provider"
-	"github.com/metacubex/mihomo/common/convert"
+	C "github.com/metacubex/mihomo/constant"
 	"github.com/xiecang/speedtest-clash/speedtest/models"
 	"github.com/xiecang/speedtest-clash/speedtest/requests"
-	"gopkg.in/yaml.v3"
 )
 
 type ProxySourceLoader struct {
 	ProxyURL *url.URL
 	Options  *models.Options
+	Stdin    io.Reader // 来源 "-" 读取的内容，nil 时不接受 "-"，避免服务端请求中的 ConfigPath 读取进程的标准输入
+
+	reports *sourceReports // 各配置源的健康报告，由 Test 设置
+	groups  *proxyGroups   // 各配置的 proxy-groups，由 Test 在 EvaluateGroups 时设置
 }
 
 type ProxyBatchHandler func([]map[string]any) error
 
+// SourcedProxyBatchHandler 与 ProxyBatchHandler 相同，额外带上批次的来源。
+// 同一批次的节点来自同一来源，source.Index 为 batch[0] 在来源中的输出序号（见 models.ProxySource.Index），batch[i] 的序号为 source.Index+i
+type SourcedProxyBatchHandler func(source models.ProxySource, batch []map[string]any) error
+
 const defaultSourceBatchSize = 200
 
+// sourceFetchTimeout 下载订阅时连接、等待响应头以及读取响应体时每次等待数据的超时。
+// 响应体边下载边解析并送入测速，测速端处理的耗时不计入；整体耗时受 Options.SourceTimeout 限制
+var sourceFetchTimeout = 60 * time.Second
+
+// defaultSourceTimeout 单个订阅从发起下载到读完内容的默认时间上限
+const defaultSourceTimeout = 30 * time.Minute
+
+// stdinSource ConfigPath 中表示从标准输入读取配置的来源
+const stdinSource = "-"
+
+// maxProviderDepth proxy-providers 的最大嵌套层数，ConfigPath 中的来源为第 0 层
+const maxProviderDepth = 3
+
 func (l *ProxySourceLoader) LoadMany(ctx context.Context, sources []string) ([]map[string]any, error) {
 	var out []map[string]any
 	for _, source := range sources {
@@ -53,6 +77,13 @@ func (l *ProxySourceLoader) LoadManyStream(ctx context.Context, sources []string
 }
 
 func (l *ProxySourceLoader) LoadManyStreamBatched(ctx context.Context, sources []string, batchSize int, fn ProxyBatchHandler) error {
+	return l.LoadManyStreamSourced(ctx, sources, batchSize, func(_ models.ProxySource, batch []map[string]any) error {
+		return fn(batch)
+	})
+}
+
+// LoadManyStreamSourced 与 LoadManyStreamBatched 相同，回调时带上批次的来源（链接/路径、provider 名称、序号）
+func (l *ProxySourceLoader) LoadManyStreamSourced(ctx context.Context, sources []string, batchSize int, fn SourcedProxyBatchHandler) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -73,17 +104,18 @@ func (l *ProxySourceLoader) LoadManyStreamBatched(ctx context.Context, sources [
 	if concurrency > len(parts) {
 		concurrency = len(parts)
 	}
+	loaded := newLoadedSources()
 	partsCh := make(chan string)
 	errCh := make(chan error, 1)
 	var callbackMu sync.Mutex
 	var callbackErr error
-	callback := func(batch []map[string]any) error {
+	callback := func(source models.ProxySource, batch []map[string]any) error {
 		callbackMu.Lock()
 		defer callbackMu.Unlock()
 		if callbackErr != nil {
 			return callbackErr
 		}
-		if err := fn(batch); err != nil {
+		if err := fn(source, batch); err != nil {
 			callbackErr = err
 			cancel()
 			select {
@@ -101,7 +133,10 @@ func (l *ProxySourceLoader) LoadManyStreamBatched(ctx context.Context, sources [
 		go func() {
 			defer wg.Done()
 			for part := range partsCh {
-				if err := l.LoadStream(ctx, part, batchSize, callback); err != nil {
+				task := l.topLevelTask(part)
+				task.loaded = loaded
+				err := l.loadSourced(ctx, task, batchSize, callback)
+				if err != nil {
 					callbackMu.Lock()
 					aborted := callbackErr != nil
 					callbackMu.Unlock()
@@ -147,48 +182,620 @@ func (l *ProxySourceLoader) LoadManyStreamBatched(ctx context.Context, sources [
 }
 
 func (l *ProxySourceLoader) Load(ctx context.Context, source string) ([]map[string]any, error) {
-	body, err := l.readSource(ctx, source)
+	content, err := l.readSource(ctx, l.topLevelTask(source))
 	if err != nil {
 		return nil, err
 	}
-	return l.parse(ctx, body)
+	return l.parse(ctx, content.body)
 }
 
 func (l *ProxySourceLoader) LoadStream(ctx context.Context, source string, batchSize int, fn ProxyBatchHandler) error {
-	body, err := l.readSource(ctx, source)
+	return l.loadSourced(ctx, l.topLevelTask(source), batchSize, func(_ models.ProxySource, batch []map[string]any) error {
+		return fn(batch)
+	})
+}
+
+// sourceTask 一个待加载的配置源及其下载与过滤选项
+type sourceTask struct {
+	source   models.ProxySource
+	options  models.ProxyProvider
+	fetchVia C.Proxy // provider 的 proxy 为所在配置中的节点名称时，经由该节点下载
+
+	chain  []string       // 从 ConfigPath 中的来源到当前来源的加载路径，用于检测循环引用
+	loaded *loadedSources // 本次加载中已加载过的来源
+
+	upstreams []proxyLookup // 上层配置中的节点，provider 中节点的 dialer-proxy 可以引用
+}
+
+// loadedSources 一次加载中已加载过的来源，重复出现的 provider 只加载一次，nil 时视为均未加载
+type loadedSources struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func newLoadedSources() *loadedSources {
+	return &loadedSources{seen: make(map[string]struct{})}
+}
+
+// add 记录来源，已记录过时返回 false
+func (s *loadedSources) add(key string) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seen[key]; ok {
+		return false
+	}
+	s.seen[key] = struct{}{}
+	return true
+}
+
+// sourceKey 来源的唯一标识，本地路径转换为绝对路径
+func sourceKey(location string) string {
+	if isRemoteSource(location) || location == "" || location == stdinSource {
+		return location
+	}
+	if abs, err := filepath.Abs(location); err == nil {
+		return abs
+	}
+	return filepath.Clean(location)
+}
+
+func isRemoteSource(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// resolveProviderPath 将 file 类型 provider 的 path 解析为相对所在配置的位置：
+// 所在配置为本地文件时相对其目录；为订阅链接时按 URL 解析，不会读取本地文件
+func resolveProviderPath(parent, path string) string {
+	if isRemoteSource(parent) {
+		base, err := url.Parse(parent)
+		if err != nil {
+			return path
+		}
+		ref, err := url.Parse(filepath.ToSlash(path))
+		if err != nil {
+			return path
+		}
+		return base.ResolveReference(ref).String()
+	}
+	if parent == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(parent), path)
+}
+
+// providerLocation provider 的链接或路径，file 类型的 path 相对所在配置解析
+func providerLocation(parent string, options models.ProxyProvider) string {
+	if options.Type == "file" || (options.Url == "" && options.Path != "") {
+		return resolveProviderPath(parent, options.Path)
+	}
+	return options.Url
+}
+
+// topLevelTask ConfigPath 中的来源，选项取自 Options.SourceOptions
+func (l *ProxySourceLoader) topLevelTask(source string) sourceTask {
+	task := sourceTask{source: models.ProxySource{URL: source}}
+	if l.Options != nil {
+		if options, ok := l.Options.SourceOptions[source]; ok {
+			task.options = options
+		} else if options, ok := l.Options.SourceOptions["*"]; ok {
+			task.options = options
+		}
+	}
+	return task
+}
+
+// providerTask proxy-providers 中的 provider，file 类型的 path 相对所在配置解析，
+// proxy 为所在配置中的节点名称时解析该节点用于下载
+func (l *ProxySourceLoader) providerTask(parent sourceTask, name string, options models.ProxyProvider, proxies proxyLookup) sourceTask {
+	location := providerLocation(parent.source.URL, options)
+	task := sourceTask{
+		source:    models.ProxySource{URL: location, Provider: name},
+		options:   options,
+		chain:     append(slices.Clone(parent.chain), sourceKey(location)),
+		loaded:    parent.loaded,
+		upstreams: append([]proxyLookup{proxies}, parent.upstreams...),
+	}
+	if options.Proxy == "" || isDirectProxy(options.Proxy) || strings.Contains(options.Proxy, "://") {
+		return task
+	}
+	config, ok := proxies(options.Proxy)
+	if !ok {
+		return task
+	}
+	config, err := newChainResolver(append([]proxyLookup{proxies}, parent.upstreams...)...).resolve(config)
 	if err != nil {
+		warnf(l.Options, "parse proxy %s for provider %s: %s", options.Proxy, name, err)
+		return task
+	}
+	proxy, err := parseProxy(config)
+	if err != nil {
+		warnf(l.Options, "parse proxy %s for provider %s: %s", options.Proxy, name, err)
+		return task
+	}
+	task.fetchVia = proxy
+	return task
+}
+
+// filtered 是否按 mihomo provider 的规则过滤与覆写节点：provider 中的节点总是处理，
+// ConfigPath 中的来源仅在设置了过滤或覆写选项时处理，避免改变普通订阅的节点
+func (t sourceTask) filtered() bool {
+	o := t.options
+	return t.source.Provider != "" || o.Filter != "" || o.ExcludeFilter != "" || o.ExcludeType != "" ||
+		o.DialerProxy != "" || !reflect.ValueOf(o.Override).IsZero()
+}
+
+// checkProvider 检查 provider 是否可以加载：不能引用加载路径上的来源、不能超过最大嵌套层数、
+// 本次加载中已加载过的来源不再重复加载
+func checkProvider(task sourceTask) error {
+	key := task.chain[len(task.chain)-1]
+	if slices.Contains(task.chain[:len(task.chain)-1], key) {
+		return fmt.Errorf("cycle detected: %s", strings.Join(task.chain, " -> "))
+	}
+	if depth := len(task.chain) - 1; depth > maxProviderDepth {
+		return fmt.Errorf("nesting depth %d exceeds %d", depth, maxProviderDepth)
+	}
+	if !task.loaded.add(key) {
+		return fmt.Errorf("%s already loaded", task.source.URL)
+	}
+	return nil
+}
+
+func isDirectProxy(name string) bool {
+	return strings.EqualFold(name, "DIRECT")
+}
+
+func (l *ProxySourceLoader) loadSourced(ctx context.Context, task sourceTask, batchSize int, fn SourcedProxyBatchHandler) error {
+	if task.loaded == nil {
+		task.loaded = newLoadedSources()
+	}
+	if task.chain == nil {
+		// ConfigPath 中的来源已作为 provider 或重复来源加载过时不再加载
+		key := sourceKey(task.source.URL)
+		if !task.loaded.add(key) {
+			warnf(l.Options, "load source %s (skipped): already loaded", task.source.URL)
+			return nil
+		}
+		task.chain = []string{key}
+	}
+	source := task.source
+	snapshots := l.snapshotStore(source.URL)
+	snapshot := snapshots.load()
+	start := time.Now()
+	stream, err := l.openSource(ctx, task, snapshot)
+	subscription := parseSubscriptionInfo(stream.header.Get("subscription-userinfo"))
+	if subscription == nil && snapshot != nil && (err != nil || stream.cached) {
+		subscription = parseSubscriptionInfo(snapshot.UserInfo)
+	}
+	body := &countingReader{elapsed: time.Since(start)}
+	l.reports.update(&source, func(r *models.SourceReport) {
+		r.HTTPStatus = stream.status
+		r.FetchTime = body.elapsed
+		r.Subscription = subscription
+		r.Data = models.SourceDataLive
+		if stream.cached {
+			r.Data = models.SourceDataCached
+			r.SnapshotTime = snapshot.FetchedAt
+		}
+	})
+	if err != nil {
+		if snapshot != nil && ctx.Err() == nil {
+			return l.loadSnapshot(ctx, task, snapshot, err, batchSize, fn)
+		}
+		return l.sourceFailed(source, err)
+	}
+	defer stream.body.Close()
+	body.r = stream.body
+	// 边下载边解析，FetchTime 仅统计建立连接与读取内容的耗时
+	defer l.reports.update(&source, func(r *models.SourceReport) {
+		r.Bytes = body.n
+		r.FetchTime = body.elapsed
+	})
+	l.warnExpiring(source, subscription)
+	if snapshots == nil || stream.cached {
+		return l.parseSourceStream(ctx, body, task, batchSize, fn)
+	}
+
+	// 下载的内容同时写入临时文件，解析出节点后替换快照，否则回退到原有快照
+	tmp, err := snapshots.create()
+	if err != nil {
+		warnf(l.Options, "source %s: save snapshot: %s", source.String(), err)
+		return l.parseSourceStream(ctx, body, task, batchSize, fn)
+	}
+	body.r = io.TeeReader(stream.body, tmp)
+	emitted := 0
+	err = l.parseSourceStream(ctx, body, task, batchSize, func(source models.ProxySource, batch []map[string]any) error {
+		emitted += len(batch)
+		return fn(source, batch)
+	})
+	if err == nil && emitted > 0 {
+		if _, err = io.Copy(io.Discard, body); err == nil {
+			err = snapshots.commit(tmp, stream.header)
+		}
+		if err != nil {
+			snapshots.discard(tmp)
+			warnf(l.Options, "source %s: save snapshot: %s", source.String(), err)
+		}
+		return nil
+	}
+	snapshots.discard(tmp)
+	// 已输出节点后失败时无法回退，避免重复输出
+	if snapshot == nil || emitted > 0 || ctx.Err() != nil || (err != nil && !isSourceLoadError(err)) {
 		return err
 	}
-	return l.parseStream(ctx, body, batchSize, fn)
+	if err == nil {
+		err = errors.New("no proxies found")
+	}
+	return l.loadSnapshot(ctx, task, snapshot, err, batchSize, fn)
+}
+
+// loadSnapshot 本次下载失败或未解析出节点时改为解析上一次成功的快照，失败原因记录在报告的 Error 中
+func (l *ProxySourceLoader) loadSnapshot(ctx context.Context, task sourceTask, snapshot *sourceSnapshot, reason error, batchSize int, fn SourcedProxyBatchHandler) error {
+	source := task.source
+	file, err := os.Open(snapshot.path)
+	if err != nil {
+		return l.sourceFailed(source, fmt.Errorf("%s; open snapshot: %w", reason, err))
+	}
+	defer file.Close()
+	warnf(l.Options, "source %s: %s, using snapshot from %s", source.String(), reason, snapshot.FetchedAt.Format(time.DateTime))
+	l.reports.update(&source, func(r *models.SourceReport) {
+		r.Status = models.SourceStatusOK
+		r.Error = reason.Error()
+		r.Data = models.SourceDataCached
+		r.SnapshotTime = snapshot.FetchedAt
+		r.Unsupported = nil
+		r.LineErrors = nil
+		r.ChainErrors = nil
+	})
+	return l.parseSourceStream(ctx, file, task, batchSize, fn)
+}
+
+// countingReader 统计读取的字节数与耗时
+type countingReader struct {
+	r       io.Reader
+	n       int64
+	elapsed time.Duration
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	c.elapsed += time.Since(start)
+	return n, err
+}
+
+// sniffPrefixSize 流式读取时用于识别格式的前缀长度
+const sniffPrefixSize = 64 * 1024
+
+// parseSourceStream 根据内容前缀识别格式：Clash/mihomo YAML 逐个节点解码并立即分批输出，
+// 其它格式读取全部内容后解析
+func (l *ProxySourceLoader) parseSourceStream(ctx context.Context, r io.Reader, task sourceTask, batchSize int, fn SourcedProxyBatchHandler) error {
+	source := task.source
+	reader := bufio.NewReaderSize(r, sniffPrefixSize)
+	prefix, err := reader.Peek(sniffPrefixSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return l.sourceFailed(source, fmt.Errorf("read config: %w", err))
+	}
+	if format, _ := sniffSourceFormat(prefix); format == sourceFormatClash || format == sourceFormatMihomo {
+		return l.parseYAMLStream(ctx, reader, task, format, batchSize, fn)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return l.sourceFailed(source, fmt.Errorf("read config: %w", err))
+	}
+	return l.parseSourced(ctx, body, task, batchSize, fn)
 }
 
-func (l *ProxySourceLoader) readSource(ctx context.Context, source string) ([]byte, error) {
-	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
-		resp, err := requests.Request(ctx, &requests.RequestOption{
+// parseYAMLStream 流式解析 Clash/mihomo YAML，proxy-providers 在 proxies 全部输出后加载。
+// provider 只有一个 filter 时逐个节点过滤，输出后不再保留节点；多个 filter 时按 mihomo 的顺序需在读取结束后处理。
+// dialer-proxy、relay 组与子 provider 引用的节点通过 streamedProxies 按名称重新读取
+func (l *ProxySourceLoader) parseYAMLStream(ctx context.Context, r io.Reader, task sourceTask, format sourceFormat, batchSize int, fn SourcedProxyBatchHandler) error {
+	source := task.source
+	l.reports.update(&source, func(r *models.SourceReport) {
+		r.Format = string(format)
+	})
+	var filter *providerFilter
+	if task.filtered() {
+		var err error
+		if filter, err = newProviderFilter(task.options); err != nil {
+			return l.sourceFailed(source, err)
+		}
+	}
+
+	emit := newProxyBatchEmitter(source, batchSize, fn)
+	var retained []map[string]any // 多个 filter 时的所有节点
+	var chained []map[string]any  // 设置了 dialer-proxy 的节点，读取结束后解析前置节点再输出
+	var index *streamedProxies
+	seen := make(map[string]struct{})
+	parsed, admitted := 0, 0 // admitted 为未被 provider 的 filter 等排除的节点数
+	var emitErr error
+	stream := newClashStream(func(line int, proxy map[string]any) error {
+		if err := ctx.Err(); err != nil {
+			emitErr = err
+			return err
+		}
+		parsed++
+		if filter != nil && !filter.streamable() {
+			retained = append(retained, proxy)
+			return nil
+		}
+		ok := true
+		if filter != nil {
+			var err error
+			if ok, err = filter.admit(filter.filters[0], proxy, seen); err != nil {
+				return fmt.Errorf("proxy at line %d override error: %w", line, err)
+			}
+		}
+		// 保留的节点已覆写，以覆写后的名称记录
+		index.add(proxy, filter != nil && ok)
+		if !ok {
+			return nil
+		}
+		admitted++
+		if dialerProxy(proxy) != "" {
+			chained = append(chained, proxy)
+			return nil
+		}
+		emitErr = emit.Add(proxy)
+		return emitErr
+	})
+	defer stream.close()
+	index = newStreamedProxies(stream, filter)
+	cfg, itemErrors, err := stream.decode(r)
+	if stream.fallback != "" {
+		debugf(l.Options, "source %s: %s, decoded the whole config", source.String(), stream.fallback)
+	}
+	l.reports.update(&source, func(r *models.SourceReport) {
+		r.LineErrors = itemErrors
+	})
+	if err != nil {
+		if emitErr != nil {
+			return err
+		}
+		return l.sourceFailed(source, err)
+	}
+	if len(itemErrors) > 0 {
+		if parsed == 0 {
+			return l.sourceFailed(source, fmt.Errorf("parse %s config: %s", format, itemErrors[0]))
+		}
+		warnf(l.Options, "source %s: skipped %d invalid proxies, first: %s", source.String(), len(itemErrors), itemErrors[0])
+	}
+	lookup := index.lookup
+	if filter != nil && !filter.streamable() {
+		filtered, err := filter.apply(retained)
+		if err != nil {
+			return l.sourceFailed(source, err)
+		}
+		lookup = sliceLookup(retained)
+		admitted = len(filtered)
+		for _, proxy := range filtered {
+			if dialerProxy(proxy) != "" {
+				chained = append(chained, proxy)
+				continue
+			}
+			if err := emit.Add(proxy); err != nil {
+				return err
+			}
+		}
+	}
+	chained, relays := l.resolveChains(task, lookup, chained, cfg.ProxyGroups)
+	l.groups.add(task, cfg.ProxyGroups, cfg.Providers)
+	for _, proxy := range append(chained, relays...) {
+		if err := emit.Add(proxy); err != nil {
+			return err
+		}
+	}
+	if err := emit.Flush(); err != nil {
+		return err
+	}
+	l.reports.update(&source, func(r *models.SourceReport) {
+		r.Parsed = parsed
+		r.Excluded = parsed - admitted
+	})
+	return l.loadProviders(ctx, task, cfg.Providers, lookup, batchSize, fn)
+}
+
+// streamedProxies 流式解析的配置中的节点，供 dialer-proxy、relay 组与 provider 的 proxy 按名称查找。
+// 只记录节点在临时文件中的位置，查找时重新解码并对 provider 保留的节点再次应用 dialer-proxy 与 override；
+// 无法按位置读取的节点（[...] 形式或回退到完整解码时）保留配置
+type streamedProxies struct {
+	stream  *clashStream
+	filter  *providerFilter
+	spans   map[string]streamedSpan
+	configs map[string]map[string]any
+}
+
+type streamedSpan struct {
+	span      yamlSpan
+	rewritten bool // 经 provider 的 filter 保留并覆写
+}
+
+func newStreamedProxies(stream *clashStream, filter *providerFilter) *streamedProxies {
+	return &streamedProxies{
+		stream:  stream,
+		filter:  filter,
+		spans:   make(map[string]streamedSpan),
+		configs: make(map[string]map[string]any),
+	}
+}
+
+// add 记录正在回调的节点，同名节点以先定义的为准
+func (p *streamedProxies) add(proxy map[string]any, rewritten bool) {
+	name, _ := proxy["name"].(string)
+	if name == "" {
+		return
+	}
+	if _, ok := p.spans[name]; ok {
+		return
+	}
+	if _, ok := p.configs[name]; ok {
+		return
+	}
+	if span, ok := p.stream.span(); ok {
+		p.spans[name] = streamedSpan{span: span, rewritten: rewritten}
+		return
+	}
+	p.configs[name] = proxy
+}
+
+// lookup 实现 proxyLookup。节点在输出时已解码成功，重新读取只在临时文件不可读时失败，此时视为不存在
+func (p *streamedProxies) lookup(name string) (map[string]any, bool) {
+	if proxy, ok := p.configs[name]; ok {
+		return proxy, true
+	}
+	entry, ok := p.spans[name]
+	if !ok {
+		return nil, false
+	}
+	proxy, err := p.stream.readItem(entry.span)
+	if err == nil && entry.rewritten {
+		err = p.filter.rewrite(proxy)
+	}
+	if err != nil {
+		return nil, false
+	}
+	return proxy, true
+}
+
+// warnExpiring 订阅已过期或即将过期时输出警告
+func (l *ProxySourceLoader) warnExpiring(source models.ProxySource, subscription *models.SubscriptionInfo) {
+	window := defaultSubscriptionExpiryWarning
+	if l.Options != nil && l.Options.SubscriptionExpiryWarning > 0 {
+		window = l.Options.SubscriptionExpiryWarning
+	}
+	if !subscription.ExpiresWithin(window, time.Now()) {
+		return
+	}
+	warnf(l.Options, "subscription %s expires at %s", source.String(), subscription.ExpireTime().Format(time.DateTime))
+}
+
+// sourceFailed 将下载或解析失败记录到来源的报告中
+func (l *ProxySourceLoader) sourceFailed(source models.ProxySource, err error) error {
+	l.reports.update(&source, func(r *models.SourceReport) {
+		r.Status = models.SourceStatusError
+		r.Error = err.Error()
+	})
+	return &sourceLoadError{err: err}
+}
+
+// sourceContent 下载或读取到的配置内容，本地文件的 status 为 0、header 为空
+type sourceContent struct {
+	body   []byte
+	status int
+	header http.Header
+}
+
+// sourceStream 打开的配置内容，调用方负责关闭 body；失败时 body 为 nil
+type sourceStream struct {
+	body   io.ReadCloser
+	status int
+	header http.Header
+	cached bool // 订阅未更新（304），body 为本地快照
+}
+
+// readSource 下载或读取全部配置内容，下载失败时仍返回已知的状态码与响应头
+func (l *ProxySourceLoader) readSource(ctx context.Context, task sourceTask) (sourceContent, error) {
+	stream, err := l.openSource(ctx, task, nil)
+	if err != nil {
+		return sourceContent{status: stream.status, header: stream.header}, err
+	}
+	defer stream.body.Close()
+	body, err := io.ReadAll(stream.body)
+	if err != nil {
+		return sourceContent{status: stream.status, header: stream.header}, fmt.Errorf("read config %s: %w", task.source.URL, err)
+	}
+	return sourceContent{body: body, status: stream.status, header: stream.header}, nil
+}
+
+// openSource 发起下载或打开本地文件，不读取内容；下载失败时仍返回已知的状态码与响应头。
+// snapshot 不为 nil 时发起条件请求，订阅未更新时返回快照内容
+func (l *ProxySourceLoader) openSource(ctx context.Context, task sourceTask, snapshot *sourceSnapshot) (sourceStream, error) {
+	source := task.source.URL
+	if isRemoteSource(source) {
+		option := &requests.RequestOption{
 			Method:             http.MethodGet,
 			URL:                source,
-			Headers:            map[string]string{"User-Agent": "clash-meta"},
-			Timeout:            60 * time.Second,
+			Headers:            sourceHeaders(task.options.Header),
+			Timeout:            sourceFetchTimeout,
+			MaxDuration:        l.sourceTimeout(),
 			RetryTimes:         3,
 			RetryTimeOut:       3 * time.Second,
 			ProxyUrl:           l.ProxyURL,
 			InsecureSkipVerify: true,
 			Logger:             loggerFromOptions(l.Options),
-		})
+		}
+		snapshot.conditionalHeaders(option.Headers)
+		if err := l.applyFetchProxy(option, task); err != nil {
+			return sourceStream{}, fmt.Errorf("fetch config %s: %w", source, err)
+		}
+		resp, err := requests.RequestStream(ctx, option)
 		if err != nil {
-			return nil, fmt.Errorf("fetch config %s: %w", source, err)
+			return sourceStream{}, fmt.Errorf("fetch config %s: %w", source, err)
+		}
+		if resp.StatusCode == http.StatusNotModified && snapshot != nil {
+			resp.Body.Close()
+			file, err := os.Open(snapshot.path)
+			if err != nil {
+				return sourceStream{status: resp.StatusCode, header: resp.Header}, fmt.Errorf("open snapshot of %s: %w", source, err)
+			}
+			return sourceStream{body: file, status: resp.StatusCode, header: resp.Header, cached: true}, nil
 		}
 		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("fetch config %s: status code %d", source, resp.StatusCode)
+			resp.Body.Close()
+			return sourceStream{status: resp.StatusCode, header: resp.Header}, fmt.Errorf("fetch config %s: status code %d", source, resp.StatusCode)
 		}
-		return resp.Body, nil
+		return sourceStream{body: resp.Body, status: resp.StatusCode, header: resp.Header}, nil
 	}
 
-	body, err := os.ReadFile(source)
+	if source == stdinSource {
+		if l.Stdin == nil {
+			return sourceStream{}, errors.New("reading config from stdin is not enabled")
+		}
+		return sourceStream{body: io.NopCloser(l.Stdin)}, nil
+	}
+
+	file, err := os.Open(source)
 	if err != nil {
-		return nil, fmt.Errorf("read local file %s: %w", source, err)
+		return sourceStream{}, fmt.Errorf("read local file %s: %w", source, err)
+	}
+	return sourceStream{body: file}, nil
+}
+
+// sourceHeaders 下载请求头，默认 User-Agent 为 clash-meta，多个值以 ", " 连接
+func sourceHeaders(header map[string][]string) map[string]string {
+	headers := map[string]string{"User-Agent": "clash-meta"}
+	for k, v := range header {
+		if len(v) == 0 {
+			continue
+		}
+		headers[http.CanonicalHeaderKey(k)] = strings.Join(v, ", ")
 	}
-	return body, nil
+	return headers
+}
+
+// applyFetchProxy 按来源的 proxy 选项设置下载代理，未设置时使用 ProxyURL
+func (l *ProxySourceLoader) applyFetchProxy(option *requests.RequestOption, task sourceTask) error {
+	proxy := task.options.Proxy
+	switch {
+	case task.fetchVia != nil:
+		option.ProxyUrl = nil
+		option.Client = requests.GetClient(task.fetchVia, option.Timeout)
+	case proxy == "":
+	case isDirectProxy(proxy):
+		option.ProxyUrl = nil
+	case strings.Contains(proxy, "://"):
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy %s: %w", proxy, err)
+		}
+		option.ProxyUrl = u
+	default:
+		return fmt.Errorf("proxy %s not found", proxy)
+	}
+	return nil
 }
 
 func (l *ProxySourceLoader) parse(ctx context.Context, body []byte) ([]map[string]any, error) {
@@ -201,33 +808,96 @@ func (l *ProxySourceLoader) parse(ctx context.Context, body []byte) ([]map[strin
 }
 
 func (l *ProxySourceLoader) parseStream(ctx context.Context, body []byte, batchSize int, fn ProxyBatchHandler) error {
-	rawCfg := &models.RawConfig{
-		Proxies: []map[string]any{},
+	return l.parseSourced(ctx, body, sourceTask{}, batchSize, func(_ models.ProxySource, batch []map[string]any) error {
+		return fn(batch)
+	})
+}
+
+// parseSourced 解析配置内容，proxy-providers 中的节点以 provider 的链接与名称作为来源
+func (l *ProxySourceLoader) parseSourced(ctx context.Context, body []byte, task sourceTask, batchSize int, fn SourcedProxyBatchHandler) error {
+	source := task.source
+	parsed, err := parseSourceContent(body)
+	l.reports.update(&source, func(r *models.SourceReport) {
+		r.Format = string(parsed.format)
+		if len(parsed.unsupported) > 0 {
+			r.Unsupported = parsed.unsupported
+		}
+		r.LineErrors = parsed.lineErrors
+	})
+	if err != nil {
+		return l.sourceFailed(source, err)
 	}
-	emit := newProxyBatchEmitter(batchSize, fn)
-	if !bytes.Contains(body, []byte("server")) {
-		if bytes.Contains(body, []byte("://")) {
-			body = []byte(base64.StdEncoding.EncodeToString(body))
+	if len(parsed.unsupported) > 0 {
+		warnf(l.Options, "source %s: skipped unsupported proxies %s", source.String(), formatTypeCounts(parsed.unsupported))
+	}
+	if len(parsed.lineErrors) > 0 {
+		warnf(l.Options, "source %s: skipped %d invalid lines, first: %s", source.String(), len(parsed.lineErrors), parsed.lineErrors[0])
+	}
+	rawCfg := parsed.config
+
+	if err := l.emitProxies(ctx, task, rawCfg.Proxies, rawCfg.ProxyGroups, batchSize, fn); err != nil {
+		return err
+	}
+	l.groups.add(task, rawCfg.ProxyGroups, rawCfg.Providers)
+	return l.loadProviders(ctx, task, rawCfg.Providers, sliceLookup(rawCfg.Proxies), batchSize, fn)
+}
+
+// loadProviders 按名称顺序依次加载配置中的 proxy-providers，proxies 查找所在配置中的节点
+func (l *ProxySourceLoader) loadProviders(ctx context.Context, task sourceTask, providers map[string]models.ProxyProvider, proxies proxyLookup, batchSize int, fn SourcedProxyBatchHandler) error {
+	if task.loaded == nil {
+		task.loaded = newLoadedSources()
+	}
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		config := providers[name]
+		if name == provider.ReservedName {
+			warnf(l.Options, "can not defined a provider called `%s`", provider.ReservedName)
+			continue
 		}
-		proxyList, err := convert.ConvertsV2Ray(body)
-		if err != nil {
-			return fmt.Errorf("convert proxies: %w", err)
+		child := l.providerTask(task, name, config, proxies)
+		if err := checkProvider(child); err != nil {
+			warnf(l.Options, "load provider %s (skipped): %s", name, err)
+			continue
 		}
-		for _, proxy := range proxyList {
-			if err := ctx.Err(); err != nil {
-				return err
-			}
-			if err := emit.Add(proxy); err != nil {
-				return err
+		if err := l.loadSourced(ctx, child, batchSize, fn); err != nil {
+			// provider 下载或解析失败时跳过该 provider，错误已记录到其报告中
+			if isSourceLoadError(err) && ctx.Err() == nil {
+				warnf(l.Options, "load provider %s error (skipped): %s", name, err)
+				continue
 			}
+			return err
 		}
-		return emit.Flush()
 	}
-	if err := yaml.Unmarshal(body, rawCfg); err != nil {
-		return fmt.Errorf("parse config: %w", err)
+	return nil
+}
+
+// emitProxies 按来源的 filter/exclude-filter/exclude-type/override 处理节点，解析 dialer-proxy 与 relay 组后分批输出，
+// 并记录解析与排除的节点数
+func (l *ProxySourceLoader) emitProxies(ctx context.Context, task sourceTask, proxies, groups []map[string]any, batchSize int, fn SourcedProxyBatchHandler) error {
+	source := task.source
+	parsed := len(proxies)
+	all := proxies
+	if task.filtered() {
+		filter, err := newProviderFilter(task.options)
+		if err != nil {
+			return l.sourceFailed(source, err)
+		}
+		if proxies, err = filter.apply(proxies); err != nil {
+			return l.sourceFailed(source, err)
+		}
 	}
+	l.reports.update(&source, func(r *models.SourceReport) {
+		r.Parsed = parsed
+		r.Excluded = parsed - len(proxies)
+	})
+	proxies, relays := l.resolveChains(task, sliceLookup(all), proxies, groups)
 
-	for _, proxy := range rawCfg.Proxies {
+	emit := newProxyBatchEmitter(source, batchSize, fn)
+	for _, proxy := range append(proxies, relays...) {
 		if err := ctx.Err(); err != nil {
 			return err
 		}
@@ -235,19 +905,57 @@ func (l *ProxySourceLoader) parseStream(ctx context.Context, body []byte, batchS
 			return err
 		}
 	}
-	if err := emit.Flush(); err != nil {
-		return err
+	return emit.Flush()
+}
+
+// resolveChains 将设置了 dialer-proxy 的节点与所在配置（及上层配置）中的前置节点组成链式节点，
+// 并将 relay 组转换为链式节点。all 查找所在配置中的所有节点；dialer-proxy 无法解析的节点（如引用代理组）
+// 不经前置节点无法连接，与无法解析的 relay 组一同被丢弃，原因记录在 SourceReport.ChainErrors 中
+func (l *ProxySourceLoader) resolveChains(task sourceTask, all proxyLookup, proxies, groups []map[string]any) ([]map[string]any, []map[string]any) {
+	hasDialer := slices.ContainsFunc(proxies, func(proxy map[string]any) bool { return dialerProxy(proxy) != "" })
+	hasRelay := slices.ContainsFunc(groups, func(group map[string]any) bool { return group["type"] == "relay" })
+	if !hasDialer && !hasRelay {
+		return proxies, nil
 	}
-	for name, config := range rawCfg.Providers {
-		if name == provider.ReservedName {
-			warnf(l.Options, "can not defined a provider called `%s`", provider.ReservedName)
+	source := task.source
+	resolver := newChainResolver(append([]proxyLookup{all}, task.upstreams...)...)
+	resolver.addGroups(groups)
+	var errs []error
+	chains := 0
+	resolved := make([]map[string]any, 0, len(proxies))
+	for _, proxy := range proxies {
+		out, err := resolver.resolve(proxy)
+		if err != nil {
+			errs = append(errs, err)
 			continue
 		}
-		if err := l.LoadStream(ctx, config.Url, batchSize, fn); err != nil {
-			return err
+		if out[models.ChainKey] != nil {
+			chains++
 		}
+		resolved = append(resolved, out)
 	}
-	return nil
+	relays, relayErrs := resolver.relays(groups)
+	errs = append(errs, relayErrs...)
+	if len(errs) > 0 {
+		warnf(l.Options, "source %s: %d proxy chains unresolved and dropped, first: %s", source.String(), len(errs), errs[0])
+	}
+	chainErrors := make([]string, 0, len(errs))
+	for _, err := range errs {
+		chainErrors = append(chainErrors, err.Error())
+	}
+	l.reports.update(&source, func(r *models.SourceReport) {
+		r.Chains = chains + len(relays)
+		r.ChainErrors = chainErrors
+	})
+	return resolved, relays
+}
+
+// sourceTimeout 单个订阅下载的时间上限，Options.SourceTimeout < 0 时不限制
+func (l *ProxySourceLoader) sourceTimeout() time.Duration {
+	if l.Options == nil || l.Options.SourceTimeout == 0 {
+		return defaultSourceTimeout
+	}
+	return max(l.Options.SourceTimeout, 0)
 }
 
 func (l *ProxySourceLoader) sourceConcurrency() int {
@@ -265,16 +973,19 @@ func (l *ProxySourceLoader) sourceBatchSize() int {
 }
 
 type proxyBatchEmitter struct {
+	source    models.ProxySource
 	batchSize int
-	fn        ProxyBatchHandler
+	fn        SourcedProxyBatchHandler
 	batch     []map[string]any
+	emitted   int // 已输出的节点数，即下一批次首个节点的序号
 }
 
-func newProxyBatchEmitter(batchSize int, fn ProxyBatchHandler) *proxyBatchEmitter {
+func newProxyBatchEmitter(source models.ProxySource, batchSize int, fn SourcedProxyBatchHandler) *proxyBatchEmitter {
 	if batchSize <= 0 {
 		batchSize = defaultSourceBatchSize
 	}
 	return &proxyBatchEmitter{
+		source:    source,
 		batchSize: batchSize,
 		fn:        fn,
 		batch:     make([]map[string]any, 0, batchSize),
@@ -295,5 +1006,8 @@ func (e *proxyBatchEmitter) Flush() error {
 	}
 	batch := e.batch
 	e.batch = make([]map[string]any, 0, e.batchSize)
-	return e.fn(batch)
+	source := e.source
+	source.Index = e.emitted
+	e.emitted += len(batch)
+	return e.fn(source, batch)
 }
@@ -2,9 +2,15 @@ package speedtest
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/xiecang/speedtest-clash/speedtest/models"
 )
@@ -95,3 +101,296 @@ func sprintStrings(values []string) string {
 	}
 	return out
 }
+
+func TestProxySourceLoaderLoadManyStreamSourcedTracksProvenance(t *testing.T) {
+	provider := writeProxyConfig(t, "p1", "p2", "p3")
+	body := "proxy-providers:\n  paid:\n    type: http\n    url: " + provider + "\n" +
+		"proxies:\n  - {name: a1, type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}\n"
+	path := filepath.Join(t.TempDir(), "main.yaml")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	loader := &ProxySourceLoader{}
+
+	var got []string
+	err := loader.LoadManyStreamSourced(context.Background(), []string{path}, 2, func(source models.ProxySource, batch []map[string]any) error {
+		for i, proxy := range batch {
+			got = append(got, fmt.Sprintf("%s@%s#%d", proxy["name"], source.Provider, source.Index+i))
+			wantURL := path
+			if source.Provider != "" {
+				wantURL = provider
+			}
+			if source.URL != wantURL {
+				t.Fatalf("source url = %s, want %s", source.URL, wantURL)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("LoadManyStreamSourced error = %v", err)
+	}
+	if got, want := sprintStrings(got), "a1@#0,p1@paid#0,p2@paid#1,p3@paid#2"; got != want {
+		t.Fatalf("provenance = %s, want %s", got, want)
+	}
+}
+
+func TestProxySourceLoaderLoadsProvidersInNameOrder(t *testing.T) {
+	body := "proxy-providers:\n"
+	for _, name := range []string{"gamma", "alpha", "delta", "beta"} {
+		body += "  " + name + ":\n    type: file\n    path: " + writeProxyConfig(t, name+"-1") + "\n"
+	}
+	path := filepath.Join(t.TempDir(), "main.yaml")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	// map 的遍历顺序是随机的，多次加载确认顺序稳定
+	for i := 0; i < 5; i++ {
+		var got []string
+		err := (&ProxySourceLoader{}).LoadManyStreamSourced(context.Background(), []string{path}, 10, func(source models.ProxySource, batch []map[string]any) error {
+			got = append(got, source.Provider)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("LoadManyStreamSourced error = %v", err)
+		}
+		if got, want := sprintStrings(got), "alpha,beta,delta,gamma"; got != want {
+			t.Fatalf("provider order = %s, want %s", got, want)
+		}
+	}
+}
+
+func TestProxySourceLoaderProviderHeaderAndProxy(t *testing.T) {
+	const proxies = "proxies:\n  - {name: p1, type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}\n"
+	var origin, viaProxy []string
+	originServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin = append(origin, r.URL.Path+" "+r.Header.Get("User-Agent")+" "+r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(proxies))
+	}))
+	defer originServer.Close()
+	// 作为 HTTP 代理，收到的是带完整 URL 的请求
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		viaProxy = append(viaProxy, r.URL.Path+" "+r.Header.Get("User-Agent"))
+		_, _ = w.Write([]byte(proxies))
+	}))
+	defer proxyServer.Close()
+
+	body := "proxy-providers:\n" +
+		"  token:\n    type: http\n    url: " + originServer.URL + "/token\n" +
+		"    header:\n      User-Agent: [ClashX/1.0]\n      authorization: [Bearer abc]\n" +
+		"  proxied:\n    type: http\n    url: " + originServer.URL + "/proxied\n    proxy: " + proxyServer.URL + "\n" +
+		"  direct:\n    type: http\n    url: " + originServer.URL + "/direct\n    proxy: DIRECT\n" +
+		proxies
+	path := filepath.Join(t.TempDir(), "main.yaml")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	loader := &ProxySourceLoader{}
+	count := 0
+	err := loader.LoadStream(context.Background(), path, 10, func(batch []map[string]any) error {
+		count += len(batch)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("LoadStream error = %v", err)
+	}
+	if count != 4 {
+		t.Fatalf("proxy count = %d, want 4", count)
+	}
+	sort.Strings(origin)
+	if got, want := sprintStrings(origin), "/direct clash-meta ,/token ClashX/1.0 Bearer abc"; got != want {
+		t.Fatalf("origin requests = %q, want %q", got, want)
+	}
+	if got, want := sprintStrings(viaProxy), "/proxied clash-meta"; got != want {
+		t.Fatalf("proxied requests = %q, want %q", got, want)
+	}
+}
+
+func TestProxySourceLoaderTopLevelSourceOptions(t *testing.T) {
+	var agents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.Header.Get("User-Agent"))
+		_, _ = w.Write([]byte("proxies:\n  - {name: p1, type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}\n"))
+	}))
+	defer server.Close()
+
+	loader := &ProxySourceLoader{Options: &models.Options{SourceOptions: map[string]models.ProxyProvider{
+		server.URL + "/a": {Header: map[string][]string{"User-Agent": {"sub-a"}}},
+		"*":               {Header: map[string][]string{"User-Agent": {"default"}}},
+	}}}
+	err := loader.LoadManyStream(context.Background(), []string{server.URL + "/a|" + server.URL + "/b"}, func(batch []map[string]any) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("LoadManyStream error = %v", err)
+	}
+	if got, want := sprintStrings(agents), "sub-a,default"; got != want {
+		t.Fatalf("user agents = %s, want %s", got, want)
+	}
+}
+
+func TestProxySourceLoaderUnknownProviderProxy(t *testing.T) {
+	loader := &ProxySourceLoader{}
+	_, err := loader.readSource(context.Background(), sourceTask{
+		source:  models.ProxySource{URL: "http://127.0.0.1:1/sub"},
+		options: models.ProxyProvider{Proxy: "missing-node"},
+	})
+	if err == nil {
+		t.Fatal("expected error for unknown provider proxy")
+	}
+}
+
+func TestProxySourceLoaderAppliesProviderFilter(t *testing.T) {
+	provider := writeProxyConfig(t, "hk-01", "jp-01", "hk-02")
+	body := "proxy-providers:\n  paid:\n    type: http\n    url: " + provider + "\n" +
+		"    filter: hk\n    exclude-filter: \"02\"\n    override:\n      additional-prefix: \"paid | \"\n" +
+		"proxies:\n  - {name: hk-03, type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}\n"
+	path := filepath.Join(t.TempDir(), "main.yaml")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	loader := &ProxySourceLoader{reports: newSourceReports()}
+
+	var names []string
+	err := loader.LoadStream(context.Background(), path, 10, func(batch []map[string]any) error {
+		for _, proxy := range batch {
+			names = append(names, fmt.Sprint(proxy["name"]))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("LoadStream error = %v", err)
+	}
+	// 顶层配置中的节点不受 provider 选项影响
+	if got, want := sprintStrings(names), "hk-03,paid | hk-01"; got != want {
+		t.Fatalf("names = %s, want %s", got, want)
+	}
+	for _, r := range loader.reports.list() {
+		if r.Provider == "paid" && (r.Parsed != 3 || r.Excluded != 2) {
+			t.Fatalf("provider report parsed = %d excluded = %d, want 3 and 2", r.Parsed, r.Excluded)
+		}
+	}
+}
+
+// writeNestedConfig 在 dir 下写入包含一个节点与若干 file 类型 provider 的配置，providers 为 名称 -> 相对路径
+func writeNestedConfig(t *testing.T, dir, file, node string, providers map[string]string) string {
+	t.Helper()
+	body := "proxies:\n  - {name: " + node + ", type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}\n"
+	if len(providers) > 0 {
+		body += "proxy-providers:\n"
+		for name, path := range providers {
+			body += "  " + name + ":\n    type: file\n    path: " + path + "\n"
+		}
+	}
+	path := filepath.Join(dir, file)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func loadNodeNames(t *testing.T, sources ...string) []string {
+	t.Helper()
+	loader := &ProxySourceLoader{}
+	var names []string
+	err := loader.LoadManyStreamSourced(context.Background(), sources, 10, func(_ models.ProxySource, batch []map[string]any) error {
+		for _, proxy := range batch {
+			names = append(names, fmt.Sprint(proxy["name"]))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("LoadManyStreamSourced error = %v", err)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestProxySourceLoaderFileProviderRelativePath(t *testing.T) {
+	dir := t.TempDir()
+	writeNestedConfig(t, dir, "providers/sub.yaml", "sub", nil)
+	main := writeNestedConfig(t, dir, "main.yaml", "main", map[string]string{"local": "providers/sub.yaml"})
+
+	if got, want := sprintStrings(loadNodeNames(t, main)), "main,sub"; got != want {
+		t.Fatalf("names = %s, want %s", got, want)
+	}
+}
+
+func TestProxySourceLoaderProviderCycles(t *testing.T) {
+	dir := t.TempDir()
+	// a 引用自身与 b，b 又引用 a
+	a := writeNestedConfig(t, dir, "a.yaml", "a", map[string]string{"self": "a.yaml", "b": "b.yaml"})
+	writeNestedConfig(t, dir, "b.yaml", "b", map[string]string{"back": "./a.yaml"})
+
+	done := make(chan []string, 1)
+	go func() { done <- loadNodeNames(t, a) }()
+	select {
+	case names := <-done:
+		if got, want := sprintStrings(names), "a,b"; got != want {
+			t.Fatalf("names = %s, want %s", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("loading cyclic providers did not finish")
+	}
+}
+
+func TestProxySourceLoaderProviderDepthAndDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	// main -> l1 -> l2 -> l3 -> l4，l4 超过最大嵌套层数
+	writeNestedConfig(t, dir, "l4.yaml", "l4", nil)
+	writeNestedConfig(t, dir, "l3.yaml", "l3", map[string]string{"p": "l4.yaml"})
+	writeNestedConfig(t, dir, "l2.yaml", "l2", map[string]string{"p": "l3.yaml"})
+	writeNestedConfig(t, dir, "l1.yaml", "l1", map[string]string{"p": "l2.yaml"})
+	shared := writeNestedConfig(t, dir, "shared.yaml", "shared", nil)
+	main := writeNestedConfig(t, dir, "main.yaml", "main", map[string]string{"p": "l1.yaml", "s1": "shared.yaml", "s2": "shared.yaml"})
+
+	// shared.yaml 被两个 provider 引用且同时位于 ConfigPath 中，只加载一次
+	if got, want := sprintStrings(loadNodeNames(t, main+"|"+shared)), "l1,l2,l3,main,shared"; got != want {
+		t.Fatalf("names = %s, want %s", got, want)
+	}
+}
+
+func TestResolveProviderPath(t *testing.T) {
+	for _, tc := range []struct {
+		parent, path, want string
+	}{
+		{"/etc/clash/config.yaml", "providers/a.yaml", "/etc/clash/providers/a.yaml"},
+		{"/etc/clash/config.yaml", "/abs/a.yaml", "/abs/a.yaml"},
+		{"https://example.com/sub/config.yaml", "a.yaml", "https://example.com/sub/a.yaml"},
+		{"https://example.com/sub/config.yaml", "/etc/passwd", "https://example.com/etc/passwd"},
+	} {
+		if got := resolveProviderPath(tc.parent, tc.path); got != tc.want {
+			t.Fatalf("resolveProviderPath(%s, %s) = %s, want %s", tc.parent, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestProxySourceLoaderStdin(t *testing.T) {
+	loader := &ProxySourceLoader{Stdin: strings.NewReader("ss://YWVzLTEyOC1nY206cGFzcw@127.0.0.1:8388#a\n")}
+	var names []string
+	err := loader.LoadManyStreamSourced(context.Background(), []string{"-|-"}, 10, func(source models.ProxySource, batch []map[string]any) error {
+		if source.URL != "-" {
+			t.Fatalf("source = %q, want -", source.URL)
+		}
+		for _, proxy := range batch {
+			names = append(names, proxy["name"].(string))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("load stdin: %v", err)
+	}
+	// 标准输入只读取一次
+	if got := strings.Join(names, ","); got != "a" {
+		t.Fatalf("names = %q, want a", got)
+	}
+
+	// 未设置 Stdin 时（如服务端请求中的 ConfigPath）不读取标准输入
+	err = (&ProxySourceLoader{}).LoadStream(context.Background(), "-", 10, func([]map[string]any) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "stdin is not enabled") {
+		t.Fatalf("load stdin without Stdin: err = %v", err)
+	}
+}
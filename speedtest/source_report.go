@@ -0,0 +1,116 @@
+package speedtest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+// sourceLoadError 配置源下载或解析失败，错误已记录到该来源的报告中。
+// proxy-providers 加载失败时仅跳过该 provider，不影响所在配置中的其他节点
+type sourceLoadError struct {
+	err error
+}
+
+func (e *sourceLoadError) Error() string { return e.err.Error() }
+func (e *sourceLoadError) Unwrap() error { return e.err }
+
+func isSourceLoadError(err error) bool {
+	var le *sourceLoadError
+	return errors.As(err, &le)
+}
+
+// sourceReports 收集一轮测速中各配置源的健康报告，nil 时所有方法均为空操作
+type sourceReports struct {
+	mu         sync.Mutex
+	order      []string
+	reports    map[string]*models.SourceReport
+	bandwidths map[string][]float64
+}
+
+func newSourceReports() *sourceReports {
+	return &sourceReports{
+		reports:    make(map[string]*models.SourceReport),
+		bandwidths: make(map[string][]float64),
+	}
+}
+
+// update 在锁内修改来源对应的报告，报告不存在时按加载顺序创建
+func (s *sourceReports) update(source *models.ProxySource, fn func(r *models.SourceReport)) {
+	if s == nil || source == nil || source.URL == "" {
+		return
+	}
+	key := source.String()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.reports[key]
+	if !ok {
+		r = &models.SourceReport{Source: source.URL, Provider: source.Provider, Status: models.SourceStatusOK}
+		s.reports[key] = r
+		s.order = append(s.order, key)
+	}
+	fn(r)
+}
+
+// recordResult 记录节点的测速结果
+func (s *sourceReports) recordResult(result *models.CProxyWithResult) {
+	if s == nil || result == nil || result.Source == nil {
+		return
+	}
+	alive := result.Alive()
+	s.update(result.Source, func(r *models.SourceReport) {
+		r.Tested++
+		if alive {
+			r.Alive++
+			key := r.Key()
+			s.bandwidths[key] = append(s.bandwidths[key], result.Bandwidth)
+		}
+	})
+}
+
+// list 按加载顺序返回报告副本
+func (s *sourceReports) list() []models.SourceReport {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]models.SourceReport, 0, len(s.order))
+	for _, key := range s.order {
+		r := *s.reports[key]
+		r.MedianBandwidth = median(s.bandwidths[key])
+		out = append(out, r)
+	}
+	return out
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// formatTypeCounts 按类型名排序输出 "类型×数量"，如 "shadowtls×2, ssh×1"
+func formatTypeCounts(counts map[string]int) string {
+	types := make([]string, 0, len(counts))
+	for tp := range counts {
+		types = append(types, tp)
+	}
+	sort.Strings(types)
+	parts := make([]string, 0, len(types))
+	for _, tp := range types {
+		parts = append(parts, fmt.Sprintf("%s×%d", tp, counts[tp]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,133 @@
+package speedtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+func TestSourceReports(t *testing.T) {
+	body := "proxy-providers:\n  broken:\n    type: http\n    url: " + filepath.Join(t.TempDir(), "missing.yaml") + "\n" +
+		"proxies:\n" +
+		"  - {name: hk-01, type: ss, server: 1.1.1.1, port: 8388, cipher: aes-128-gcm, password: pass}\n" +
+		"  - {name: hk-02, type: ss, server: 1.1.1.2, port: 8388, cipher: aes-128-gcm, password: pass}\n" +
+		"  - {name: hk-test, type: ss, server: 1.1.1.3, port: 8388, cipher: aes-128-gcm, password: pass}\n" +
+		"  - {name: hk-bad, type: ss, server: 1.1.1.4, port: 8388}\n"
+	good := filepath.Join(t.TempDir(), "good.yaml")
+	require.NoError(t, os.WriteFile(good, []byte(body), 0644))
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	cache := &countingCache{gets: map[string]int{}, result: models.Result{Delay: 100, Bandwidth: 1024}}
+	tester, err := NewTest(models.Options{
+		ConfigPath:          good + "|" + server.URL + "/sub",
+		NameRegexNonContain: "test",
+		Cache:               cache,
+	})
+	require.NoError(t, err)
+	defer tester.Close()
+	_, err = tester.TestSpeed(context.Background())
+	require.NoError(t, err)
+
+	reports := tester.SourceReports()
+	require.Len(t, reports, 3)
+	byKey := map[string]models.SourceReport{}
+	for _, r := range reports {
+		byKey[r.Key()] = r
+	}
+
+	r := byKey[good]
+	assert.Equal(t, models.SourceStatusOK, r.Status)
+	assert.Equal(t, "clash", r.Format)
+	assert.Equal(t, int64(len(body)), r.Bytes)
+	assert.Equal(t, 4, r.Parsed)
+	assert.Equal(t, 1, r.Filtered)
+	assert.Equal(t, 1, r.ParseFailures)
+	assert.Equal(t, 2, r.Tested)
+	assert.Equal(t, 2, r.Alive)
+	assert.Equal(t, float64(1024), r.MedianBandwidth)
+
+	for key, r := range byKey {
+		if key == good {
+			continue
+		}
+		assert.Equal(t, models.SourceStatusError, r.Status, key)
+		assert.NotEmpty(t, r.Error, key)
+		assert.Zero(t, r.Parsed, key)
+	}
+	assert.Equal(t, http.StatusNotFound, byKey[server.URL+"/sub"].HTTPStatus)
+	assert.Equal(t, "broken", reports[1].Provider)
+}
+
+func TestMedian(t *testing.T) {
+	assert.Zero(t, median(nil))
+	assert.Equal(t, 2.0, median([]float64{3, 1, 2}))
+	assert.Equal(t, 2.5, median([]float64{4, 1, 3, 2}))
+}
+
+func TestSourceReportSubscriptionInfo(t *testing.T) {
+	expire := time.Now().Add(48 * time.Hour).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Subscription-Userinfo", fmt.Sprintf("upload=1024; download=2048; total=10240; expire=%d", expire))
+		_, _ = w.Write([]byte("proxies:\n  - {name: a, type: ss, server: 1.1.1.1, port: 8388, cipher: aes-128-gcm, password: pass}\n"))
+	}))
+	defer server.Close()
+
+	cache := &countingCache{gets: map[string]int{}, result: models.Result{Delay: 100}}
+	tester, err := NewTest(models.Options{ConfigPath: server.URL, Cache: cache})
+	require.NoError(t, err)
+	defer tester.Close()
+	_, err = tester.TestSpeed(context.Background())
+	require.NoError(t, err)
+
+	reports := tester.SourceReports()
+	require.Len(t, reports, 1)
+	assert.Equal(t, &models.SubscriptionInfo{
+		Upload: 1024, Download: 2048, Total: 10240, Remaining: 7168, Expire: expire,
+	}, reports[0].Subscription)
+	assert.True(t, reports[0].Subscription.ExpiresWithin(tester.options.SubscriptionExpiryWarning, time.Now()))
+	assert.Contains(t, tester.formatSubscription(reports[0].Subscription), "⚠️")
+}
+
+func TestParseSubscriptionInfo(t *testing.T) {
+	assert.Nil(t, parseSubscriptionInfo(""))
+
+	info := parseSubscriptionInfo("upload=100; download=2e3; total=1000")
+	assert.Equal(t, &models.SubscriptionInfo{Upload: 100, Download: 2000, Total: 1000}, info)
+	assert.True(t, info.ExpireTime().IsZero())
+	assert.False(t, info.ExpiresWithin(time.Hour, time.Now()))
+}
+
+func TestSourceReportsSingBoxUnsupported(t *testing.T) {
+	body := `{"outbounds": [
+  {"type": "shadowsocks", "tag": "ss", "server": "1.1.1.1", "server_port": 8388, "method": "aes-128-gcm", "password": "p"},
+  {"type": "ssh", "tag": "ssh", "server": "2.2.2.2", "server_port": 22},
+  {"type": "shadowtls", "tag": "stls", "server": "3.3.3.3", "server_port": 443},
+  {"type": "direct", "tag": "direct"}
+]}`
+	path := filepath.Join(t.TempDir(), "sing-box.json")
+	require.NoError(t, os.WriteFile(path, []byte(body), 0644))
+
+	loader := &ProxySourceLoader{reports: newSourceReports()}
+	var names []string
+	require.NoError(t, loader.LoadStream(context.Background(), path, 10, func(batch []map[string]any) error {
+		names = append(names, proxyNames(batch))
+		return nil
+	}))
+	assert.Equal(t, []string{"ss"}, names)
+
+	reports := loader.reports.list()
+	require.Len(t, reports, 1)
+	assert.Equal(t, "sing-box", reports[0].Format)
+	assert.Equal(t, 1, reports[0].Parsed)
+	assert.Equal(t, map[string]int{"shadowtls": 1, "ssh": 1}, reports[0].Unsupported)
+}
@@ -0,0 +1,527 @@
+package speedtest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+	"gopkg.in/yaml.v3"
+)
+
+// topLevelKeyPattern 子集内的顶层键行：普通键或引号内的同类键，之后为 ":" 与空白或行尾
+var topLevelKeyPattern = regexp.MustCompile(`^(?:([A-Za-z0-9_.][A-Za-z0-9_.-]*)|"([A-Za-z0-9_.-]+)"|'([A-Za-z0-9_.-]+)')[ \t]*:(?:[ \t]|$)`)
+
+// yamlSpan proxies 中一个节点在临时文件中的位置
+type yamlSpan struct {
+	offset, length int64
+}
+
+// yamlSubsetError 输入超出 clashStream 可以逐行切分的 YAML 子集
+type yamlSubsetError struct {
+	line   int
+	reason string
+}
+
+func (e *yamlSubsetError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.reason)
+}
+
+// clashStream 逐行读取 Clash/mihomo YAML 配置，proxies 中的节点逐个解码并立即回调，
+// 无需将整个配置读入内存；proxy-providers 与 proxy-groups 在读取结束后解码。
+//
+// 逐行切分只接受以下 YAML 子集，其中每个片段单独解码的结果与完整解码相同：
+//   - 只读取第一个文档：第一个顶层键之前可以有一行 "---"，之后的 "---" 或 "..." 结束读取，
+//     与 yaml.Unmarshal 相同；"%" 指令与 "---" 同一行的内容不在子集内；
+//   - 根为块映射，第 0 列只能是空行、注释、顶层键行或块序列的 "- " 项（序列不缩进时）。
+//     顶层键为 [A-Za-z0-9_.] 开头、由字母数字与 "_.-" 组成的普通键或引号内的同类键，且不重复；
+//     显式键（"? "）、第 0 列的续行、根上的标签或锚点都不在子集内；
+//   - 值在下一行开始的顶层键按第一行的缩进切分为条目：块序列的每一项或块映射的每个键值，
+//     续行缩进更多。每个条目必须可以单独解码为一项（一个键值），因此跨到下一条目的多行字符串或
+//     [...]、引用其它条目或其它顶层键中定义的锚点的别名都不在子集内；
+//   - 值在键同一行开始的顶层键（标量、[...]、锚点或标签）整段解码，同样必须可以单独解码；
+//   - proxies 的值为块序列或同一行开始的 [...]，proxies 后的标签、锚点、别名或标量不在子集内。
+//
+// 输入同时写入临时文件，close 之前可以按位置重新读取已输出的节点（readItem）。遇到子集之外的内容时读完剩余输入并用 yaml.v3 完整解码第一个文档，
+// 跳过已输出的节点后输出其余节点（fallback 记录原因）。完整解码也失败时文档不是合法的 YAML，
+// 此时继续按上述规则逐行切分而不再检查子集：无法单独解码的节点记录到 itemErrors 并跳过，
+// 跨条目的别名因此无法解析
+type clashStream struct {
+	fn func(line int, proxy map[string]any) error
+
+	spool    *os.File // 已读取的输入，回退到完整解码与按位置重新读取节点时使用
+	offset   int64    // 当前行在输入中的位置
+	lineEnd  int64    // 当前行结束的位置
+	lenient  bool     // 完整解码失败，不再检查子集
+	fallback string   // 回退到完整解码的原因
+	started  bool     // 已读到 "---" 或顶层键
+	done     bool     // 第一个文档已结束
+	keys     map[string]struct{}
+
+	section   string   // 当前顶层键
+	inline    bool     // 值在键同一行开始，整段解码
+	text      []string // 需要整段解码的段落（inline、proxy-providers 与 proxy-groups）
+	startLine int      // 段落首行的行号
+
+	childIndent int      // 条目的缩进，未读到条目时为 -1
+	seq         bool     // 条目为序列项
+	entry       []string // 当前条目暂存的行
+	entryLine   int      // 当前条目首行的行号
+	entrySpan   yamlSpan // 当前条目在输入中的位置
+	itemSpan    yamlSpan // 正在回调的节点在输入中的位置
+	spanned     bool     // 正在回调的节点可以按位置重新读取
+	items       int      // 已处理的 proxies 节点数
+
+	providers  string // proxy-providers 段落
+	groups     string // proxy-groups 段落
+	itemErrors []string
+}
+
+func newClashStream(fn func(line int, proxy map[string]any) error) *clashStream {
+	return &clashStream{fn: fn, childIndent: -1, keys: make(map[string]struct{})}
+}
+
+// decodeClashStream 解码 r 中的 proxies 并逐个回调 fn，返回 proxy-providers、proxy-groups（Proxies 为空）
+// 与无法解码的节点（"line N: 原因"）。fn 返回的错误原样返回
+func decodeClashStream(r io.Reader, fn func(line int, proxy map[string]any) error) (*models.RawConfig, []string, error) {
+	s := newClashStream(fn)
+	defer s.close()
+	return s.decode(r)
+}
+
+// decode 读取并解码 r，临时文件在 close 之前保留，供 readItem 重新读取节点
+func (s *clashStream) decode(r io.Reader) (*models.RawConfig, []string, error) {
+	spool, err := os.CreateTemp("", "speedtest-source-*.yaml")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create spool: %w", err)
+	}
+	s.spool = spool
+
+	reader := bufio.NewReaderSize(io.TeeReader(r, spool), 64*1024)
+	var offset int64 // 已切分的输入长度
+	for lineNo := 1; !s.done; lineNo++ {
+		raw, readErr := reader.ReadString('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return nil, s.itemErrors, fmt.Errorf("read config: %w", readErr)
+		}
+		if raw != "" {
+			line := strings.TrimRight(raw, "\r\n")
+			if lineNo == 1 {
+				line = strings.TrimPrefix(line, "\ufeff")
+			}
+			s.offset, s.lineEnd = offset, offset+int64(len(raw))
+			err := s.feed(lineNo, line)
+			var subset *yamlSubsetError
+			if errors.As(err, &subset) {
+				if _, err := io.Copy(io.Discard, reader); err != nil {
+					return nil, s.itemErrors, fmt.Errorf("read config: %w", err)
+				}
+				cfg, ok, decodeErr := s.decodeSpooled()
+				if ok || decodeErr != nil {
+					s.fallback = subset.Error()
+					return cfg, s.itemErrors, decodeErr
+				}
+				s.lenient = true
+				if reader, err = s.spooledFrom(offset + int64(len(raw))); err != nil {
+					return nil, s.itemErrors, err
+				}
+				err = s.feed(lineNo, line)
+			}
+			if err != nil {
+				return nil, s.itemErrors, err
+			}
+			offset += int64(len(raw))
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	if err := s.endSection(); err != nil {
+		var subset *yamlSubsetError
+		if !errors.As(err, &subset) {
+			return nil, s.itemErrors, err
+		}
+		// 最后一个段落超出子集，输入已全部读完
+		cfg, ok, err := s.decodeSpooled()
+		if ok || err != nil {
+			s.fallback = subset.Error()
+			return cfg, s.itemErrors, err
+		}
+		s.lenient = true
+		if err := s.endSection(); err != nil {
+			return nil, s.itemErrors, err
+		}
+	}
+	cfg, err := s.decodeSections()
+	return cfg, s.itemErrors, err
+}
+
+// spooledFrom 从临时文件的 offset 处继续读取
+func (s *clashStream) spooledFrom(offset int64) (*bufio.Reader, error) {
+	info, err := s.spool.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("read spool: %w", err)
+	}
+	return bufio.NewReaderSize(io.NewSectionReader(s.spool, offset, info.Size()-offset), 64*1024), nil
+}
+
+// close 删除临时文件
+func (s *clashStream) close() {
+	if s.spool == nil {
+		return
+	}
+	_ = s.spool.Close()
+	_ = os.Remove(s.spool.Name())
+	s.spool = nil
+}
+
+// outside 记录超出子集的内容：尚未确定文档不是合法 YAML 时返回 yamlSubsetError，否则返回 nil 并继续切分
+func (s *clashStream) outside(line int, format string, args ...any) error {
+	if s.lenient {
+		return nil
+	}
+	return &yamlSubsetError{line: line, reason: fmt.Sprintf(format, args...)}
+}
+
+func (s *clashStream) feed(lineNo int, line string) error {
+	trimmed := strings.TrimLeft(line, " ")
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		if s.entry != nil {
+			s.appendEntry(line)
+		}
+		s.text = appendText(s.text, line)
+		return nil
+	}
+	indent := len(line) - len(trimmed)
+	item := trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+	if indent == 0 && !item {
+		return s.feedTopLevel(lineNo, line)
+	}
+
+	switch {
+	case s.section == "":
+		return s.outside(lineNo, "content before the first top-level key")
+	case s.inline:
+		if indent == 0 {
+			if err := s.outside(lineNo, "sequence item after the value of %q", s.section); err != nil {
+				return err
+			}
+		}
+		s.text = append(s.text, line)
+		return nil
+	}
+
+	if s.childIndent < 0 {
+		s.childIndent, s.seq = indent, item
+	}
+	if indent > s.childIndent {
+		if s.entry == nil {
+			return s.outside(lineNo, "continuation line without an entry")
+		}
+		s.appendEntry(line)
+		s.text = appendText(s.text, line)
+		return nil
+	}
+	if indent < s.childIndent || item != s.seq {
+		if err := s.outside(lineNo, "entry of %q does not match the indent or kind of its first entry", s.section); err != nil {
+			return err
+		}
+		s.appendEntry(line)
+		s.text = appendText(s.text, line)
+		return nil
+	}
+	if s.section == "proxies" && !s.seq {
+		if err := s.outside(lineNo, "proxies is not a sequence"); err != nil {
+			return err
+		}
+	}
+	if err := s.endEntry(); err != nil {
+		return err
+	}
+	s.entry, s.entryLine, s.entrySpan = []string{line}, lineNo, yamlSpan{offset: s.offset, length: s.lineEnd - s.offset}
+	s.text = appendText(s.text, line)
+	return nil
+}
+
+func (s *clashStream) appendEntry(line string) {
+	s.entry = append(s.entry, line)
+	s.entrySpan.length = s.lineEnd - s.entrySpan.offset
+}
+
+// appendText 只向需要整段解码的段落追加行
+func appendText(text []string, line string) []string {
+	if text == nil {
+		return nil
+	}
+	return append(text, line)
+}
+
+// feedTopLevel 处理第 0 列的文档标记与顶层键行
+func (s *clashStream) feedTopLevel(lineNo int, line string) error {
+	if marker, rest, ok := documentMarker(line); ok {
+		if marker == "---" && !s.started {
+			if rest != "" && !strings.HasPrefix(rest, "#") {
+				if err := s.outside(lineNo, "content on the document start line"); err != nil {
+					return err
+				}
+			}
+			s.started = true
+			return nil
+		}
+		s.done = true
+		return s.endSection()
+	}
+	m := topLevelKeyPattern.FindStringSubmatch(line)
+	if m == nil {
+		if err := s.outside(lineNo, "column-0 line is not a simple top-level key"); err != nil {
+			return err
+		}
+		if s.entry != nil {
+			s.appendEntry(line)
+		}
+		s.text = appendText(s.text, line)
+		return nil
+	}
+	if err := s.endSection(); err != nil {
+		return err
+	}
+	key := m[1] + m[2] + m[3]
+	if _, dup := s.keys[key]; dup {
+		if err := s.outside(lineNo, "duplicate top-level key %q", key); err != nil {
+			return err
+		}
+	}
+	s.keys[key] = struct{}{}
+	s.started = true
+	s.section, s.startLine = key, lineNo
+
+	rest := strings.TrimSpace(line[len(m[0]):])
+	s.inline = rest != "" && !strings.HasPrefix(rest, "#")
+	if key == "proxies" && s.inline && !strings.HasPrefix(rest, "[") {
+		if err := s.outside(lineNo, "proxies value %q is not a block or flow sequence", rest); err != nil {
+			return err
+		}
+	}
+	if s.inline || key == "proxy-providers" || key == "proxy-groups" {
+		s.text = []string{line}
+	}
+	return nil
+}
+
+// documentMarker 识别第 0 列的 "---" 与 "..."，rest 为标记之后的内容
+func documentMarker(line string) (marker, rest string, ok bool) {
+	for _, marker := range []string{"---", "..."} {
+		if line == marker || strings.HasPrefix(line, marker+" ") || strings.HasPrefix(line, marker+"\t") {
+			return marker, strings.TrimSpace(line[len(marker):]), true
+		}
+	}
+	return "", "", false
+}
+
+// endEntry 单独解码暂存的条目，proxies 中的节点解码后回调
+func (s *clashStream) endEntry() error {
+	if s.entry == nil {
+		return nil
+	}
+	value, err := decodeEntry(strings.Join(s.entry, "\n"), s.seq)
+	if err != nil {
+		if err := s.outside(s.entryLine, "entry of %q cannot be decoded on its own: %s", s.section, err); err != nil {
+			return err
+		}
+	}
+	line := s.entryLine
+	s.entry = nil
+	if s.section != "proxies" {
+		return nil
+	}
+	s.items++
+	var proxy map[string]any
+	switch {
+	case err == nil && !s.seq:
+		err = errors.New("proxies is not a sequence")
+	case err == nil:
+		err = value.Decode(&proxy)
+	}
+	if err != nil {
+		s.itemErrors = append(s.itemErrors, fmt.Sprintf("line %d: %s", line, err))
+		return nil
+	}
+	if proxy == nil {
+		return nil
+	}
+	s.itemSpan, s.spanned = s.entrySpan, true
+	return s.fn(line, proxy)
+}
+
+// decodeEntry 解码一个条目，返回序列项或只含一个键值的映射
+func decodeEntry(text string, seq bool) (*yaml.Node, error) {
+	value, err := decodeSectionValue("k:\n" + text)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case seq && value != nil && value.Kind == yaml.SequenceNode && len(value.Content) == 1:
+		return value.Content[0], nil
+	case !seq && value != nil && value.Kind == yaml.MappingNode && len(value.Content) == 2:
+		return value, nil
+	}
+	return nil, errors.New("entry does not decode to exactly one item")
+}
+
+// span 正在回调的节点在临时文件中的位置，[...] 形式或回退到完整解码时输出的节点无法按位置读取
+func (s *clashStream) span() (yamlSpan, bool) {
+	return s.itemSpan, s.spanned
+}
+
+// readItem 从临时文件中重新读取并解码 span 处的节点
+func (s *clashStream) readItem(span yamlSpan) (map[string]any, error) {
+	if s.spool == nil {
+		return nil, errors.New("spool closed")
+	}
+	buf := make([]byte, span.length)
+	if _, err := s.spool.ReadAt(buf, span.offset); err != nil {
+		return nil, fmt.Errorf("read spool: %w", err)
+	}
+	value, err := decodeEntry(string(buf), true)
+	if err != nil {
+		return nil, err
+	}
+	var proxy map[string]any
+	if err := value.Decode(&proxy); err != nil {
+		return nil, err
+	}
+	return proxy, nil
+}
+
+// endSection 结束当前顶层键：解码最后一个条目，整段解码 inline 段落并暂存 proxy-providers 与 proxy-groups
+func (s *clashStream) endSection() error {
+	if s.section == "" {
+		return nil
+	}
+	if err := s.endEntry(); err != nil {
+		return err
+	}
+	text := strings.Join(s.text, "\n")
+	if s.inline {
+		if err := s.endInline(text); err != nil {
+			return err
+		}
+	}
+	switch s.section {
+	case "proxy-providers":
+		s.providers = text
+	case "proxy-groups":
+		s.groups = text
+	}
+	s.section, s.inline, s.text, s.childIndent, s.seq = "", false, nil, -1, false
+	return nil
+}
+
+// endInline 整段解码值在键同一行开始的段落，proxies 中的节点逐个回调
+func (s *clashStream) endInline(text string) error {
+	value, err := decodeSectionValue(text)
+	if err != nil {
+		if err := s.outside(s.startLine, "value of %q cannot be decoded on its own: %s", s.section, err); err != nil {
+			return err
+		}
+	}
+	if s.section != "proxies" {
+		return nil
+	}
+	var proxies []map[string]any
+	if err == nil && value != nil {
+		err = value.Decode(&proxies)
+	}
+	if err != nil {
+		s.itemErrors = append(s.itemErrors, fmt.Sprintf("line %d: %s", s.startLine, err))
+		return nil
+	}
+	s.spanned = false
+	for _, proxy := range proxies {
+		s.items++
+		if proxy == nil {
+			continue
+		}
+		if err := s.fn(s.startLine, proxy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeSpooled 完整解码临时文件中的第一个文档，输出尚未处理的节点并返回 proxy-providers 与 proxy-groups。
+// 文档不是合法的 YAML 时返回 false
+func (s *clashStream) decodeSpooled() (*models.RawConfig, bool, error) {
+	if _, err := s.spool.Seek(0, io.SeekStart); err != nil {
+		return nil, false, fmt.Errorf("read spool: %w", err)
+	}
+	var doc struct {
+		Proxies     []yaml.Node                     `yaml:"proxies"`
+		Providers   map[string]models.ProxyProvider `yaml:"proxy-providers"`
+		ProxyGroups []map[string]any                `yaml:"proxy-groups"`
+	}
+	if err := yaml.NewDecoder(s.spool).Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, false, nil
+	}
+	s.spanned = false
+	for i := s.items; i < len(doc.Proxies); i++ {
+		item := &doc.Proxies[i]
+		var proxy map[string]any
+		if err := item.Decode(&proxy); err != nil {
+			s.itemErrors = append(s.itemErrors, fmt.Sprintf("line %d: %s", item.Line, err))
+			continue
+		}
+		if proxy == nil {
+			continue
+		}
+		if err := s.fn(item.Line, proxy); err != nil {
+			return nil, true, err
+		}
+	}
+	return &models.RawConfig{Providers: doc.Providers, ProxyGroups: doc.ProxyGroups}, true, nil
+}
+
+// decodeSections 解码 proxy-providers 与 proxy-groups 段落
+func (s *clashStream) decodeSections() (*models.RawConfig, error) {
+	cfg := &models.RawConfig{}
+	if s.providers != "" {
+		value, err := decodeSectionValue(s.providers)
+		if err == nil && value != nil {
+			err = value.Decode(&cfg.Providers)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse proxy-providers: %w", err)
+		}
+	}
+	if s.groups != "" {
+		value, err := decodeSectionValue(s.groups)
+		if err == nil && value != nil {
+			err = value.Decode(&cfg.ProxyGroups)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse proxy-groups: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// decodeSectionValue 解码一个顶层段落（"key:" 及其内容）并返回其值，值为空时返回 nil。
+// 段落必须只有一个顶层键
+func decodeSectionValue(section string) (*yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(section), &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode || len(root.Content[0].Content) != 2 {
+		return nil, errors.New("section does not decode to a single top-level key")
+	}
+	value := root.Content[0].Content[1]
+	if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+		return nil, nil
+	}
+	return value, nil
+}
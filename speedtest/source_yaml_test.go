@@ -0,0 +1,391 @@
+package speedtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDecodeClashStream(t *testing.T) {
+	body := `mixed-port: 7890
+proxies:
+  # comment
+  - name: a
+    type: ss
+    server: 1.1.1.1
+    port: 8388
+  - {name: b, type: ss, server: 1.1.1.2, port: 8388, cipher: aes-128-gcm, password: pass}
+  - name: [broken
+  - name: "multi
+      line"
+    type: trojan
+    server: 1.1.1.3
+    port: 443
+    password: p
+proxy-groups:
+  - {name: auto, type: url-test, proxies: [a, b]}
+proxy-providers:
+  sub:
+    type: http
+    url: https://example.com/sub
+rules:
+  - MATCH,DIRECT
+`
+	var got []string
+	s := newClashStream(func(line int, proxy map[string]any) error {
+		got = append(got, fmt.Sprintf("%s@%d:%v/%v", proxy["name"], line, proxy["type"], proxy["server"]))
+		return nil
+	})
+	defer s.close()
+	cfg, itemErrors, err := s.decode(strings.NewReader(body))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"a@4:ss/1.1.1.1",
+		"b@8:ss/1.1.1.2",
+		"multi line@10:trojan/1.1.1.3",
+	}, got)
+	// 文档不是合法的 YAML，不回退到完整解码，只跳过无法解码的节点
+	assert.Empty(t, s.fallback)
+	require.Len(t, itemErrors, 1)
+	assert.True(t, strings.HasPrefix(itemErrors[0], "line 9: "), itemErrors[0])
+	require.Contains(t, cfg.Providers, "sub")
+	assert.Equal(t, "http", cfg.Providers["sub"].Type)
+	assert.Equal(t, "https://example.com/sub", cfg.Providers["sub"].Url)
+	require.Len(t, cfg.ProxyGroups, 1)
+	assert.Equal(t, "auto", cfg.ProxyGroups[0]["name"])
+}
+
+func TestDecodeClashStreamFallsBackForAnchors(t *testing.T) {
+	body := `x-common: &common
+  type: ss
+  cipher: aes-128-gcm
+  password: pass
+x-provider: &provider
+  type: http
+  interval: 3600
+proxies:
+  - name: a
+    <<: *common
+    server: 1.1.1.1
+    port: 8388
+  - &b {name: b, type: ss, server: 1.1.1.2, port: 8388, cipher: aes-128-gcm, password: "p&q*r"}
+  - {<<: *b, name: c}
+proxy-providers:
+  sub:
+    <<: *provider
+    url: https://example.com/sub
+`
+	var got []string
+	s := newClashStream(func(line int, proxy map[string]any) error {
+		got = append(got, fmt.Sprintf("%s@%d:%v/%v/%v", proxy["name"], line, proxy["type"], proxy["server"], proxy["password"]))
+		return nil
+	})
+	defer s.close()
+	cfg, itemErrors, err := s.decode(strings.NewReader(body))
+	require.NoError(t, err)
+	assert.Empty(t, itemErrors)
+	assert.Equal(t, []string{
+		"a@9:ss/1.1.1.1/pass",
+		"b@13:ss/1.1.1.2/p&q*r",
+		"c@14:ss/1.1.1.2/p&q*r",
+	}, got)
+	assert.True(t, strings.HasPrefix(s.fallback, "line 9: "), s.fallback)
+	assert.Equal(t, "http", cfg.Providers["sub"].Type)
+	assert.Equal(t, "https://example.com/sub", cfg.Providers["sub"].Url)
+}
+
+func TestDecodeClashStreamSubset(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		body     string
+		fallback string // 为空时整个输入在子集内
+	}{
+		{"block", "proxies:\n  - name: a\n    type: ss\n  - {name: b, type: vmess}\nrules:\n  - MATCH,DIRECT\n", ""},
+		{"indentless", "proxies:\n- name: a\n  type: ss\n- name: b\n  type: vmess\nproxy-groups:\n- {name: g, type: select, proxies: [a]}\n", ""},
+		{"flow", "proxies: [{name: a, type: ss}, {name: b, type: vmess}]\n", ""},
+		{"quoted key", "\"proxies\": [{name: a, type: ss}]\n'proxy-groups':\n  - {name: g, type: select}\n", ""},
+		{"document start", "# comment\n--- # start\nproxies:\n  - {name: a, type: ss}\n", ""},
+		{"block scalar", "x: |\n  proxies:\n  - not a proxy\nproxies:\n  - {name: a, type: ss}\n", ""},
+		{"local alias", "proxies:\n  - name: a\n    type: ss\n    plugin-opts: &o {mode: tls}\n    smux: {opts: *o}\n", ""},
+		{"multi document", "proxies:\n  - {name: a, type: ss}\n---\nproxies:\n  - {name: b, type: ss}\n", ""},
+		{"document end", "proxies:\n  - {name: a, type: ss}\n...\nproxies: [\n", ""},
+		{"flow closed at column 0", "proxies: [{name: a, type: ss},\n  {name: b, type: vmess}\n]\nrules: []\n", "line 3: "},
+		{"column-0 quoted continuation", "x: \"a\nproxies: b\"\nproxies:\n  - {name: a, type: ss}\n", "line 1: "},
+		{"column-0 continuation in proxy", "proxies:\n  - {name: a, type: ss}\n  - {name: b,\ntype: ss}\n", "line 3: "},
+		{"quoted continuation across items", "proxies:\n  - {name: a, type: ss}\n  - name: b\n    password: \"x\n      - name: c\"\n    type: ss\n", ""},
+		{"explicit key", "? proxies\n: - {name: a, type: ss}\n", "line 1: "},
+		{"tag on proxies", "proxies: !!seq\n  - {name: a, type: ss}\n", "line 1: "},
+		{"anchor on proxies", "proxies: &p\n  - {name: a, type: ss}\nx: *p\n", "line 1: "},
+		{"alias as proxies", "x: &p\n  - {name: a, type: ss}\nproxies: *p\n", "line 3: "},
+		{"directive", "%YAML 1.1\n---\nproxies:\n  - {name: a, type: ss}\n", "line 1: "},
+		{"tagged root", "--- !!map\nproxies:\n  - {name: a, type: ss}\n", "line 1: "},
+		{"cross-item alias", "proxies:\n  - &b {name: b, type: ss}\n  - {<<: *b, name: c}\n", "line 3: "},
+		{"alias in groups", "x: &g [a]\nproxies:\n  - {name: a, type: ss}\nproxy-groups:\n  - {name: g, type: select, proxies: *g}\n", "line 5: "},
+		{"proxies mapping", "proxies:\n  a: {name: a, type: ss}\n", "line 2: "},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var want struct {
+				Proxies     []map[string]any                `yaml:"proxies"`
+				Providers   map[string]models.ProxyProvider `yaml:"proxy-providers"`
+				ProxyGroups []map[string]any                `yaml:"proxy-groups"`
+			}
+			wantErr := yaml.Unmarshal([]byte(tc.body), &want)
+			var got []map[string]any
+			s := newClashStream(func(_ int, proxy map[string]any) error {
+				got = append(got, proxy)
+				return nil
+			})
+			defer s.close()
+			cfg, itemErrors, err := s.decode(strings.NewReader(tc.body))
+			require.NoError(t, err)
+			if wantErr != nil {
+				// proxies 不是序列时完整解码失败，节点无法解码
+				assert.Empty(t, got)
+				return
+			}
+			assert.Empty(t, itemErrors)
+			assert.Equal(t, want.Proxies, got)
+			assert.Equal(t, want.Providers, cfg.Providers)
+			assert.Equal(t, want.ProxyGroups, cfg.ProxyGroups)
+			if tc.fallback == "" {
+				assert.Empty(t, s.fallback)
+			} else {
+				assert.True(t, strings.HasPrefix(s.fallback, tc.fallback), s.fallback)
+			}
+		})
+	}
+}
+
+func TestDecodeClashStreamMatchesUnmarshal(t *testing.T) {
+	for _, body := range []string{
+		"proxies:\n- name: a\n  type: ss\n- name: b\n  type: vmess\n",
+		"proxies: [{name: a, type: ss},\n  {name: b, type: vmess}\n]\nrules: []\n",
+		"\ufeffproxies:\r\n  - {name: a, type: ss}\r\n  - {name: b, type: vmess}\r\n",
+	} {
+		var want struct {
+			Proxies []map[string]any `yaml:"proxies"`
+		}
+		require.NoError(t, yaml.Unmarshal([]byte(strings.TrimPrefix(body, "\ufeff")), &want))
+		var got []map[string]any
+		_, itemErrors, err := decodeClashStream(strings.NewReader(body), func(_ int, proxy map[string]any) error {
+			got = append(got, proxy)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Empty(t, itemErrors)
+		assert.Equal(t, want.Proxies, got, body)
+	}
+}
+
+func TestProxySourceLoaderStreamsBeforeDownloadCompletes(t *testing.T) {
+	firstBatch := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b strings.Builder
+		b.WriteString("proxies:\n")
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(&b, "  - {name: p%d, type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}\n", i)
+		}
+		// 超过格式识别所需的前缀长度
+		b.WriteString("# " + strings.Repeat("x", sniffPrefixSize) + "\n")
+		_, _ = w.Write([]byte(b.String()))
+		w.(http.Flusher).Flush()
+		// 收到第一批节点后才发送剩余内容
+		select {
+		case <-firstBatch:
+		case <-time.After(5 * time.Second):
+			return
+		}
+		_, _ = w.Write([]byte("  - {name: p3, type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}\n"))
+	}))
+	defer server.Close()
+
+	loader := &ProxySourceLoader{reports: newSourceReports()}
+	var names []string
+	err := loader.LoadStream(context.Background(), server.URL, 2, func(batch []map[string]any) error {
+		if len(names) == 0 {
+			close(firstBatch)
+		}
+		names = append(names, proxyNames(batch))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p0,p1", "p2,p3"}, names)
+
+	reports := loader.reports.list()
+	require.Len(t, reports, 1)
+	assert.Equal(t, "clash", reports[0].Format)
+	assert.Equal(t, 4, reports[0].Parsed)
+	assert.Greater(t, reports[0].Bytes, int64(sniffPrefixSize))
+}
+
+func TestProxySourceLoaderSlowConsumerOutlastsFetchTimeout(t *testing.T) {
+	timeout := sourceFetchTimeout
+	sourceFetchTimeout = 200 * time.Millisecond
+	defer func() { sourceFetchTimeout = timeout }()
+
+	consumed := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("proxies:\n"))
+		for i := 0; i < 2; i++ {
+			fmt.Fprintf(w, "  - {name: p%d, type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}\n", i)
+		}
+		_, _ = w.Write([]byte("# " + strings.Repeat("x", sniffPrefixSize) + "\n"))
+		w.(http.Flusher).Flush()
+		// 测速端处理完第一批节点后才发送剩余内容，此时已超过下载超时
+		select {
+		case <-consumed:
+		case <-time.After(5 * time.Second):
+			return
+		}
+		_, _ = w.Write([]byte("  - {name: p2, type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}\n"))
+	}))
+	defer server.Close()
+
+	loader := &ProxySourceLoader{reports: newSourceReports()}
+	var names []string
+	err := loader.LoadStream(context.Background(), server.URL, 1, func(batch []map[string]any) error {
+		if len(names) == 0 {
+			time.Sleep(3 * sourceFetchTimeout)
+			close(consumed)
+		}
+		names = append(names, proxyNames(batch))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p0", "p1", "p2"}, names)
+	reports := loader.reports.list()
+	require.Len(t, reports, 1)
+	assert.Equal(t, models.SourceStatusOK, reports[0].Status)
+}
+
+func TestProxySourceLoaderStalledServerTimesOut(t *testing.T) {
+	timeout := sourceFetchTimeout
+	sourceFetchTimeout = 200 * time.Millisecond
+	defer func() { sourceFetchTimeout = timeout }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("proxies:\n  - {name: p0, type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}\n"))
+		w.(http.Flusher).Flush()
+		// 发送响应头与部分内容后停止发送
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	loader := &ProxySourceLoader{reports: newSourceReports()}
+	start := time.Now()
+	err := loader.LoadStream(context.Background(), server.URL, 1, func(batch []map[string]any) error { return nil })
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	reports := loader.reports.list()
+	require.Len(t, reports, 1)
+	assert.Equal(t, models.SourceStatusError, reports[0].Status)
+	assert.Contains(t, reports[0].Error, "no data received for 200ms")
+}
+
+func TestProxySourceLoaderSourceTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("proxies:\n"))
+		// 持续发送数据，但总耗时超过 SourceTimeout
+		for i := 0; i < 50; i++ {
+			fmt.Fprintf(w, "  - {name: p%d, type: ss, server: 127.0.0.1, port: 8388, cipher: aes-128-gcm, password: pass}\n", i)
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}))
+	defer server.Close()
+
+	loader := &ProxySourceLoader{Options: &models.Options{SourceTimeout: 200 * time.Millisecond}, reports: newSourceReports()}
+	err := loader.LoadStream(context.Background(), server.URL, 1, func(batch []map[string]any) error { return nil })
+	require.Error(t, err)
+	reports := loader.reports.list()
+	require.Len(t, reports, 1)
+	assert.Contains(t, reports[0].Error, "request exceeded 200ms")
+}
+
+func TestProxySourceLoaderStreamedProviderFilters(t *testing.T) {
+	provider := writeProxyConfig(t, "hk-01", "jp-01", "us-01", "hk-02")
+	for _, tc := range []struct {
+		filter string
+		want   string
+	}{
+		{"hk", "hk-01,hk-02"},
+		// 多个 filter 时按 filter 的顺序输出
+		{"us`hk", "us-01,hk-01,hk-02"},
+	} {
+		loader := &ProxySourceLoader{}
+		task := sourceTask{
+			source:  models.ProxySource{URL: provider, Provider: "p"},
+			options: models.ProxyProvider{Filter: tc.filter},
+		}
+		var names []string
+		err := loader.loadSourced(context.Background(), task, 10, func(_ models.ProxySource, batch []map[string]any) error {
+			names = append(names, proxyNames(batch))
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, strings.Join(names, ","), tc.filter)
+	}
+}
+
+func TestProxySourceLoaderStreamedChainsReadBack(t *testing.T) {
+	body := `proxies:
+  - name: landing
+    type: ss
+    server: 127.0.0.2
+    port: 8388
+    cipher: aes-128-gcm
+    password: pass
+    dialer-proxy: "[x] transit"
+  - name: transit
+    type: ss
+    server: 127.0.0.1
+    port: 8388
+    cipher: aes-128-gcm
+    password: pass
+proxy-groups:
+  - {name: chain, type: relay, proxies: ["[x] transit", "[x] landing"]}
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(body), 0644))
+
+	prefix, udp := "[x] ", true
+	loader := &ProxySourceLoader{}
+	task := sourceTask{
+		source:  models.ProxySource{URL: path, Provider: "p"},
+		options: models.ProxyProvider{Override: models.ProviderOverride{AdditionalPrefix: &prefix, UDP: &udp}},
+	}
+	byName := map[string]map[string]any{}
+	err := loader.loadSourced(context.Background(), task, 10, func(_ models.ProxySource, batch []map[string]any) error {
+		for _, proxy := range batch {
+			byName[proxy["name"].(string)] = proxy
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Contains(t, byName, "[x] landing")
+	require.Contains(t, byName, "chain")
+	assert.Equal(t, "[x] transit -> [x] landing", chainName(byName["[x] landing"]))
+	assert.Equal(t, "[x] transit -> chain", chainName(byName["chain"]))
+	// 前置节点从临时文件重新读取，并再次应用 override
+	hop := chainConfigs(byName["[x] landing"])[0]
+	assert.Equal(t, "127.0.0.1", hop["server"])
+	assert.Equal(t, true, hop["udp"])
+}
@@ -16,7 +16,6 @@ import (
 	"text/tabwriter"
 	"time"
 
-	"github.com/metacubex/mihomo/adapter"
 	"github.com/xiecang/speedtest-clash/speedtest/models"
 )
 
@@ -44,6 +43,7 @@ type Test struct {
 
 	regexpContain    *regexp.Regexp
 	regexpNonContain *regexp.Regexp
+	configFilter     *configFilter
 
 	proxiesCh chan models.CProxy
 	cancel    context.CancelFunc
@@ -55,6 +55,15 @@ type Test struct {
 	count        *int32 // 计数器，记录已测速的节点数量
 	invalidCount *int32 // 计数器，记录无效节点数量（仅测速阶段）
 	aliveCount   *int32 // 计数器，记录有效节点数量
+	// 计数器，记录与其他节点身份相同、复用测试结果的节点数量
+	coalescedCount *int32
+	// 计数器，记录去重阶段丢弃的重复节点数量
+	droppedCount *int32
+	// 计数器，记录被名称正则或 Filter 表达式过滤的节点数量
+	filteredCount *int32
+	deduper       *proxyDeduper
+	sources       *sourceReports // 本轮测速各配置源的健康报告
+	groups        *proxyGroups   // 本轮测速各配置的 proxy-groups，仅 EvaluateGroups 时收集
 
 	// 自动进度输出相关
 	logTicker *time.Ticker
@@ -62,6 +71,7 @@ type Test struct {
 	testing   atomic.Bool   // 测速状态
 
 	bandwidthLimiter *models.BandwidthLimiter
+	cacheStatsStart  models.CacheStats // 本轮测速开始时的缓存统计，用于计算本轮命中情况
 }
 
 func (t *Test) checkAndLogProgress() {
@@ -89,8 +99,8 @@ func (t *Test) checkAndLogProgress() {
 	bar := strings.Repeat("█", done) + strings.Repeat("░", barWidth-done)
 
 	// 使用 \r 回到行首，使用 \033[K 清除当前行光标后的内容
-	fmt.Printf("\r[%s] 📊 %s %d/%d (%.1f%%) | ✅ %d | ❌ %d\033[K",
-		now.Format("15:04:05"), bar, processed, total, percentage, alive, invalid)
+	fmt.Printf("\r[%s] 📊 %s %d/%d (%.1f%%) | ✅ %d | ❌ %d | 🔁 %d\033[K",
+		now.Format("15:04:05"), bar, processed, total, percentage, alive, invalid, t.CoalescedCount())
 }
 
 func (t *Test) startAutoProgress() {
@@ -141,10 +151,10 @@ func sortResult(results []models.Result, sortField models.SortField) ([]models.R
 	return results, err
 }
 
-// proxyShouldKeep 根据正则等，判断是否应该对此节点测速
-// 注意：正则表达式已在 NewTest 时预编译，这里直接使用
+// proxyShouldKeep 根据正则与 Filter 表达式等，判断是否应该对此节点测速
+// 注意：正则表达式与 Filter 表达式已在 NewTest 时预编译，这里直接使用
 func (t *Test) proxyShouldKeep(proxy models.CProxy) bool {
-	if t.regexpContain == nil && t.regexpNonContain == nil {
+	if t.regexpContain == nil && t.regexpNonContain == nil && t.configFilter == nil {
 		return true
 	}
 
@@ -156,18 +166,24 @@ func (t *Test) proxyShouldKeep(proxy models.CProxy) bool {
 	}
 
 	// 如果设置了 regexpContain，必须匹配才测试
-	if t.regexpContain != nil {
-		return t.regexpContain.MatchString(name)
+	if t.regexpContain != nil && !t.regexpContain.MatchString(name) {
+		return false
 	}
 
-	// 只设置了 regexpNonContain 且未匹配，则测试
-	return true
+	// 直接通过 AddProxy 添加的节点可能没有配置，此时按节点类型在配置中的名称匹配
+	config := proxy.SecretConfig
+	if config == nil {
+		config = map[string]any{"name": name, "type": configType(proxy.Type())}
+	}
+	return t.configFilter.match(config)
 }
 
 // processProxy 内部测速节点处理核心逻辑（计入已处理 count，但不计入总量 totalCount）
 func (t *Test) processProxy(ctx context.Context, proxy models.CProxy) {
 	if !t.proxyShouldKeep(proxy) {
 		atomic.AddInt32(t.count, 1) // 跳过的节点也算作已处理
+		atomic.AddInt32(t.filteredCount, 1)
+		t.sources.update(proxy.Source, func(r *models.SourceReport) { r.Filtered++ })
 		return
 	}
 	// 优先非阻塞入队，避免 ctx.Done() 与 channel send 同时就绪时的随机丢弃问题。
@@ -209,38 +225,66 @@ func (t *Test) AddProxy(ctx context.Context, proxy models.CProxy) error {
 	return nil
 }
 
-// processConfig 内部测速节点配置解析与处理逻辑（计入已处理/无效 count，但不计入总量 totalCount）
-func (t *Test) processConfig(ctx context.Context, config map[string]any) error {
+// admitConfig 按名称正则、Filter 表达式与去重策略判断节点配置是否需要测速，被过滤或丢弃的节点计入已处理 count，
+// 需要测速时返回去重登记的节点身份。在 addProxies 中按配置顺序串行调用，保证去重时"最先出现"的语义稳定，被过滤的节点不参与去重
+func (t *Test) admitConfig(config map[string]any, source *models.ProxySource) (string, bool) {
 	// 优化：提前过滤，避免不必要的解析开销
+	filtered := !t.configFilter.match(config)
 	if name, ok := config["name"].(string); ok && name != "" {
-		if (t.regexpNonContain != nil && t.regexpNonContain.MatchString(name)) ||
-			(t.regexpContain != nil && !t.regexpContain.MatchString(name)) {
-			// 如果被过滤了，增加已处理计数
-			atomic.AddInt32(t.count, 1)
-			return nil
-		}
+		filtered = filtered || (t.regexpNonContain != nil && t.regexpNonContain.MatchString(name)) ||
+			(t.regexpContain != nil && !t.regexpContain.MatchString(name))
+	}
+	if filtered {
+		// 如果被过滤了，增加已处理计数
+		atomic.AddInt32(t.count, 1)
+		atomic.AddInt32(t.filteredCount, 1)
+		t.sources.update(source, func(r *models.SourceReport) { r.Filtered++ })
+		return "", false
+	}
+	identity, ok := t.deduper.admit(config, source)
+	if !ok {
+		atomic.AddInt32(t.droppedCount, 1)
+		atomic.AddInt32(t.count, 1) // 丢弃的重复节点也算处理过
+		t.sources.update(source, func(r *models.SourceReport) { r.Duplicates++ })
+		return "", false
 	}
+	return identity, true
+}
 
+// prepareConfig 测速前处理节点配置
+func (t *Test) prepareConfig(config map[string]any) {
 	// 强制设置 skip-cert-verify
 	if t.options.ForceCertVerify {
 		if _, exists := config["skip-cert-verify"]; exists {
 			config["skip-cert-verify"] = false
 		}
 	}
+}
+
+// processConfig 内部测速节点配置解析与处理逻辑（计入已处理/无效 count，但不计入总量 totalCount），
+// identity 为去重登记的节点身份
+func (t *Test) processConfig(ctx context.Context, config map[string]any, source *models.ProxySource, identity string) error {
+	t.prepareConfig(config)
 
-	proxy, err := adapter.ParseProxy(config)
+	proxy, err := parseProxy(config)
 	if err != nil {
 		atomic.AddInt32(t.invalidCount, 1)
 		atomic.AddInt32(t.count, 1) // 解析失败也算处理过
+		t.sources.update(source, func(r *models.SourceReport) { r.ParseFailures++ })
 		warnf(t.options, "ParseProxy error: %s", err)
 		return err
 	}
-	t.processProxy(ctx, models.CProxy{Proxy: proxy, SecretConfig: config})
+	t.processProxy(ctx, models.CProxy{Proxy: proxy, SecretConfig: config, Source: source, Identity: identity})
 	return nil
 }
 
 // AddProxies 实时批量添加待测速节点配置
 func (t *Test) AddProxies(ctx context.Context, configs []map[string]any) error {
+	return t.addProxies(ctx, configs, nil)
+}
+
+// addProxies 批量添加节点配置，source 为 configs[0] 的来源，configs[i] 的序号为 source.Index+i
+func (t *Test) addProxies(ctx context.Context, configs []map[string]any, source *models.ProxySource) error {
 	if len(configs) == 0 {
 		return nil
 	}
@@ -259,18 +303,28 @@ func (t *Test) AddProxies(ctx context.Context, configs []map[string]any) error {
 	sem := make(chan struct{}, concurrency)
 
 	var ctxErr error
-	for _, config := range configs {
+	for i, config := range configs {
+		var src *models.ProxySource
+		if source != nil {
+			s := *source
+			s.Index += i
+			src = &s
+		}
+		identity, ok := t.admitConfig(config, src)
+		if !ok {
+			continue
+		}
 		select {
 		case <-ctx.Done():
 			ctxErr = ctx.Err()
 			goto waitAndReturn
 		case sem <- struct{}{}:
 			wg.Add(1)
-			go func(c map[string]any) {
+			go func(c map[string]any, src *models.ProxySource, identity string) {
 				defer wg.Done()
 				defer func() { <-sem }()
-				_ = t.processConfig(ctx, c)
-			}(config)
+				_ = t.processConfig(ctx, c, src, identity)
+			}(config, src, identity)
 		}
 	}
 waitAndReturn:
@@ -290,9 +344,9 @@ func (t *Test) AddProxyURLs(ctx context.Context, sources []string) error {
 	if err := t.ensureCanAdd(); err != nil {
 		return err
 	}
-	loader := &ProxySourceLoader{ProxyURL: t.proxyUrl, Options: t.options}
-	return loader.LoadManyStreamBatched(ctx, sources, t.options.SourceBatchSize, func(proxies []map[string]any) error {
-		return t.AddProxies(ctx, proxies)
+	loader := &ProxySourceLoader{ProxyURL: t.proxyUrl, Options: t.options, Stdin: t.options.Stdin, reports: t.sources, groups: t.groups}
+	return loader.LoadManyStreamSourced(ctx, sources, t.options.SourceBatchSize, func(source models.ProxySource, proxies []map[string]any) error {
+		return t.addProxies(ctx, proxies, &source)
 	})
 }
 
@@ -334,6 +388,8 @@ func (t *Test) TestSpeed(ctx context.Context) ([]models.CProxyWithResult, error)
 		return nil, err
 	}
 
+	t.deduper.apply(results)
+	t.deduper.apply(aliveProxies)
 	t._testedSpeed = true
 	t.results = results
 	t.aliveProxies = aliveProxies
@@ -366,6 +422,18 @@ func (t *Test) RunStream(ctx context.Context) (<-chan *models.CProxyWithResult,
 	atomic.StoreInt32(t.totalCount, 0)
 	atomic.StoreInt32(t.invalidCount, 0)
 	atomic.StoreInt32(t.aliveCount, 0)
+	atomic.StoreInt32(t.coalescedCount, 0)
+	atomic.StoreInt32(t.droppedCount, 0)
+	atomic.StoreInt32(t.filteredCount, 0)
+	t.deduper = newProxyDeduper(t.options.Dedup, t.prepareConfig)
+	t.sources = newSourceReports()
+	t.groups = nil
+	if t.options.EvaluateGroups {
+		t.groups = &proxyGroups{}
+	}
+	if reporter, ok := t.options.Cache.(models.CacheStatsReporter); ok {
+		t.cacheStatsStart = reporter.Stats()
+	}
 
 	t.startAutoProgress()
 
@@ -376,6 +444,8 @@ func (t *Test) RunStream(ctx context.Context) (<-chan *models.CProxyWithResult,
 
 	var workerWg sync.WaitGroup
 	sem := make(chan struct{}, maxConcurrency)
+	// 本轮测速中每个节点身份对应的测试，仅由分发协程访问
+	flights := make(map[string]*proxyFlight)
 
 	// worker 处理
 	go func() {
@@ -400,16 +470,27 @@ func (t *Test) RunStream(ctx context.Context) (<-chan *models.CProxyWithResult,
 					goto waitAndExit
 				}
 
+				// 相同身份的节点只测一次，其余节点等待并复用结果
+				identity := ProxyIdentity(proxy.SecretConfig)
+				if f, dup := flights[identity]; dup && identity != "" {
+					workerWg.Add(1)
+					go t.awaitFlight(streamCtx, f, proxy, identity, resultsStream, &workerWg)
+					continue
+				}
+
 				select {
 				case <-streamCtx.Done():
 					goto waitAndExit
 				case sem <- struct{}{}:
+					f := &proxyFlight{done: make(chan struct{})}
+					flights[identity] = f
 					workerWg.Add(1)
 					go func(p models.CProxy) {
 						defer func() {
 							if r := recover(); r != nil {
 								errorf(t.options, "worker panic: %v", r)
 							}
+							close(f.done)
 							atomic.AddInt32(t.count, 1)
 							workerWg.Done()
 							<-sem
@@ -419,20 +500,8 @@ func (t *Test) RunStream(ctx context.Context) (<-chan *models.CProxyWithResult,
 						if err != nil {
 							errorf(t.options, "[%s] test speed err: %v", p.Name(), err)
 						}
-						if result != nil {
-							if result.Alive() {
-								atomic.AddInt32(t.aliveCount, 1)
-							} else {
-								atomic.AddInt32(t.invalidCount, 1)
-							}
-
-							select {
-							case <-streamCtx.Done():
-							case resultsStream <- result:
-							}
-						} else {
-							atomic.AddInt32(t.invalidCount, 1)
-						}
+						f.result = result
+						t.emitResult(streamCtx, result, resultsStream)
 					}(proxy)
 				}
 			}
@@ -444,6 +513,49 @@ func (t *Test) RunStream(ctx context.Context) (<-chan *models.CProxyWithResult,
 	return resultsStream, nil
 }
 
+// proxyFlight 一次节点测试，result 在 done 关闭前写入
+type proxyFlight struct {
+	done   chan struct{}
+	result *models.CProxyWithResult
+}
+
+// awaitFlight 等待相同身份节点的测试完成，以当前节点的名称与配置返回结果，不占用并发名额
+func (t *Test) awaitFlight(ctx context.Context, f *proxyFlight, proxy models.CProxy, identity string, out chan<- *models.CProxyWithResult, wg *sync.WaitGroup) {
+	defer func() {
+		atomic.AddInt32(t.count, 1)
+		wg.Done()
+	}()
+	select {
+	case <-ctx.Done():
+		return
+	case <-f.done:
+	}
+	atomic.AddInt32(t.coalescedCount, 1)
+	var result *models.CProxyWithResult
+	if f.result != nil {
+		result = bindResult(*f.result, proxy, identity)
+	}
+	t.emitResult(ctx, result, out)
+}
+
+// emitResult 更新计数并输出结果，result 为 nil 时计为无效节点
+func (t *Test) emitResult(ctx context.Context, result *models.CProxyWithResult, out chan<- *models.CProxyWithResult) {
+	if result == nil {
+		atomic.AddInt32(t.invalidCount, 1)
+		return
+	}
+	t.sources.recordResult(result)
+	if result.Alive() {
+		atomic.AddInt32(t.aliveCount, 1)
+	} else {
+		atomic.AddInt32(t.invalidCount, 1)
+	}
+	select {
+	case <-ctx.Done():
+	case out <- result:
+	}
+}
+
 func (t *Test) TotalCount() int32 {
 	return atomic.LoadInt32(t.totalCount)
 }
@@ -456,6 +568,153 @@ func (t *Test) AliveCount() int32 {
 	return atomic.LoadInt32(t.aliveCount)
 }
 
+// CacheStats 返回本轮测速的缓存命中统计，缓存未实现 models.CacheStatsReporter 时 ok 为 false
+func (t *Test) CacheStats() (stats models.CacheStats, ok bool) {
+	if t.options == nil {
+		return stats, false
+	}
+	reporter, ok := t.options.Cache.(models.CacheStatsReporter)
+	if !ok {
+		return stats, false
+	}
+	return reporter.Stats().Sub(t.cacheStatsStart), true
+}
+
+// CoalescedCount 返回与其他节点身份相同、复用其测试结果的节点数量
+func (t *Test) CoalescedCount() int32 {
+	if t.coalescedCount == nil {
+		return 0
+	}
+	return atomic.LoadInt32(t.coalescedCount)
+}
+
+// SourceReports 返回本轮测速各配置源的健康报告，按加载顺序排列。
+// 仅包含通过 ConfigPath/AddProxyURLs 加载的来源，测速进行中调用时返回当前进度
+func (t *Test) SourceReports() []models.SourceReport {
+	return t.sources.list()
+}
+
+// LogSourceReports 输出各配置源的健康报告，加载失败的来源会给出原因
+func (t *Test) LogSourceReports() {
+	reports := t.SourceReports()
+	if len(reports) == 0 {
+		return
+	}
+	fmt.Printf("\n📦 配置源报告:\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "来源\t状态\tHTTP\t大小\t格式\t解析\t排除\t链式\t解析失败\t过滤\t重复\t已测\t有效\t带宽中位数")
+	for _, r := range reports {
+		status := "✅"
+		if r.Status != models.SourceStatusOK {
+			status = "❌"
+		}
+		median := models.Result{Bandwidth: r.MedianBandwidth}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			r.Key(), status, r.HTTPStatus, formatBytes(r.Bytes), r.Format,
+			r.Parsed, r.Excluded, r.Chains, r.ParseFailures, r.Filtered, r.Duplicates, r.Tested, r.Alive, median.FormattedBandwidth())
+	}
+	_ = w.Flush()
+	for _, r := range reports {
+		if r.Error != "" {
+			fmt.Printf("   • %s: %s\n", r.Key(), r.Error)
+		}
+		if r.Data == models.SourceDataCached {
+			fmt.Printf("   • %s: 使用 %s 的快照\n", r.Key(), r.SnapshotTime.Format(time.DateTime))
+		}
+		if len(r.Unsupported) > 0 {
+			fmt.Printf("   • %s: 不支持的节点类型 %s\n", r.Key(), formatTypeCounts(r.Unsupported))
+		}
+		for _, lineErr := range r.LineErrors {
+			fmt.Printf("   • %s: %s\n", r.Key(), lineErr)
+		}
+		for _, chainErr := range r.ChainErrors {
+			fmt.Printf("   • %s: 已丢弃 %s\n", r.Key(), chainErr)
+		}
+	}
+	for _, r := range reports {
+		if r.Subscription != nil {
+			fmt.Printf("   • %s: %s\n", r.Key(), t.formatSubscription(r.Subscription))
+		}
+	}
+}
+
+// GroupReports 按本轮测速结果推断 ConfigPath 中各配置的 url-test/fallback/load-balance 组会选择的节点，
+// 按加载顺序排列。需开启 Options.EvaluateGroups，未测速时返回 nil
+func (t *Test) GroupReports() []models.GroupReport {
+	if !t._testedSpeed {
+		return nil
+	}
+	return t.groups.evaluate(t.results)
+}
+
+// LogGroupReports 输出代理组报告，没有有效成员的代理组单独列出
+func (t *Test) LogGroupReports() {
+	reports := t.GroupReports()
+	if len(reports) == 0 {
+		return
+	}
+	fmt.Printf("\n🧭 代理组报告:\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "代理组\t类型\t成员\t有效\t选中\t延迟\t带宽")
+	for _, r := range reports {
+		selected := r.Selected
+		if r.Type == "load-balance" && r.Healthy() {
+			selected = "-"
+		}
+		result := models.Result{Delay: r.Delay, Bandwidth: r.Bandwidth}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%dms\t%s\n",
+			r.Name, r.Type, r.Members, r.Alive, selected, r.Delay, result.FormattedBandwidth())
+	}
+	_ = w.Flush()
+	for _, r := range reports {
+		if r.Error != "" {
+			fmt.Printf("   • %s (%s): %s\n", r.Name, r.Source, r.Error)
+			continue
+		}
+		if !r.Healthy() {
+			fmt.Printf("   • %s (%s): 没有有效成员\n", r.Name, r.Source)
+		}
+		if len(r.Missing) > 0 {
+			fmt.Printf("   • %s (%s): 成员 %s 没有测速结果\n", r.Name, r.Source, strings.Join(r.Missing, ", "))
+		}
+	}
+}
+
+// formatSubscription 以 "已用 1.00GB / 总量 100.00GB，剩余 99.00GB，到期 2025-01-01 00:00:00" 的形式展示订阅信息
+func (t *Test) formatSubscription(s *models.SubscriptionInfo) string {
+	var parts []string
+	if s.Total > 0 {
+		parts = append(parts, fmt.Sprintf("已用 %s / 总量 %s，剩余 %s",
+			formatBytes(s.Upload+s.Download), formatBytes(s.Total), formatBytes(s.Remaining)))
+	}
+	expire := s.ExpireTime()
+	switch {
+	case expire.IsZero():
+		parts = append(parts, "长期有效")
+	case s.ExpiresWithin(t.options.SubscriptionExpiryWarning, time.Now()):
+		parts = append(parts, "⚠️ 到期 "+expire.Format(time.DateTime))
+	default:
+		parts = append(parts, "到期 "+expire.Format(time.DateTime))
+	}
+	return strings.Join(parts, "，")
+}
+
+// DroppedCount 返回去重阶段丢弃的重复节点数量
+func (t *Test) DroppedCount() int32 {
+	if t.droppedCount == nil {
+		return 0
+	}
+	return atomic.LoadInt32(t.droppedCount)
+}
+
+// FilteredCount 返回被名称正则或 Filter 表达式过滤、未测速的节点数量
+func (t *Test) FilteredCount() int32 {
+	if t.filteredCount == nil {
+		return 0
+	}
+	return atomic.LoadInt32(t.filteredCount)
+}
+
 // ProcessCount 返回已处理的节点数量
 func (t *Test) ProcessCount() int32 {
 	return atomic.LoadInt32(t.count)
@@ -471,88 +730,63 @@ func (t *Test) LogNum() {
 	fmt.Printf("\n[%s] 🎯 测速完成！\n", now)
 	fmt.Printf("📊 统计概览:\n")
 	fmt.Printf("   • 总节点: %d | 已处理: %d | ✅ 有效: %d | ❌ 无效: %d\n", total, processed, alive, invalid)
-
-	if alive > 0 {
-		t.LogSummary()
+	if filtered := t.FilteredCount(); filtered > 0 {
+		fmt.Printf("   • 过滤: 跳过 %d 个节点（名称正则或 Filter 表达式）\n", filtered)
+	}
+	if dropped := t.DroppedCount(); dropped > 0 {
+		fmt.Printf("   • 去重: 丢弃 %d 个重复节点（策略 %s）\n", dropped, t.options.Dedup)
 	}
+	if coalesced := t.CoalescedCount(); coalesced > 0 {
+		fmt.Printf("   • 重复节点: %d 个与其他节点相同，已复用测试结果\n", coalesced)
+	}
+	if stats, ok := t.CacheStats(); ok {
+		fmt.Printf("   • 缓存: 命中 %d | 未命中 %d | 命中率 %.1f%% | 写入 %d | 跳过 %d\n",
+			stats.Hits, stats.Misses, stats.HitRate()*100, stats.Sets, stats.Skipped)
+	}
+
+	t.LogSummary()
 }
 
 func (t *Test) LogSummary() {
-	var (
-		minBW, maxBW, totalBW float64
-		minD, maxD, totalD    int64
-		count                 = int64(len(t.aliveProxies))
-	)
+	summary := summarizeResults(t.aliveProxies)
+	if summary.Alive > 0 {
+		t.logPerformance(summary)
+	}
+	t.logSources()
+	fmt.Println()
+}
 
-	if count == 0 {
+// logSources 按来源输出有效/无效节点数，用于判断各订阅的质量
+func (t *Test) logSources() {
+	sources := summarizeSources(t.results)
+	if len(sources) == 0 {
 		return
 	}
-
-	minBW = t.aliveProxies[0].Bandwidth
-	minD = int64(t.aliveProxies[0].Delay)
-
-	for _, p := range t.aliveProxies {
-		bw := p.Bandwidth
-		d := int64(p.Delay)
-
-		if bw < minBW {
-			minBW = bw
-		}
-		if bw > maxBW {
-			maxBW = bw
-		}
-		totalBW += bw
-
-		if d < minD {
-			minD = d
-		}
-		if d > maxD {
-			maxD = d
-		}
-		totalD += d
+	fmt.Printf("\n📦 来源统计:\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, s := range sources {
+		fmt.Fprintf(w, "   • %s\t✅ %d\t❌ %d\n", s.Source, s.Alive, s.Dead)
 	}
+	_ = w.Flush()
+}
 
-	avgBW := totalBW / float64(count)
-	avgD := totalD / count
-
+// logPerformance 输出有效节点的带宽/延迟统计与地区分布
+func (t *Test) logPerformance(summary resultSummary) {
 	// 使用 tabwriter 格式化输出
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	fmt.Fprintf(w, "\n📈 性能详情:\t最小值\t最大值\t平均值\n")
-
-	// 临时构建 Result 结构以调用 Formatted 方法
-	minR := &models.Result{Bandwidth: minBW, TTFB: time.Duration(minD) * time.Millisecond}
-	maxR := &models.Result{Bandwidth: maxBW, TTFB: time.Duration(maxD) * time.Millisecond}
-	avgR := &models.Result{Bandwidth: avgBW, TTFB: time.Duration(avgD) * time.Millisecond}
-
 	fmt.Fprintf(w, "   • 带宽:\t%s\t%s\t%s\n",
-		minR.FormattedBandwidth(), maxR.FormattedBandwidth(), avgR.FormattedBandwidth())
+		summary.Min.FormattedBandwidth(), summary.Max.FormattedBandwidth(), summary.Avg.FormattedBandwidth())
 	fmt.Fprintf(w, "   • 延迟:\t%s\t%s\t%s\n",
-		minR.FormattedTTFB(), maxR.FormattedTTFB(), avgR.FormattedTTFB())
+		summary.Min.FormattedTTFB(), summary.Max.FormattedTTFB(), summary.Avg.FormattedTTFB())
 	_ = w.Flush()
 
-	// 简单的按国家统计
-	countryStats := make(map[string]int)
-	for _, p := range t.aliveProxies {
-		c := p.Country
-		if c == "" {
-			c = "Unknown"
-		}
-		countryStats[c]++
-	}
-
-	if len(countryStats) > 0 {
+	if len(summary.Countries) > 0 {
 		fmt.Printf("\n🌍 地区分布:\n")
-		// 排序输出
-		var countries []string
-		for c := range countryStats {
-			countries = append(countries, c)
-		}
-		sort.Strings(countries)
-		for _, c := range countries {
-			fmt.Printf("   • %-10s: %d 个节点\n", c, countryStats[c])
+		for _, c := range summary.Countries {
+			fmt.Printf("   • %-10s: %d 个节点\n", c.Country, c.Count)
 		}
 	}
-	fmt.Println()
 }
 
 func (t *Test) LogAlive() {
@@ -574,59 +808,34 @@ func (t *Test) LogAlive() {
 }
 
 func (t *Test) WriteToYaml(names ...string) error {
-	if !t._testedSpeed {
-		return ErrSpeedNotTest
-	}
-	if len(t.aliveProxies) == 0 {
-		return ErrSpeedNoAlive
-	}
-	var name string
+	var name = "result.yaml"
 	if len(names) > 0 {
 		name = names[0]
-	} else {
-		name = "result.yaml"
 	}
-	return writeNodeConfigurationToYAML(name, t.aliveProxies)
+	return t.WriteToFile(name, "yaml")
 }
 
 func (t *Test) WriteToCsv(names ...string) error {
-	if !t._testedSpeed {
-		return ErrSpeedNotTest
-	}
-	if len(t.aliveProxies) == 0 {
-		return ErrSpeedNoAlive
-	}
-
-	var name string
+	var name = "result.csv"
 	if len(names) > 0 {
 		name = names[0]
-	} else {
-		name = "result.csv"
 	}
-	return writeToCSV(name, t.aliveProxies)
+	return t.WriteToFile(name, "csv")
 }
 
 // AliveProxiesWithResult 可访问的节点以及结果
 func (t *Test) AliveProxiesWithResult() ([]models.CProxyWithResult, error) {
-	if !t._testedSpeed {
-		_, err := t.TestSpeed(context.Background())
-		if err != nil {
-			return nil, fmt.Errorf("test speed failed: %w", err)
-		}
+	if err := t.ensureTested(); err != nil {
+		return nil, err
 	}
-
 	return t.aliveProxies, nil
 }
 
 // ProxiesWithResult 合法的节点以及结果
 func (t *Test) ProxiesWithResult() ([]models.CProxyWithResult, error) {
-	if !t._testedSpeed {
-		_, err := t.TestSpeed(context.Background())
-		if err != nil {
-			return nil, fmt.Errorf("test speed failed: %w", err)
-		}
+	if err := t.ensureTested(); err != nil {
+		return nil, err
 	}
-
 	return t.results, nil
 }
 
@@ -636,71 +845,53 @@ func (t *Test) ProxiesWithResult() ([]models.CProxyWithResult, error) {
 
 // AliveProxiesToJson 可访问的节点, 返回 JSON string
 func (t *Test) AliveProxiesToJson() ([]byte, error) {
-	ps, err := t.AliveProxies()
-	if err != nil {
+	if err := t.ensureTested(); err != nil {
 		return nil, err
 	}
-	data, err := json.Marshal(ps)
-	if err != nil {
-		return nil, err
-	}
-	return data, nil
+	return json.Marshal(t.exportConfigs(t.aliveProxies, t.options.Export.JSONAnnotation))
 }
 
-// ProxiesToJson 可访问的节点, 返回 JSON string
+// ProxiesToJson 合法的节点, 返回 JSON string
 func (t *Test) ProxiesToJson() ([]byte, error) {
-	ps, err := t.Proxies()
-	if err != nil {
+	if err := t.ensureTested(); err != nil {
 		return nil, err
 	}
-	data, err := json.Marshal(ps)
-	if err != nil {
-		return nil, err
-	}
-	return data, nil
+	return json.Marshal(t.exportConfigs(t.results, t.options.Export.JSONAnnotation))
 }
 
-// AliveProxies 可访问的节点
+// AliveProxies 可访问的节点，返回深拷贝后的纯净配置
 func (t *Test) AliveProxies() ([]map[string]interface{}, error) {
-	if !t._testedSpeed {
-		_, err := t.TestSpeed(context.Background())
-		if err != nil {
-			return nil, fmt.Errorf("test speed failed: %w", err)
-		}
-	}
-	var (
-		ps []map[string]any
-	)
-
-	for _, proxy := range t.aliveProxies {
-		d := proxy.Proxy.SecretConfig
-		d["_check"] = proxy.CheckResults
-		ps = append(ps, d)
-
+	if err := t.ensureTested(); err != nil {
+		return nil, err
 	}
-
-	return ps, nil
+	return t.exportConfigs(t.aliveProxies, models.AnnotationNone), nil
 }
 
-// Proxies 合法的节点
+// Proxies 合法的节点，返回深拷贝后的纯净配置
 func (t *Test) Proxies() ([]map[string]interface{}, error) {
-	if !t._testedSpeed {
-		_, err := t.TestSpeed(context.Background())
-		if err != nil {
-			return nil, fmt.Errorf("test speed failed: %w", err)
-		}
+	if err := t.ensureTested(); err != nil {
+		return nil, err
 	}
-	var (
-		ps []map[string]any
-	)
+	return t.exportConfigs(t.results, models.AnnotationNone), nil
+}
 
-	for _, proxy := range t.results {
-		d := proxy.Proxy.SecretConfig
-		d["_check"] = proxy.CheckResults
-		ps = append(ps, d)
+// AliveProxiesWithAnnotation 可访问的节点，测速结果以旁路结构附带，不写入配置
+func (t *Test) AliveProxiesWithAnnotation() ([]models.AnnotatedProxy, error) {
+	if err := t.ensureTested(); err != nil {
+		return nil, err
 	}
+	return t.annotatedProxies(t.aliveProxies), nil
+}
 
-	return ps, nil
+// ensureTested 未测速时先执行一次测速
+func (t *Test) ensureTested() error {
+	if t._testedSpeed {
+		return nil
+	}
+	if _, err := t.TestSpeed(context.Background()); err != nil {
+		return fmt.Errorf("test speed failed: %w", err)
+	}
+	return nil
 }
 
 func NewTest(options models.Options) (*Test, error) {
@@ -733,11 +924,17 @@ func NewTest(options models.Options) (*Test, error) {
 		}
 	}
 
+	filter, err := newConfigFilter(options.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("Filter 表达式解析失败: %w", err)
+	}
+
 	return &Test{
 		options:          &options,
 		proxyUrl:         proxyUrl,
 		regexpContain:    regexpContain,
 		regexpNonContain: regexpNonContain,
+		configFilter:     filter,
 		state:            stateNew,
 		bandwidthLimiter: models.NewBandwidthLimiter(int64(options.MaxBandwidthMBPerSec * 1024 * 1024)),
 		proxiesCh:        make(chan models.CProxy, cpuCount*10),
@@ -745,6 +942,9 @@ func NewTest(options models.Options) (*Test, error) {
 		count:            new(int32),
 		invalidCount:     new(int32),
 		aliveCount:       new(int32),
+		coalescedCount:   new(int32),
+		droppedCount:     new(int32),
+		filteredCount:    new(int32),
 		stopChan:         make(chan struct{}),
 	}, nil
 }
@@ -644,3 +644,56 @@ func TestNoPanicOnCtxCancelDuringAddProxies(t *testing.T) {
 		t.Fatal("TestSpeed hung after ctx cancellation")
 	}
 }
+
+type countingCache struct {
+	mu     sync.Mutex
+	gets   map[string]int
+	result models.Result
+}
+
+func (c *countingCache) Get(ctx context.Context, key string) (*models.CProxyWithResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets[key]++
+	return &models.CProxyWithResult{Result: c.result}, true
+}
+
+func (c *countingCache) Set(ctx context.Context, key string, result *models.CProxyWithResult) error {
+	return nil
+}
+
+func (c *countingCache) GenerateKey(proxy *models.CProxy) string {
+	return proxy.Name()
+}
+
+func (c *countingCache) Close() error { return nil }
+
+func TestRunStreamCoalescesIdenticalProxies(t *testing.T) {
+	cache := &countingCache{gets: map[string]int{}, result: models.Result{Name: "cached", Delay: 100}}
+	tester, err := NewTest(models.Options{Concurrent: 2, Cache: cache, Timeout: time.Second})
+	assert.NoError(t, err)
+	defer tester.Close()
+
+	resultsCh, err := tester.RunStream(context.Background())
+	assert.NoError(t, err)
+	err = tester.AddProxies(context.Background(), []map[string]any{
+		{"name": "hk-a", "type": "ss", "server": "1.1.1.1", "port": 8388, "cipher": "aes-128-gcm", "password": "pass"},
+		{"name": "hk-b", "type": "ss", "server": "1.1.1.1", "port": "8388", "cipher": "aes-128-gcm", "password": "pass", "udp": true},
+		{"name": "hk-c", "type": "ss", "server": "1.1.1.1", "port": 8388, "cipher": "aes-128-gcm", "password": "pass"},
+		{"name": "us", "type": "ss", "server": "2.2.2.2", "port": 8388, "cipher": "aes-128-gcm", "password": "pass"},
+	})
+	assert.NoError(t, err)
+	tester.CloseInput()
+
+	var names []string
+	for result := range resultsCh {
+		names = append(names, result.Name)
+		assert.Equal(t, result.Name, result.Proxy.Name())
+	}
+
+	assert.ElementsMatch(t, []string{"hk-a", "hk-b", "hk-c", "us"}, names)
+	assert.Len(t, cache.gets, 2, "identical proxies should be tested once")
+	assert.Equal(t, int32(2), tester.CoalescedCount())
+	assert.Equal(t, int32(4), tester.ProcessCount())
+	assert.Equal(t, int32(4), tester.AliveCount())
+}
@@ -1,6 +1,7 @@
 package speedtest
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -67,3 +68,39 @@ func TestStopProgressRobustness(t *testing.T) {
 		tester.stopProgress()
 	})
 }
+
+func TestSummarizeSources(t *testing.T) {
+	sub := &models.ProxySource{URL: "https://a.example/sub"}
+	paid := &models.ProxySource{URL: "https://b.example/sub", Provider: "paid"}
+	results := []models.CProxyWithResult{
+		{Result: models.Result{Name: "a1", Delay: 100, Source: sub}},
+		{Result: models.Result{Name: "a2", Source: sub}},
+		{Result: models.Result{Name: "b1", Delay: 100, Source: paid}},
+		{Result: models.Result{Name: "b2", Delay: 100, Source: paid}},
+		{Result: models.Result{Name: "direct", Delay: 100}},
+	}
+
+	assert.Equal(t, []sourceCount{
+		{Source: "paid (https://b.example/sub)", Alive: 2},
+		{Source: "https://a.example/sub", Alive: 1, Dead: 1},
+	}, summarizeSources(results))
+}
+
+func TestTestSpeedAttachesSource(t *testing.T) {
+	path := writeProxyConfig(t, "a", "b")
+	cache := &countingCache{gets: map[string]int{}, result: models.Result{Delay: 100, Source: &models.ProxySource{URL: "stale"}}}
+	tester, err := NewTest(models.Options{ConfigPath: path, Cache: cache})
+	assert.NoError(t, err)
+	defer tester.Close()
+
+	results, err := tester.TestSpeed(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	indexes := map[string]int{}
+	for _, r := range results {
+		assert.Equal(t, path, r.Source.URL)
+		assert.Same(t, r.Proxy.Source, r.Source)
+		indexes[r.Name] = r.Source.Index
+	}
+	assert.Equal(t, map[string]int{"a": 0, "b": 1}, indexes)
+}
@@ -0,0 +1,29 @@
+package speedtest
+
+import (
+	"time"
+
+	"github.com/metacubex/mihomo/adapter/provider"
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+const defaultSubscriptionExpiryWarning = 7 * 24 * time.Hour
+
+// parseSubscriptionInfo 解析 subscription-userinfo 响应头，
+// 格式为 "upload=1234; download=2234; total=1024000; expire=2218532293"，header 为空时返回 nil
+func parseSubscriptionInfo(header string) *models.SubscriptionInfo {
+	if header == "" {
+		return nil
+	}
+	si := provider.NewSubscriptionInfo(header)
+	info := &models.SubscriptionInfo{
+		Upload:   si.Upload,
+		Download: si.Download,
+		Total:    si.Total,
+		Expire:   si.Expire,
+	}
+	if info.Total > 0 {
+		info.Remaining = max(info.Total-info.Upload-info.Download, 0)
+	}
+	return info
+}
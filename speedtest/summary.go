@@ -0,0 +1,126 @@
+package speedtest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/xiecang/speedtest-clash/speedtest/models"
+)
+
+// resultSummary 结果统计，供 LogSummary 及报告类导出复用
+type resultSummary struct {
+	Total int // 结果总数
+	Alive int // 有效节点数
+
+	// 以下统计仅针对有效节点
+	Min *models.Result
+	Max *models.Result
+	Avg *models.Result
+
+	Countries []countryCount
+	Sources   []sourceCount // 按来源统计有效/无效节点数，包括无效节点
+}
+
+type countryCount struct {
+	Country string
+	Count   int
+}
+
+type sourceCount struct {
+	Source      string
+	Alive, Dead int
+}
+
+func summarizeResults(results []models.CProxyWithResult) resultSummary {
+	var (
+		summary               = resultSummary{Total: len(results)}
+		minBW, maxBW, totalBW float64
+		minD, maxD, totalD    int64
+		countryStats          = make(map[string]int)
+	)
+
+	summary.Sources = summarizeSources(results)
+	for _, p := range results {
+		if !p.Alive() {
+			continue
+		}
+		bw := p.Bandwidth
+		d := int64(p.Delay)
+		if summary.Alive == 0 {
+			minBW, minD = bw, d
+		}
+		summary.Alive++
+
+		if bw < minBW {
+			minBW = bw
+		}
+		if bw > maxBW {
+			maxBW = bw
+		}
+		totalBW += bw
+
+		if d < minD {
+			minD = d
+		}
+		if d > maxD {
+			maxD = d
+		}
+		totalD += d
+
+		c := p.Country
+		if c == "" {
+			c = "Unknown"
+		}
+		countryStats[c]++
+	}
+	if summary.Alive == 0 {
+		return summary
+	}
+
+	count := int64(summary.Alive)
+	avgBW := totalBW / float64(count)
+	avgD := totalD / count
+
+	// Delay 同时填入 TTFB，以便复用 Formatted 方法
+	summary.Min = &models.Result{Bandwidth: minBW, Delay: uint16(minD), TTFB: time.Duration(minD) * time.Millisecond}
+	summary.Max = &models.Result{Bandwidth: maxBW, Delay: uint16(maxD), TTFB: time.Duration(maxD) * time.Millisecond}
+	summary.Avg = &models.Result{Bandwidth: avgBW, Delay: uint16(avgD), TTFB: time.Duration(avgD) * time.Millisecond}
+
+	for c, n := range countryStats {
+		summary.Countries = append(summary.Countries, countryCount{Country: c, Count: n})
+	}
+	sort.Slice(summary.Countries, func(i, j int) bool {
+		return summary.Countries[i].Country < summary.Countries[j].Country
+	})
+	return summary
+}
+
+// summarizeSources 按来源统计有效/无效节点数，按有效节点数降序排列，没有来源信息的结果不参与统计
+func summarizeSources(results []models.CProxyWithResult) []sourceCount {
+	index := make(map[string]int)
+	var sources []sourceCount
+	for _, p := range results {
+		if p.Source == nil {
+			continue
+		}
+		name := p.Source.String()
+		i, ok := index[name]
+		if !ok {
+			i = len(sources)
+			index[name] = i
+			sources = append(sources, sourceCount{Source: name})
+		}
+		if p.Alive() {
+			sources[i].Alive++
+		} else {
+			sources[i].Dead++
+		}
+	}
+	sort.SliceStable(sources, func(i, j int) bool {
+		if sources[i].Alive != sources[j].Alive {
+			return sources[i].Alive > sources[j].Alive
+		}
+		return sources[i].Source < sources[j].Source
+	})
+	return sources
+}
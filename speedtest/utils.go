@@ -2,14 +2,12 @@ package speedtest
 
 import (
 	"context"
-	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
-	"os"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,7 +17,6 @@ import (
 	C "github.com/metacubex/mihomo/constant"
 	"github.com/xiecang/speedtest-clash/speedtest/models"
 	"github.com/xiecang/speedtest-clash/speedtest/requests"
-	"gopkg.in/yaml.v3"
 )
 
 func testspeed(ctx context.Context, proxy models.CProxy, options *models.Options, limiter *models.BandwidthLimiter) (*models.CProxyWithResult, error) {
@@ -28,9 +25,9 @@ func testspeed(ctx context.Context, proxy models.CProxy, options *models.Options
 		// 生成缓存键
 		key = options.Cache.GenerateKey(&proxy)
 
-		// 尝试从缓存获取
+		// 尝试从缓存获取，缓存键不含节点名称，命中时使用当前节点的名称与配置
 		if cached, exists := options.Cache.Get(ctx, key); exists {
-			return cached, nil
+			return bindResult(*cached, proxy, key), nil
 		}
 	}
 
@@ -40,18 +37,11 @@ func testspeed(ctx context.Context, proxy models.CProxy, options *models.Options
 	)
 	switch tp {
 	case C.Shadowsocks, C.ShadowsocksR, C.Snell, C.Socks5, C.Http, C.Vmess, C.Vless, C.Trojan, C.Hysteria, C.Hysteria2, C.WireGuard, C.Tuic:
-		name := key
-		if n, ok := proxy.SecretConfig["name"].(string); ok && n != "" {
-			name = n
-		}
-		result := TestProxy(ctx, name, proxy, options, limiter)
+		result := TestProxy(ctx, proxyName(proxy, key), proxy, options, limiter)
 		if result == nil {
 			return nil, fmt.Errorf("test proxy returned nil result")
 		}
-		var r = &models.CProxyWithResult{
-			Result: *result,
-			Proxy:  proxy,
-		}
+		var r = bindResult(models.CProxyWithResult{Result: *result}, proxy, key)
 		// 存储到缓存
 		if options.Cache != nil {
 			if err := options.Cache.Set(ctx, key, r); err != nil {
@@ -68,6 +58,24 @@ func testspeed(ctx context.Context, proxy models.CProxy, options *models.Options
 	return nil, err
 }
 
+// bindResult 返回以当前节点的名称、配置与来源填充的结果副本，
+// 用于缓存命中或复用相同身份节点的结果时避免沿用其他节点的信息
+func bindResult(r models.CProxyWithResult, proxy models.CProxy, key string) *models.CProxyWithResult {
+	r.Name = proxyName(proxy, key)
+	r.Proxy = proxy
+	r.Source = proxy.Source
+	r.Chain = chainNames(proxy.SecretConfig)
+	return &r
+}
+
+// proxyName 结果中使用的节点名称，配置中没有名称时使用缓存键
+func proxyName(proxy models.CProxy, key string) string {
+	if n, ok := proxy.SecretConfig["name"].(string); ok && n != "" {
+		return n
+	}
+	return key
+}
+
 type proxyTest struct {
 	name             string
 	option           *models.Options
@@ -521,6 +529,8 @@ func (s *proxyTest) Test(ctx context.Context) *models.Result {
 			Jitter:       delayStats.jitter,
 			LossRate:     delayStats.lossRate,
 			TestDuration: time.Since(testStart),
+			// 探活超时是无效节点的正常结果，只有整体测速被取消时才视为不完整
+			Partial: errors.Is(ctx.Err(), context.Canceled),
 		}
 	}
 
@@ -532,6 +542,7 @@ func (s *proxyTest) Test(ctx context.Context) *models.Result {
 		bandwidth     float64
 		downloadBytes int64
 		bandwidthErr  error
+		checkFailed   int
 		mu            sync.Mutex
 		wg            sync.WaitGroup
 	)
@@ -548,8 +559,9 @@ func (s *proxyTest) Test(ctx context.Context) *models.Result {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		countryR := checkProxy(ctx, proxy, []models.CheckType{models.CheckTypeCountry}, loggerFromOptions(option))
+		countryR, failed := checkProxy(ctx, proxy, []models.CheckType{models.CheckTypeCountry}, loggerFromOptions(option))
 		mu.Lock()
+		checkFailed += failed
 		if len(countryR) > 0 {
 			country = countryR[0].Value
 		}
@@ -559,8 +571,9 @@ func (s *proxyTest) Test(ctx context.Context) *models.Result {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		results := checkProxy(ctx, proxy, checkTypes, loggerFromOptions(option))
+		results, failed := checkProxy(ctx, proxy, checkTypes, loggerFromOptions(option))
 		mu.Lock()
+		checkFailed += failed
 		checkResults = results
 		mu.Unlock()
 	}()
@@ -617,6 +630,8 @@ func (s *proxyTest) Test(ctx context.Context) *models.Result {
 		URLForTest:    urlResults,
 		TestDuration:  time.Since(testStart),
 		DownloadBytes: downloadBytes,
+		// 带宽测试在超时前下载多少算多少属于正常结果；整体测速被取消或解锁检测请求失败时，结果不完整
+		Partial: errors.Is(ctx.Err(), context.Canceled) || checkFailed > 0,
 	}
 }
 
@@ -629,55 +644,43 @@ func TestProxy(ctx context.Context, name string, proxy C.Proxy, option *models.O
 	return p.Test(proxyCtx)
 }
 
-func writeNodeConfigurationToYAML(filePath string, results []models.CProxyWithResult) error {
-	fp, err := os.Create(filePath)
-	if err != nil {
-		return err
+// cloneConfig 深拷贝节点配置，避免导出/改名时修改原始 SecretConfig
+func cloneConfig(src map[string]any) map[string]any {
+	if src == nil {
+		return nil
 	}
-	defer func(fp *os.File) {
-		_ = fp.Close()
-	}(fp)
-
-	var proxies []any
-	for _, result := range results {
-		proxies = append(proxies, result.Proxy.SecretConfig)
+	dst := make(map[string]any, len(src))
+	for k, v := range src {
+		dst[k] = cloneValue(v)
 	}
-
-	bytes, err := yaml.Marshal(proxies)
-	if err != nil {
-		return err
-	}
-
-	_, err = fp.Write(bytes)
-	return err
+	return dst
 }
 
-func writeToCSV(filePath string, results []models.CProxyWithResult) error {
-	csvFile, err := os.Create(filePath)
-	if err != nil {
-		return err
-	}
-	defer csvFile.Close()
-
-	// 写入 UTF-8 BOM 头
-	csvFile.WriteString("\xEF\xBB\xBF")
-
-	csvWriter := csv.NewWriter(csvFile)
-	err = csvWriter.Write([]string{"节点", "带宽 (MB/s)", "延迟 (ms)"})
-	if err != nil {
-		return err
-	}
-	for _, result := range results {
-		line := []string{
-			result.Name,
-			fmt.Sprintf("%.2f", result.Bandwidth/(1024*1024)),
-			strconv.FormatInt(result.TTFB.Milliseconds(), 10),
+func cloneValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		return cloneConfig(val)
+	case map[any]any:
+		m := make(map[any]any, len(val))
+		for k, item := range val {
+			m[k] = cloneValue(item)
 		}
-		err = csvWriter.Write(line)
-		if err != nil {
-			return err
+		return m
+	case []any:
+		s := make([]any, len(val))
+		for i, item := range val {
+			s[i] = cloneValue(item)
 		}
+		return s
+	case []string:
+		return append([]string(nil), val...)
+	case []map[string]any:
+		s := make([]map[string]any, len(val))
+		for i, item := range val {
+			s[i] = cloneConfig(item)
+		}
+		return s
+	default:
+		return v
 	}
-	csvWriter.Flush()
-	return nil
 }